@@ -7,19 +7,16 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	"net/http/cookiejar"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -34,6 +31,9 @@ const applicationJSON string = "application/json"
 const applicationOctetStream string = "application/octet-stream"
 const exitCodeFail int = 1
 const exitCodeSuccess int = 0
+const exitCodeUsage int = 2
+const exitCodeServer int = 3
+const exitCodeAuth int = 4
 const fileNameHostToken string = "host_token"
 const fileNameScenario string = "scenario"
 const fileNameServer string = "server"
@@ -43,36 +43,28 @@ const fileNameTeamKey string = "team_key"
 // to be set by build
 var version string
 
-func config(dirWork string, dirConfig string, hostname string) {
+func config(dirWork string, dirConfig string, hostname string, settings agentSettings) {
 	log.Println("Running agent config")
 
 	// don't override existing files
 	serverURL, err := readServerURL(dirConfig)
 	if err == nil || len(serverURL) > 0 {
-		log.Fatalln("ERROR: server URL already set")
+		exitWith(exitCodeFail, "ERROR: server URL already set, use -enroll to add a scenario")
 	}
 
 	// ask for server URL
-	log.Println("Enter server URL: ")
-	_, err = fmt.Scan(&serverURL)
-	if err != nil {
-		log.Fatalln("Error asking for server URL;", err)
-	}
-	// remove trailing slash
-	serverURL = strings.TrimRight(strings.TrimSpace(serverURL), ("/"))
-
-	// ask for admin credentials
-	var username string
-	var password string
-	log.Println("Enter username: ")
-	_, err = fmt.Scan(&username)
-	if err != nil {
-		log.Fatalln("Error asking for username;", err)
-	}
-	log.Println("Enter password: ")
-	_, err = fmt.Scan(&password)
-	if err != nil {
-		log.Fatalln("Error asking for password;", err)
+	serverURL = settings.ServerURL
+	if len(serverURL) == 0 {
+		serverURL, err = prompt("server URL")
+		if err != nil {
+			exitWith(exitCodeUsage, "Error asking for server URL;", err)
+		}
+		settings.ServerURL = serverURL
+		settings, err = validateSettings(settings)
+		if err != nil {
+			exitWith(exitCodeUsage, "ERROR:", err)
+		}
+		serverURL = settings.ServerURL
 	}
 
 	cookieJar, err := cookiejar.New(nil)
@@ -80,27 +72,96 @@ func config(dirWork string, dirConfig string, hostname string) {
 		log.Fatalln("ERROR: unable to create cookie jar;", err)
 	}
 
-	c := &http.Client{
-		Jar: cookieJar,
-	}
+	c := *httpClient
+	c.Jar = cookieJar
 
 	// test server URL
 	resp, err := c.Get(serverURL + "/api/version")
 	if err != nil {
-		log.Fatalln("ERROR: unable to access server;", err)
+		exitWith(exitCodeServer, "ERROR: unable to access server;", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalln("ERROR: unexpected server response: ", resp.StatusCode)
+		exitWith(exitCodeServer, "ERROR: unexpected server response: ", resp.StatusCode)
 	}
 	apiVersion, err := readBody(resp)
 	if err != nil {
-		log.Fatalln("ERROR: could not read server API version;", err)
+		exitWith(exitCodeServer, "ERROR: could not read server API version;", err)
 	}
+	// installed agents update themselves to the server version
 	if apiVersion != version {
-		log.Fatalln("ERROR: could not verify server API version")
+		log.Println("WARNING: server version " + apiVersion + " does not match agent version " + version)
 	}
 	log.Println("Server checks passed")
 
+	adminLogin(&c, serverURL, settings)
+
+	// get server public key
+	serverPubKey, _ := readServerPubKey(dirConfig)
+	if serverPubKey == nil {
+		log.Println("Downloading server public key")
+		resp, err = c.Get(serverURL + "/public/" + fileNameServerPubKey)
+		if err != nil {
+			exitWith(exitCodeServer, "ERROR: unable to retrieve server public key;", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			exitWith(exitCodeServer, "ERROR: could not download server public key:", resp.StatusCode)
+		}
+		pubKeyBs, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			exitWith(exitCodeServer, "ERROR: unable to download server public key;", err)
+		}
+		err = saveFile(dirConfig, fileNameServerPubKey, string(pubKeyBs))
+		if err != nil {
+			log.Fatalln("ERROR: unable to save server public key;", err)
+		}
+	}
+
+	// ask for scenario
+	scenarioID := askScenario(settings)
+
+	// get scenario config
+	config := getScenarioConfig(&c, serverURL, scenarioID, hostname, settings)
+	executeConfig(config)
+
+	log.Println("Saving config files")
+	err = saveFile(dirConfig, fileNameServer, serverURL)
+	if err != nil {
+		log.Fatalln("ERROR: unable to save server URL;", err)
+	}
+	err = saveFile(dirConfig, fileNameScenario, scenarioID)
+	if err != nil {
+		log.Fatalln("ERROR: unable to save scenario;", err)
+	}
+	if len(settings.Role) > 0 {
+		err = saveFile(dirConfig, fileNameRole, settings.Role)
+		if err != nil {
+			log.Fatalln("ERROR: unable to save role;", err)
+		}
+	}
+	err = saveHTTPSettings(dirConfig, settings)
+	if err != nil {
+		log.Fatalln("ERROR: unable to save proxy and CA settings;", err)
+	}
+
+	writeReadmeHTML(dirWork, serverURL)
+}
+
+// enroll token replaces admin login
+func adminLogin(c *http.Client, serverURL string, settings agentSettings) {
+	if len(settings.EnrollToken) > 0 {
+		return
+	}
+
+	// ask for admin credentials
+	username, err := prompt("username")
+	if err != nil {
+		exitWith(exitCodeUsage, "Error asking for username;", err)
+	}
+	password, err := prompt("password")
+	if err != nil {
+		exitWith(exitCodeUsage, "Error asking for password;", err)
+	}
+
 	loginUser := model.LoginUser{
 		Username: username,
 		Password: password,
@@ -111,71 +172,104 @@ func config(dirWork string, dirConfig string, hostname string) {
 	}
 
 	// server admin login
-	resp, err = c.Post(serverURL+"/api/login/", applicationJSON, bytes.NewBuffer(bs))
+	resp, err := c.Post(serverURL+"/api/login/", applicationJSON, bytes.NewBuffer(bs))
 	if err != nil {
-		log.Fatalln("ERROR: unable to access server;", err)
+		exitWith(exitCodeServer, "ERROR: unable to access server;", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalln("ERROR: authentication failure")
+		exitWith(exitCodeAuth, "ERROR: authentication failure")
 	}
 	log.Println("User authenticated")
+}
 
-	// get server public key
-	serverPubKey, _ := readServerPubKey(dirConfig)
-	if serverPubKey == nil {
-		log.Println("Downloading server public key")
-		resp, err = c.Get(serverURL + "/public/" + fileNameServerPubKey)
+func askScenario(settings agentSettings) string {
+	scenarioID := settings.ScenarioID
+	if len(scenarioID) == 0 {
+		var err error
+		scenarioID, err = prompt("scenario")
 		if err != nil {
-			log.Fatalln("ERROR: unable to retrieve server public key;", err)
+			exitWith(exitCodeUsage, "Error asking for scenario;", err)
 		}
-		if resp.StatusCode != http.StatusOK {
-			log.Fatalf("ERROR: could not download server public key: %d", resp.StatusCode)
-		}
-		pubKeyBs, err := ioutil.ReadAll(resp.Body)
+		settings.ScenarioID = scenarioID
+		settings, err = validateSettings(settings)
 		if err != nil {
-			log.Fatalln("ERROR: unable to download server public key;", err)
-		}
-		err = saveFile(dirConfig, fileNameServerPubKey, string(pubKeyBs))
-		if err != nil {
-			log.Fatalln("ERROR: unable to save server public key;", err)
+			exitWith(exitCodeUsage, "ERROR:", err)
 		}
+		scenarioID = settings.ScenarioID
 	}
+	return scenarioID
+}
 
-	var scenarioID string
-	// ask for scenario
-	log.Println("Enter scenario: ")
-	_, err = fmt.Scan(&scenarioID)
+func getScenarioConfig(c *http.Client, serverURL string, scenarioID string, hostname string, settings agentSettings) []model.Action {
+	var req *http.Request
+	var err error
+	if len(settings.EnrollToken) > 0 {
+		req, err = http.NewRequest("GET", serverURL+"/api/scenario-config/"+scenarioID+hostQuery(hostname, settings.Role), nil)
+		if err == nil {
+			req.Header.Set(model.HeaderEnrollToken, settings.EnrollToken)
+		}
+	} else {
+		req, err = http.NewRequest("GET", serverURL+"/api/scenarios/"+scenarioID+"/config"+hostQuery(hostname, settings.Role), nil)
+	}
 	if err != nil {
-		log.Fatalln("Error asking for server URL;", err)
+		log.Fatalln("ERROR: could not form scenario config request;", err)
 	}
-	scenarioID = strings.TrimSpace(scenarioID)
-
-	// get scenario config
-	resp, err = c.Get(serverURL + "/api/scenarios/" + scenarioID + "/config?hostname=" + hostname)
+	resp, err := c.Do(req)
 	if err != nil {
-		log.Fatalln("ERROR: unable to access server;", err)
+		exitWith(exitCodeServer, "ERROR: unable to access server;", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		exitWith(exitCodeAuth, "ERROR: not allowed to access scenario, status code: ", resp.StatusCode)
 	}
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalln("ERROR: cannot access scenario, status code: ", resp.StatusCode)
+		exitWith(exitCodeServer, "ERROR: cannot access scenario, status code: ", resp.StatusCode)
 	}
 	var config []model.Action
 	err = json.NewDecoder(resp.Body).Decode(&config)
 	if err != nil {
-		log.Fatalln("ERROR: cannot read scenario config;", err)
+		exitWith(exitCodeServer, "ERROR: cannot read scenario config;", err)
 	}
-	executeConfig(config)
+	return config
+}
 
-	log.Println("Saving config files")
-	err = saveFile(dirConfig, fileNameServer, serverURL)
+// adds a scenario to a configured agent, on the same server
+func enroll(dirConfig string, dirData string, hostname string, settings agentSettings) {
+	log.Println("Running agent enroll")
+
+	serverURL, err := readServerURL(dirConfig)
+	if err != nil || len(serverURL) == 0 {
+		exitWith(exitCodeUsage, "ERROR: run config before enrolling in more scenarios")
+	}
+
+	cookieJar, err := cookiejar.New(nil)
 	if err != nil {
-		log.Fatalln("ERROR: unable to save server URL;", err)
+		log.Fatalln("ERROR: unable to create cookie jar;", err)
 	}
-	err = saveFile(dirConfig, fileNameScenario, scenarioID)
+	c := *httpClient
+	c.Jar = cookieJar
+
+	adminLogin(&c, serverURL, settings)
+
+	// ask for scenario
+	scenarioID := askScenario(settings)
+	enrollments, err := readEnrollments(dirConfig, dirData)
 	if err != nil {
-		log.Fatalln("ERROR: unable to save scenario;", err)
+		log.Fatalln("ERROR: unable to read scenarios;", err)
+	}
+	if _, err := selectEnrollment(enrollments, scenarioID); err == nil {
+		exitWith(exitCodeUsage, "ERROR: already enrolled in scenario "+scenarioID)
 	}
 
-	writeReadmeHTML(dirWork, serverURL)
+	// get scenario config
+	config := getScenarioConfig(&c, serverURL, scenarioID, hostname, settings)
+	executeConfig(config)
+
+	id, _ := strconv.ParseUint(scenarioID, 10, 64)
+	_, err = saveEnrollment(dirConfig, dirData, id, settings.Role)
+	if err != nil {
+		log.Fatalln("ERROR: unable to save enrollment;", err)
+	}
+	log.Println("Enrolled in scenario " + scenarioID)
 }
 
 func copyTeamFiles(dirWork string) {
@@ -189,56 +283,73 @@ func copyTeamFiles(dirWork string) {
 	}
 }
 
-func executeConfig(config []model.Action) {
+func executeConfig(config []model.Action) []configResult {
 	log.Println("Executing scenario config")
 
-	for _, action := range config {
-		log.Println(" - ", action.Type, ": ", action.Command, "[", strings.Join(action.Args, ","), "]")
-		if action.Type != model.ActionTypeExec {
-			continue
-		}
-		if &action.Command == nil || len(action.Command) == 0 {
-			continue
-		}
-		cmd := exec.Command(action.Command, action.Args...)
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			log.Println("ERROR: unable to get stdout;", err)
-		}
-		stderr, err := cmd.StderrPipe()
-		if err != nil {
-			log.Println("ERROR: unable to get stderr;", err)
-		}
-		go func() {
-			multiReader := io.MultiReader(stdout, stderr)
-			scanner := bufio.NewScanner(multiReader)
-			for scanner.Scan() {
-				line := scanner.Text()
-				log.Println(line)
-			}
-		}()
+	results := applyConfig(config)
 
-		err = cmd.Run()
-		if err != nil {
-			log.Println("Unable to execute config action;", err)
-		}
+	log.Println("Applied config, " + summarizeConfigResults(results) + ". Check log output.")
+	return results
+}
+
+// puts the host back in the scenario's starting state, e.g. between sessions
+func reapplyConfig(dirConfig string, dirData string, hostname string, settings agentSettings) {
+	log.Println("Running agent apply config")
+
+	serverURL, err := readServerURL(dirConfig)
+	if err != nil || len(serverURL) == 0 {
+		exitWith(exitCodeUsage, "ERROR: run config before applying scenario config")
+	}
+	enrollments, err := readEnrollments(dirConfig, dirData)
+	if err != nil {
+		log.Fatalln("ERROR: unable to read scenarios;", err)
+	}
+	e, err := selectEnrollment(enrollments, settings.ScenarioID)
+	if err != nil {
+		exitWith(exitCodeUsage, "ERROR:", err)
 	}
 
-	log.Println("Applied config. Check log output.")
+	cookieJar, err := cookiejar.New(nil)
+	if err != nil {
+		log.Fatalln("ERROR: unable to create cookie jar;", err)
+	}
+	c := *httpClient
+	c.Jar = cookieJar
+
+	adminLogin(&c, serverURL, settings)
+
+	settings.ScenarioID = strconv.FormatUint(e.ScenarioID, 10)
+	if len(settings.Role) == 0 {
+		settings.Role = e.role()
+	}
+	config := getScenarioConfig(&c, serverURL, settings.ScenarioID, hostname, settings)
+	results := executeConfig(config)
+	for _, result := range results {
+		if len(result.Error) > 0 {
+			exitWith(exitCodeFail, "ERROR: not all config actions applied")
+		}
+	}
 }
 
-func getScenarioChecks(serverURL string, scenarioID uint64, hostname string, lastModified string) ([]model.Action, string, error) {
+// the host token, if any, asks the server how many commands are queued for
+// the host
+func getScenarioChecks(serverURL string, scenarioID uint64, hostname string, role string, hostToken string, lastModified string, schedule checkSchedule) ([]model.Action, string, checkSchedule, int, error) {
 	log.Println("Read scenario checks")
 
 	scenarioIDStr := strconv.FormatUint(scenarioID, 10)
-	url := serverURL + "/api/scenario-checks/" + scenarioIDStr + "?hostname=" + hostname
-	client := &http.Client{}
+	url := serverURL + "/api/scenario-checks/" + scenarioIDStr + hostQuery(hostname, role)
 	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", schedule, 0, err
+	}
 	req.Header.Set("If-Modified-Since", lastModified)
-	resp, err := client.Do(req)
+	if len(hostToken) > 0 {
+		req.Header.Set(model.HeaderHostToken, hostToken)
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		log.Println("ERROR: could not access server;", err)
-		return nil, "", err
+		return nil, "", schedule, 0, err
 	}
 
 	var checks []model.Action
@@ -249,79 +360,60 @@ func getScenarioChecks(serverURL string, scenarioID uint64, hostname string, las
 		err = json.NewDecoder(resp.Body).Decode(&checks)
 		if err != nil {
 			log.Println("ERROR: could not read scenario checks")
-			return nil, "", err
+			return nil, "", schedule, 0, err
 		}
 	} else if resp.StatusCode == 304 {
 		// scenario checks not modified
 	} else {
-		return nil, "", fmt.Errorf("ERROR: could not get scenario checks: %d", resp.StatusCode)
+		return nil, "", schedule, 0, fmt.Errorf("ERROR: could not get scenario checks: %d", resp.StatusCode)
+	}
+
+	commands, _ := strconv.Atoi(resp.Header.Get(model.HeaderHostCommands))
+	return checks, lastModified, parseCheckSchedule(resp.Header, schedule), commands, nil
+}
+
+func getScenarioScripts(serverURL string, scenarioID uint64, hostname string, role string) ([]model.Script, error) {
+	log.Println("Read scenario scripts")
+
+	scenarioIDStr := strconv.FormatUint(scenarioID, 10)
+	url := serverURL + "/api/scenario-scripts/" + scenarioIDStr + hostQuery(hostname, role)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		log.Println("ERROR: could not access server;", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("ERROR: could not get scenario scripts: %d", resp.StatusCode)
+	}
+
+	var scripts []model.Script
+	err = json.NewDecoder(resp.Body).Decode(&scripts)
+	if err != nil {
+		log.Println("ERROR: could not read scenario scripts")
+		return nil, err
 	}
 
-	return checks, lastModified, nil
+	return scripts, nil
 }
 
 func executeScenarioChecks(scenarioID uint64, hostToken string, checks []model.Action, lastModified string, outputDir string, tempDir string, entities []*openpgp.Entity) {
 	log.Println("Executing scenario checks")
 	checkResults := []string{}
-	for _, check := range checks {
-		var result string
-		if check.Type == model.ActionTypeExec {
-			if &check.Command == nil || len(check.Command) == 0 {
-				result = "invalid command"
-			} else {
-				cmd := exec.Command(check.Command, check.Args...)
-				cmd.Dir = tempDir
-				out, err := cmd.Output()
-				if err != nil {
-					result = "could not execute file"
-				}
-				result = strings.TrimSpace(string(out))
-			}
-		} else if check.Type == model.ActionTypeFileExist {
-			if len(check.Args) == 1 {
-				if _, err := os.Stat(check.Args[0]); err == nil {
-					result = "true"
-
-				} else {
-					result = "false"
-				}
-			}
-		} else if check.Type == model.ActionTypeFileRegex {
-			if len(check.Args) == 2 {
-				fp := check.Args[0]
-				rgx := regexp.MustCompile(check.Args[1])
-				contents, err := ioutil.ReadFile(fp)
-				if err != nil {
-					result = "could not read file"
-				} else {
-					b := rgx.MatchString(string(contents))
-					if b {
-						result = "true"
-					} else {
-						result = "false"
-					}
-				}
-			}
-		} else if check.Type == model.ActionTypeFileValue {
-			if len(check.Args) == 2 {
-				fp := check.Args[0]
-				rgx := regexp.MustCompile(check.Args[1])
-				contents, err := ioutil.ReadFile(fp)
-				if err != nil {
-					result = "could not read file"
-				} else {
-					rrs := rgx.FindAllString(string(contents), -1)
-					result = strconv.Itoa(len(rrs))
-				}
-			}
-		}
-		checkResults = append(checkResults, result)
+	checkResultDetails := [][]model.CheckResultDetail{}
+	checkDiagnostics := []string{}
+	for _, detail := range executeChecks(checks, tempDir, checkWorkers) {
+		checkResults = append(checkResults, detail.Result)
+		checkResultDetails = append(checkResultDetails, detail.Children)
+		checkDiagnostics = append(checkDiagnostics, detail.Diagnostics)
 	}
 	auditCheckResults := model.AuditCheckResults{}
 	auditCheckResults.ScenarioID = scenarioID
 	auditCheckResults.HostToken = hostToken
 	auditCheckResults.Timestamp = time.Now().Unix()
 	auditCheckResults.CheckResults = checkResults
+	auditCheckResults.CheckResultDetails = checkResultDetails
+	auditCheckResults.CheckDiagnostics = checkDiagnostics
 	auditCheckResults.ChecksLastModified = lastModified
 
 	// save results
@@ -329,42 +421,85 @@ func executeScenarioChecks(scenarioID uint64, hostToken string, checks []model.A
 	if err != nil {
 		log.Println("ERROR: could not prepare saving results to file;", err)
 	} else {
-		fileName := strconv.FormatInt(auditCheckResults.Timestamp, 10)
-		saveFile(outputDir, fileName, string(bs))
+		// renamed into place, so results are never sent half written
+		fileName := strconv.FormatInt(auditCheckResults.Timestamp, 10) + "-" + strconv.FormatUint(scenarioID, 10)
+		err = writeFileAtomic(path.Join(outputDir, fileName), bs, 0400)
+		if err != nil {
+			log.Println("ERROR: could not save results to file;", err)
+		}
 	}
 }
 
-func executeSubmitScenarioCheckResults(serverURL string, outputDir string) {
-	files, err := ioutil.ReadDir(outputDir)
-	if err != nil {
-		log.Println("ERROR: cannot read results directory;", err)
-		return
-	}
-	if len(files) == 0 {
-		return
-	}
+// children are only given for composite checks, diagnostics for EXEC checks
+func executeCheck(check model.Action, tempDir string) model.CheckResultDetail {
+	detail := model.CheckResultDetail{Description: check.Description}
+	var result string
+	if check.Type == model.ActionTypeExec {
+		result, detail.Diagnostics = executeCommand(check, tempDir)
+	} else if check.Type == model.ActionTypeFileExist {
+		if len(check.Args) == 1 {
+			if _, err := os.Stat(check.Args[0]); err == nil {
+				result = "true"
 
-	log.Println("Sending results to server")
-
-	for _, resultFile := range files {
-		filePath := path.Join(outputDir, resultFile.Name())
-		bs, err := ioutil.ReadFile(filePath)
-		if err != nil {
-			log.Println("ERROR: unable to read results file;", err)
-			continue
+			} else {
+				result = "false"
+			}
 		}
-
-		resp, err := http.Post(serverURL+"/api/audit/", applicationOctetStream, bytes.NewBuffer(bs))
-		if err != nil {
-			log.Println("ERROR: unable to send results file;", err)
-			break
+	} else if check.Type == model.ActionTypeFileRegex {
+		if len(check.Args) == 2 {
+			fp := check.Args[0]
+			rgx := regexp.MustCompile(check.Args[1])
+			contents, err := ioutil.ReadFile(fp)
+			if err != nil {
+				result = "could not read file"
+			} else {
+				b := rgx.MatchString(string(contents))
+				if b {
+					result = "true"
+				} else {
+					result = "false"
+				}
+			}
 		}
-		if resp.StatusCode == http.StatusBadRequest {
-			log.Println("SERVER REJECTED")
+	} else if check.Type == model.ActionTypeFileValue {
+		if len(check.Args) == 2 {
+			fp := check.Args[0]
+			rgx := regexp.MustCompile(check.Args[1])
+			contents, err := ioutil.ReadFile(fp)
+			if err != nil {
+				result = "could not read file"
+			} else {
+				rrs := rgx.FindAllString(string(contents), -1)
+				result = strconv.Itoa(len(rrs))
+			}
 		}
-		log.Println("DELETING", filePath)
-		os.Remove(filePath)
-	}
+	} else if check.Type == model.ActionTypeFileSweep {
+		result = checkFileSweep(check.Args)
+	} else if check.Type == model.ActionTypeConfigValue {
+		result = checkConfigValue(check.Args)
+	} else if check.Type == model.ActionTypeSysctl {
+		result = checkSysctl(check.Args)
+	} else if check.Type == model.ActionTypeServiceActive {
+		result = checkServiceActive(check.Args)
+	} else if check.Type == model.ActionTypeServiceEnabled {
+		result = checkServiceEnabled(check.Args)
+	} else if check.Type == model.ActionTypeScheduledJob {
+		result = checkScheduledJob(check.Args)
+	} else if check.Type == model.ActionTypeFirewallEnabled {
+		result = checkFirewallEnabled(check.Args)
+	} else if check.Type == model.ActionTypeFirewallPolicy {
+		result = checkFirewallPolicy(check.Args)
+	} else if check.Type == model.ActionTypeFirewallRule {
+		result = checkFirewallRule(check.Args)
+	} else if check.Type == model.ActionTypePAMSetting {
+		result = checkPAMSetting(check.Args)
+	} else if check.Type == model.ActionTypeSudoPrivilege {
+		result = checkSudoPrivilege(check.Args)
+	} else if check.Type == model.ActionTypeComposite {
+		result, detail.Children = checkComposite(check, tempDir, 0)
+	}
+	detail.Result = result
+	return detail
 }
 
 func install() {
@@ -380,7 +515,37 @@ func install() {
 	}
 }
 
+func repair() {
+	log.Println("Repairing agent")
+
+	host, err := getCurrentHost()
+	if err != nil {
+		log.Fatalln("ERROR: could not get current host;", err)
+	}
+	err = host.repair()
+	if err != nil {
+		log.Fatalln("ERROR: could not repair;", err)
+	}
+}
+
+func uninstall() {
+	log.Println("Uninstalling agent")
+
+	host, err := getCurrentHost()
+	if err != nil {
+		log.Fatalln("ERROR: could not get current host;", err)
+	}
+	err = host.uninstall()
+	if err != nil {
+		log.Fatalln("ERROR: could not uninstall;", err)
+	}
+}
+
 func pressEnterBeforeExit(code int) {
+	// nobody to press enter
+	if !stdinIsTerminal() {
+		os.Exit(code)
+	}
 	log.Println("Press enter to exit")
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
@@ -445,26 +610,30 @@ func readTeamKey(dirData string) (string, error) {
 	return string(bs), nil
 }
 
-func requestHostToken(dirData string, serverURL string, scenarioID uint64, hostname string) (string, error) {
+// also returns the role the server matched the host to
+func requestHostToken(dirData string, serverURL string, scenarioID uint64, hostname string, role string) (string, string, error) {
 	log.Println("Requesting host token")
 	hostTokenRequest := model.HostTokenRequest{
 		ScenarioID: scenarioID,
 		Hostname:   hostname,
+		Role:       role,
 	}
 	hostTokenRequestBs, err := json.Marshal(hostTokenRequest)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	resp, err := http.Post(serverURL+"/api/host-token/request", applicationJSON, bytes.NewBuffer(hostTokenRequestBs))
+	resp, err := httpClient.Post(serverURL+"/api/host-token/request", applicationJSON, bytes.NewBuffer(hostTokenRequestBs))
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	if resp.StatusCode != 200 {
-		return "", errors.New("Could not request host token, unexpected status code")
+		resp.Body.Close()
+		return "", "", errors.New("Could not request host token, unexpected status code")
 	}
 
-	return readBody(resp)
+	hostToken, err := readBody(resp)
+	return hostToken, resp.Header.Get(model.HeaderHostRole), err
 }
 
 func saveFile(dir string, fileName string, content string) error {
@@ -472,70 +641,114 @@ func saveFile(dir string, fileName string, content string) error {
 	return ioutil.WriteFile(file, []byte(content), 0400)
 }
 
-func teamSetup(dirData string, serverURL string) {
+// the team key is asked for once and registered for every scenario
+func teamSetup(enrollments []enrollment, serverURL string, settings agentSettings) {
 	log.Println("Running team setup")
 
-	// team key exists
-	teamKey, err := readTeamKey(dirData)
-	if err == nil || len(teamKey) > 0 {
+	// scenarios without a team key yet
+	pending := make([]enrollment, 0)
+	hostTokens := make([]string, 0)
+	for _, e := range enrollments {
+		if _, err := readTeamKey(e.dirData); err == nil {
+			continue
+		}
+		// no host token yet
+		hostToken, err := readHostToken(e.dirData)
+		if err != nil || len(hostToken) == 0 {
+			log.Println("Cannot register, agent not running or unable to access scoring server. Try again later.")
+			pressEnterBeforeExit(exitCodeServer)
+		}
+		pending = append(pending, e)
+		hostTokens = append(hostTokens, hostToken)
+	}
+	if len(pending) == 0 {
 		log.Println("Team key already set")
 		pressEnterBeforeExit(exitCodeSuccess)
 	}
 
-	// no host token yet
-	hostToken, err := readHostToken(dirData)
-	if err != nil || len(hostToken) == 0 {
-		log.Println("Cannot register, agent not running or unable to access scoring server. Try again later.")
-		pressEnterBeforeExit(exitCodeFail)
-	}
-
+	var teamKey string
+	var err error
 	for {
-		// ask for team key
-		log.Println("Enter team key: ")
-		_, err := fmt.Scan(&teamKey)
-		if err != nil {
-			log.Println("Error asking for team key;", err)
-			pressEnterBeforeExit(exitCodeFail)
+		// ask for team key, a given key is only tried once
+		if len(settings.TeamKey) > 0 {
+			teamKey = settings.TeamKey
+		} else {
+			teamKey, err = prompt("team key")
+			if err != nil {
+				log.Println("Error asking for team key;", err)
+				pressEnterBeforeExit(exitCodeUsage)
+			}
 		}
 
 		// register team key with host token
-		c := http.Client{}
-		data := model.HostTokenRegistration{
-			HostToken: hostToken,
-			TeamKey:   teamKey,
-		}
-		bs, err := json.Marshal(data)
-		if err != nil {
-			log.Println("ERROR: could not form host token registration request;", err)
-			pressEnterBeforeExit(exitCodeFail)
-		}
-		r, err := c.Post(serverURL+"/api/host-token/register",
-			"application/json", bytes.NewBuffer(bs))
+		statusCode, err := registerTeamKey(serverURL, hostTokens[0], teamKey)
 		if err != nil {
 			log.Println("ERROR: unable to POST team key (try again later);", err)
-			pressEnterBeforeExit(exitCodeFail)
+			pressEnterBeforeExit(exitCodeServer)
 		}
-		if r.StatusCode == http.StatusOK {
+		if statusCode == http.StatusOK {
 			log.Println("Team key registered with host token.")
 			break
-		} else if r.StatusCode == http.StatusUnauthorized {
+		} else if statusCode == http.StatusUnauthorized {
+			if len(settings.TeamKey) > 0 {
+				log.Println("Team key rejected.")
+				pressEnterBeforeExit(exitCodeAuth)
+			}
 			log.Println("Team key rejected. Try again.")
 			continue
 		} else {
-			log.Printf("ERROR: Unexpected status code from server: %d", r.StatusCode)
+			log.Printf("ERROR: Unexpected status code from server: %d", statusCode)
+			if len(settings.TeamKey) > 0 {
+				pressEnterBeforeExit(exitCodeServer)
+			}
 			continue
 		}
 	}
-	err = saveFile(dirData, fileNameTeamKey, teamKey)
+	err = saveFile(pending[0].dirData, fileNameTeamKey, teamKey)
 	if err != nil {
 		log.Println("ERROR: cannot save team key;", err)
 		pressEnterBeforeExit(exitCodeFail)
 	}
 
+	for i := 1; i < len(pending); i++ {
+		scenario := strconv.FormatUint(pending[i].ScenarioID, 10)
+		statusCode, err := registerTeamKey(serverURL, hostTokens[i], teamKey)
+		if err != nil {
+			log.Println("ERROR: unable to POST team key for scenario "+scenario+" (try again later);", err)
+			pressEnterBeforeExit(exitCodeServer)
+		}
+		if statusCode != http.StatusOK {
+			log.Printf("ERROR: team key not registered for scenario %s: %d", scenario, statusCode)
+			pressEnterBeforeExit(exitCodeServer)
+		}
+		err = saveFile(pending[i].dirData, fileNameTeamKey, teamKey)
+		if err != nil {
+			log.Println("ERROR: cannot save team key;", err)
+			pressEnterBeforeExit(exitCodeFail)
+		}
+	}
+
 	log.Println("Team setup complete")
 	pressEnterBeforeExit(exitCodeSuccess)
 }
 
+func registerTeamKey(serverURL string, hostToken string, teamKey string) (int, error) {
+	data := model.HostTokenRegistration{
+		HostToken: hostToken,
+		TeamKey:   teamKey,
+	}
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+	r, err := httpClient.Post(serverURL+"/api/host-token/register", applicationJSON, bytes.NewBuffer(bs))
+	if err != nil {
+		return 0, err
+	}
+	r.Body.Close()
+	return r.StatusCode, nil
+}
+
 func main() {
 	// set seed
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -548,19 +761,50 @@ func main() {
 	dirWork := filepath.Dir(ex)
 
 	// program arguments
+	var askApplyConfig bool
 	var askConfig bool
 	var askCopyFiles bool
+	var askDryRun bool
+	var askEnroll bool
 	var askInstall bool
+	var askRepair bool
+	var askStatus bool
 	var askTeamSetup bool
+	var askUninstall bool
 	var askVersion bool
+	var checksFile string
+	var answersFile string
+	var settingsFile string
+	var flagSettings agentSettings
 	flag.StringVar(&dirWork, "dir_work", dirWork, "working directory path")
 	flag.BoolVar(&askConfig, "config", false, "run config")
+	flag.StringVar(&settingsFile, "config_file", "", "config and team setup settings file, instead of asking")
+	flag.StringVar(&flagSettings.ServerURL, "server", "", "config server URL, or "+envServer)
+	flag.StringVar(&flagSettings.ScenarioID, "scenario", "", "config scenario, or "+envScenario)
+	flag.StringVar(&flagSettings.EnrollToken, "enroll_token", "", "config enroll token instead of admin login, or "+envEnrollToken)
+	flag.StringVar(&flagSettings.Role, "role", "", "config scenario host role, instead of matching by hostname, or "+envRole)
+	flag.StringVar(&flagSettings.TeamKey, "team_key", "", "team setup team key, or "+envTeamKey)
+	flag.StringVar(&flagSettings.Proxy, "proxy", "", "HTTP proxy URL for server access, or "+envProxy+", saved by config")
+	flag.StringVar(&flagSettings.CAFile, "ca_file", "", "PEM CA bundle trusted for the server, or "+envCAFile+", saved by config")
+	flag.BoolVar(&askApplyConfig, "apply_config", false, "apply scenario config again to reset the host, with -scenario")
 	flag.BoolVar(&askCopyFiles, "copy_files", false, "copy team files to current directory")
+	flag.BoolVar(&askEnroll, "enroll", false, "add a scenario to a configured agent, with -scenario and -role")
+	flag.BoolVar(&askDryRun, "dry_run", false, "run scenario checks once and print results, without submitting")
+	flag.StringVar(&checksFile, "checks_file", "", "dry run checks file, instead of the server")
+	flag.StringVar(&answersFile, "answers_file", "", "dry run answers file, read locally; the server does not send answers to agents")
 	flag.BoolVar(&askInstall, "install", false, "run install")
+	flag.BoolVar(&askRepair, "repair", false, "re-create service and installed files, keeping config and data")
+	flag.BoolVar(&askStatus, "status", false, "print agent status")
 	flag.BoolVar(&askTeamSetup, "team_setup", false, "team setup")
+	flag.BoolVar(&askUninstall, "uninstall", false, "remove service, installation folder and team files")
 	flag.BoolVar(&askVersion, "version", false, "get version number")
 	flag.Parse()
 
+	settings, err := resolveSettings(flagSettings, os.Getenv, settingsFile)
+	if err != nil {
+		exitWith(exitCodeUsage, "ERROR: invalid settings;", err)
+	}
+
 	// version
 	if askVersion {
 		log.Println("Version: " + version)
@@ -573,6 +817,18 @@ func main() {
 		os.Exit(exitCodeSuccess)
 	}
 
+	// repair
+	if askRepair {
+		repair()
+		os.Exit(exitCodeSuccess)
+	}
+
+	// uninstall
+	if askUninstall {
+		uninstall()
+		os.Exit(exitCodeSuccess)
+	}
+
 	// copy files
 	if askCopyFiles {
 		copyTeamFiles(dirWork)
@@ -583,20 +839,65 @@ func main() {
 	dirData := path.Join(dirWork, "data")
 	dirTemp := path.Join(dirData, "temp")
 	dirResults := path.Join(dirData, "results")
+	dirQuarantine := path.Join(dirData, "quarantine")
 
 	createDir(dirConfig)
 	createDir(dirData)
 	createDir(dirTemp)
 	createDir(dirResults)
+	createDir(dirQuarantine)
+	// checks run as an unprivileged account start in the temp directory
+	os.Chmod(dirData, 0711)
+	os.Chmod(dirTemp, 0711)
+
+	httpClient, err = newHTTPClient(readHTTPSettings(settings, dirConfig))
+	if err != nil {
+		exitWith(exitCodeUsage, "ERROR: invalid proxy or CA settings;", err)
+	}
 
 	hostname, err := os.Hostname()
 	if err != nil {
 		log.Fatalln("ERROR: could not get hostname", err)
 	}
 
+	// status
+	if askStatus {
+		showStatus(os.Stdout, dirConfig, dirData, dirResults, dirQuarantine)
+		os.Exit(exitCodeSuccess)
+	}
+
+	// dry run
+	if askDryRun {
+		var scenarioID uint64
+		role := ""
+		enrollments, _ := readEnrollments(dirConfig, dirData)
+		e, err := selectEnrollment(enrollments, settings.ScenarioID)
+		if err == nil {
+			scenarioID = e.ScenarioID
+			role = e.role()
+		}
+		err = dryRun(os.Stdout, dirConfig, hostname, scenarioID, role, checksFile, answersFile)
+		if err != nil {
+			log.Fatalln("ERROR: dry run failed;", err)
+		}
+		os.Exit(exitCodeSuccess)
+	}
+
 	// config
 	if askConfig {
-		config(dirWork, dirConfig, hostname)
+		config(dirWork, dirConfig, hostname, settings)
+		os.Exit(exitCodeSuccess)
+	}
+
+	// reset host
+	if askApplyConfig {
+		reapplyConfig(dirConfig, dirData, hostname, settings)
+		os.Exit(exitCodeSuccess)
+	}
+
+	// more scenarios
+	if askEnroll {
+		enroll(dirConfig, dirData, hostname, settings)
 		os.Exit(exitCodeSuccess)
 	}
 
@@ -608,15 +909,22 @@ func main() {
 
 	// team setup
 	if askTeamSetup {
-		teamSetup(dirData, serverURL)
+		enrollments, err := readEnrollments(dirConfig, dirData)
+		if err != nil {
+			log.Println("Error reading scenarios;", err)
+			pressEnterBeforeExit(exitCodeFail)
+		}
+		teamSetup(enrollments, serverURL, settings)
 		pressEnterBeforeExit(exitCodeSuccess)
 	}
 
-	scenarioID, err := readScenarioID(dirConfig)
-	if err != nil {
+	enrollments, err := readEnrollments(dirConfig, dirData)
+	if err != nil || len(enrollments) == 0 {
 		log.Fatalln("ERROR: unable to read scenario file;", err)
 	}
-	log.Println("scenario: ", scenarioID)
+	for _, e := range enrollments {
+		log.Println("scenario: ", e.ScenarioID)
+	}
 
 	// get server public key
 	entities, err := readServerPubKey(dirConfig)
@@ -624,62 +932,87 @@ func main() {
 		log.Fatalln("ERROR: could not read server public key; ", err)
 	}
 
+	status := newStatusRecorder(dirData)
+	status.update(func(s *agentStatus) {
+		s.ServerURL = serverURL
+		scenarios := make([]scenarioStatus, 0, len(enrollments))
+		for _, e := range enrollments {
+			scenario := *s.scenario(e.ScenarioID)
+			fillScenarioStatus(&scenario, e)
+			scenarios = append(scenarios, scenario)
+		}
+		s.Scenarios = scenarios
+	})
+
 	var wg sync.WaitGroup
 
-	// run scenario checks
+	// run scenario checks, all scenarios on one schedule
 	wg.Add(1)
 	go func() {
-		nextTime := time.Now()
-		hostToken, _ := readHostToken(dirData)
-		teamKey := ""
-		lastModified := "Thu, 01 Jan 1970 00:00:00 GMT"
-		var checks []model.Action
+		runs := make(map[uint64]*scenarioRun)
 		for {
-			if len(hostToken) == 0 {
-				hostToken, err = requestHostToken(dirData, serverURL, scenarioID, hostname)
-				if err != nil {
-					log.Println("ERROR: could not get host token;", err)
-				} else {
-					log.Println("Saving host token")
-					err = saveFile(dirData, fileNameHostToken, hostToken)
-					if err != nil {
-						log.Println("ERROR: unable to save host token;", err)
-					}
-				}
+			// scenarios enrolled while running are picked up here
+			enrollments, err := readEnrollments(dirConfig, dirData)
+			if err != nil {
+				log.Println("ERROR: unable to read scenarios;", err)
 			}
-			if len(hostToken) > 0 {
-				// make sure team key registered before doing scenario checks
-				if len(teamKey) == 0 {
-					teamKey, _ = readTeamKey(dirData)
-				}
-				if len(teamKey) > 0 {
-					checks2, lastModified2, err := getScenarioChecks(serverURL, scenarioID, hostname, lastModified)
+			for _, e := range enrollments {
+				if _, present := runs[e.ScenarioID]; !present {
+					err = createEnrollmentDirs(e)
 					if err != nil {
-						log.Println("ERROR: unable to get checks;", err)
-					}
-					if checks2 != nil {
-						checks = checks2
-						lastModified = lastModified2
-					}
-					if checks != nil {
-						executeScenarioChecks(scenarioID, hostToken, checks, lastModified, dirResults, dirTemp, entities)
+						log.Println("ERROR: unable to set up scenario directories;", err)
 					}
 				}
 			}
-			nextTime = nextTime.Add(time.Minute)
-			wait := time.Since(nextTime) * -1
-			time.Sleep(wait)
+			next := runScenarios(runs, enrollments, time.Now(), func(r *scenarioRun) {
+				r.run(serverURL, hostname, dirResults, entities, status)
+			})
+			time.Sleep(time.Until(next))
+		}
+	}()
+
+	// self update
+	wg.Add(1)
+	go func() {
+		host, err := getCurrentHost()
+		if err != nil {
+			log.Println("ERROR: could not get current host;", err)
+			return
+		}
+		for {
+			_, err := checkUpdate(serverURL, entities, host)
+			if err != nil {
+				log.Println("ERROR: unable to update agent;", err)
+				status.recordError("unable to update agent", err)
+			}
+			time.Sleep(updateInterval)
 		}
 	}()
 
 	// flush scenario check results
 	wg.Add(1)
 	go func() {
-		nextTime := time.Now()
+		retry := backoff{Min: spoolInterval, Max: spoolBackoffMax}
 		for {
-			executeSubmitScenarioCheckResults(serverURL, dirResults)
-			nextTime = nextTime.Add(5 * time.Second)
-			wait := time.Since(nextTime) * -1
+			wait := spoolInterval
+			waiting := countFiles(dirResults)
+			err := executeSubmitScenarioCheckResults(serverURL, dirResults, dirQuarantine)
+			if err != nil {
+				wait = retry.failure()
+				log.Println("ERROR: unable to send results, retrying in", wait.Round(time.Second), ";", err)
+				status.recordError("unable to send results", err)
+			} else {
+				retry.reset()
+			}
+			if waiting > 0 {
+				status.update(func(s *agentStatus) {
+					if err == nil {
+						s.LastSubmission = time.Now().Unix()
+					}
+					s.SpoolDepth = countFiles(dirResults)
+					s.QuarantineDepth = countFiles(dirQuarantine)
+				})
+			}
 			time.Sleep(wait)
 		}
 	}()
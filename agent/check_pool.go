@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+// checks are mostly waiting on files and commands, not CPU
+var checkWorkers = 4
+
+// results are in the same order as checks
+func executeChecks(checks []model.Action, tempDir string, workers int) []model.CheckResultDetail {
+	if workers < 1 {
+		workers = 1
+	}
+	details := make([]model.CheckResultDetail, len(checks))
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		if check.Serial {
+			wg.Wait()
+			details[i] = executeCheck(check, tempDir)
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, check model.Action) {
+			defer wg.Done()
+			details[i] = executeCheck(check, tempDir)
+			<-slots
+		}(i, check)
+	}
+	wg.Wait()
+	return details
+}
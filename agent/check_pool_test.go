@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func TestExecuteChecks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	defer runAsTestUser()()
+	dir, err := ioutil.TempDir("", "check_pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sh := func(script string, serial bool) model.Action {
+		return model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", script}, Serial: serial}
+	}
+	fp := filepath.Join(dir, "state")
+
+	checks := make([]model.Action, 0)
+	for i := 0; i < 10; i++ {
+		checks = append(checks, sh("sleep 0.0"+strconv.Itoa(9-i)+"; echo "+strconv.Itoa(i), false))
+	}
+	// later checks depend on serial checks before them
+	checks = append(checks, sh("echo written > "+fp, true))
+	checks = append(checks, model.Action{Type: model.ActionTypeFileExist, Args: []string{fp}})
+	checks = append(checks, sh("cat "+fp, false))
+	checks = append(checks, sh("rm "+fp, true))
+	checks = append(checks, model.Action{Type: model.ActionTypeFileExist, Args: []string{fp}})
+
+	for _, workers := range []int{0, 1, 4} {
+		details := executeChecks(checks, dir, workers)
+		if len(details) != len(checks) {
+			t.Fatal("Unexpected result count", len(details))
+		}
+		for i := 0; i < 10; i++ {
+			if details[i].Result != strconv.Itoa(i) {
+				t.Fatal("Unexpected result order", workers, i, details[i].Result)
+			}
+		}
+		if details[11].Result != "true" || details[12].Result != "written" || details[14].Result != "false" {
+			t.Fatal("Serial check overlapped", workers, details[11:])
+		}
+	}
+}
+
+func benchmarkExecuteChecks(b *testing.B, workers int) {
+	if runtime.GOOS == "windows" {
+		b.Skip("uses /bin/sh")
+	}
+	checks := make([]model.Action, 16)
+	for i := range checks {
+		checks[i] = model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", "sleep 0.01"}}
+	}
+	dir := os.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		executeChecks(checks, dir, workers)
+	}
+}
+
+func BenchmarkExecuteChecksSerial(b *testing.B) {
+	benchmarkExecuteChecks(b, 1)
+}
+
+func BenchmarkExecuteChecksParallel(b *testing.B) {
+	benchmarkExecuteChecks(b, checkWorkers)
+}
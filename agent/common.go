@@ -1,13 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 func copyFile(srcPath string, dstPath string) {
@@ -27,6 +30,102 @@ func copyFile(srcPath string, dstPath string) {
 	}
 }
 
+// the running executable is left alone if it is already the installed copy,
+// copying a file over itself would empty it
+func copyAgent(binFile string) error {
+	ex, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exInfo, err := os.Stat(ex)
+	if err != nil {
+		return err
+	}
+	binInfo, err := os.Stat(binFile)
+	if err == nil && os.SameFile(exInfo, binInfo) {
+		return nil
+	}
+	copyFile(ex, binFile)
+	return os.Chmod(binFile, 0755)
+}
+
+// directories copy_files put team files in, one per line
+const fileNameTeamFileCopies = "team_file_copies"
+
+// kept in the installation folder, so uninstall finds copies wherever it runs
+// from
+func recordTeamFileCopies(installPath string, dir string) error {
+	for _, recorded := range readTeamFileCopies(installPath) {
+		if recorded == dir {
+			return nil
+		}
+	}
+	f, err := os.OpenFile(filepath.Join(installPath, fileNameTeamFileCopies), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(dir + "\n")
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readTeamFileCopies(installPath string) []string {
+	bs, err := ioutil.ReadFile(filepath.Join(installPath, fileNameTeamFileCopies))
+	if err != nil {
+		return nil
+	}
+	dirs := make([]string, 0)
+	for _, line := range strings.Split(string(bs), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs
+}
+
+// looks in the recorded directories, the current directory and the known
+// locations, for copies made before they were recorded or by an account that
+// could not record them; only copies made by copy_files are removed, same
+// names with other content are kept
+func removeTeamFileCopies(installPath string, fileNames []string, knownDirs []string) {
+	dirs := readTeamFileCopies(installPath)
+	if currentDir, err := os.Getwd(); err == nil {
+		dirs = append(dirs, currentDir)
+	}
+	for _, pattern := range knownDirs {
+		matches, err := filepath.Glob(pattern)
+		if err == nil {
+			dirs = append(dirs, matches...)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if seen[dir] || dir == filepath.Clean(installPath) {
+			continue
+		}
+		seen[dir] = true
+		for _, fileName := range fileNames {
+			installed, err := ioutil.ReadFile(filepath.Join(installPath, fileName))
+			if err != nil {
+				continue
+			}
+			copyPath := filepath.Join(dir, fileName)
+			copied, err := ioutil.ReadFile(copyPath)
+			if err != nil || !bytes.Equal(installed, copied) {
+				continue
+			}
+			log.Println("Removing " + copyPath)
+			os.Remove(copyPath)
+		}
+	}
+}
+
 func createDir(dir string) {
 	// data directory
 	err := os.MkdirAll(dir, 0700)
@@ -49,7 +148,13 @@ func writeReadmeHTML(dir string, serverURL string) error {
 	outFile := path.Join(dir, "README.html")
 	log.Println("Creating " + outFile)
 	url := serverURL + "/ui/team-dashboard"
-	s := "<html><head><meta http-equiv=\"refresh\" content=\"0; url=" + url + "\"></head><body><a href=\"" + url + "\">Team Dashboard</a></body></html>"
+	// copies of this page elsewhere still find the status of this install
+	statusURL := "file:///" + strings.TrimPrefix(filepath.ToSlash(filepath.Join(dir, "data", fileNameStatusScript)), "/")
+	s := "<html><head><title>Team Dashboard</title></head><body><a href=\"" + url + "\">Team Dashboard</a>" +
+		"<h3>Agent status</h3><pre id=\"status\">No status saved, the agent service has not run yet</pre>" +
+		"<script src=\"" + statusURL + "\"></script>" +
+		"<script>if (typeof cpScoringStatus !== \"undefined\") { document.getElementById(\"status\").textContent = JSON.stringify(cpScoringStatus, null, 2); }</script>" +
+		"</body></html>"
 	err := ioutil.WriteFile(outFile, []byte(s), 0644)
 	if err != nil {
 		log.Println("ERROR: unable to save " + outFile)
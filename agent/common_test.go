@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveTeamFileCopies(t *testing.T) {
+	installPath, err := ioutil.TempDir("", "cp-scoring-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(installPath)
+	currentDir, err := ioutil.TempDir("", "cp-scoring-cwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(currentDir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(currentDir)
+
+	ioutil.WriteFile(filepath.Join(installPath, "same"), []byte("team"), 0644)
+	ioutil.WriteFile(filepath.Join(currentDir, "same"), []byte("team"), 0644)
+	ioutil.WriteFile(filepath.Join(installPath, "changed"), []byte("team"), 0644)
+	ioutil.WriteFile(filepath.Join(currentDir, "changed"), []byte("mine"), 0644)
+	ioutil.WriteFile(filepath.Join(currentDir, "other"), []byte("team"), 0644)
+
+	removeTeamFileCopies(installPath, []string{"same", "changed", "other"}, nil)
+
+	if _, err := os.Stat(filepath.Join(currentDir, "same")); !os.IsNotExist(err) {
+		t.Fatal("Expected copy to be removed", err)
+	}
+	if _, err := os.Stat(filepath.Join(currentDir, "changed")); err != nil {
+		t.Fatal("Expected changed file to be kept", err)
+	}
+	if _, err := os.Stat(filepath.Join(currentDir, "other")); err != nil {
+		t.Fatal("Expected file not installed to be kept", err)
+	}
+	if _, err := os.Stat(filepath.Join(installPath, "same")); err != nil {
+		t.Fatal("Expected installed file to be kept", err)
+	}
+}
+
+func TestRemoveTeamFileCopiesElsewhere(t *testing.T) {
+	installPath, err := ioutil.TempDir("", "cp-scoring-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(installPath)
+	home, err := ioutil.TempDir("", "cp-scoring-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	recorded := filepath.Join(home, "team1")
+	desktop := filepath.Join(home, "team2", "Desktop")
+	os.MkdirAll(recorded, 0755)
+	os.MkdirAll(desktop, 0755)
+	ioutil.WriteFile(filepath.Join(installPath, "same"), []byte("team"), 0644)
+	ioutil.WriteFile(filepath.Join(recorded, "same"), []byte("team"), 0644)
+	ioutil.WriteFile(filepath.Join(desktop, "same"), []byte("team"), 0644)
+
+	// uninstall runs from the installation folder
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(installPath)
+
+	err = recordTeamFileCopies(installPath, recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordTeamFileCopies(installPath, recorded)
+	if dirs := readTeamFileCopies(installPath); len(dirs) != 1 || dirs[0] != recorded {
+		t.Fatal("Unexpected recorded directories", dirs)
+	}
+
+	removeTeamFileCopies(installPath, []string{"same"}, []string{filepath.Join(home, "*", "Desktop")})
+
+	if _, err := os.Stat(filepath.Join(recorded, "same")); !os.IsNotExist(err) {
+		t.Fatal("Expected recorded copy to be removed", err)
+	}
+	if _, err := os.Stat(filepath.Join(desktop, "same")); !os.IsNotExist(err) {
+		t.Fatal("Expected desktop copy to be removed", err)
+	}
+	if _, err := os.Stat(filepath.Join(installPath, "same")); err != nil {
+		t.Fatal("Expected installed file to be kept", err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+const compositeMaxDepth int = 8
+
+// result of a composite check run by the agent, the server scores it
+const compositeEvaluated string = "evaluated"
+
+// args: all_of, any_of or none_of; children are check.Checks, all run without
+// short-circuiting, as only the server has the answers that decide which
+// children pass; the server scores them with processing.CompositeResult
+func checkComposite(check model.Action, tempDir string, depth int) (string, []model.CheckResultDetail) {
+	if len(check.Args) != 1 {
+		return "invalid arguments", nil
+	}
+	mode := check.Args[0]
+	if mode != model.CompositeAllOf && mode != model.CompositeAnyOf && mode != model.CompositeNoneOf {
+		return "invalid arguments", nil
+	}
+	if depth >= compositeMaxDepth {
+		return "max depth exceeded", nil
+	}
+
+	details := make([]model.CheckResultDetail, len(check.Checks))
+	for i, child := range check.Checks {
+		if child.Type == model.ActionTypeComposite {
+			details[i] = model.CheckResultDetail{Description: child.Description}
+			details[i].Result, details[i].Children = checkComposite(child, tempDir, depth+1)
+		} else {
+			details[i] = executeCheck(child, tempDir)
+		}
+	}
+	return compositeEvaluated, details
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func TestCheckComposite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "composite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	present := filepath.Join(dir, "present")
+	ioutil.WriteFile(present, []byte("PermitRootLogin no\n"), 0644)
+	exists := model.Action{Type: model.ActionTypeFileExist, Description: "exists", Args: []string{present}}
+	missing := model.Action{Type: model.ActionTypeFileExist, Description: "missing", Args: []string{filepath.Join(dir, "missing")}}
+	rootLogin := model.Action{Type: model.ActionTypeConfigValue, Description: "root login", Args: []string{present, "sshd", "PermitRootLogin"}}
+
+	// agents are sent children without answers
+	composite := func(mode string, checks ...model.Action) model.Action {
+		return model.Action{Type: model.ActionTypeComposite, Description: mode, Args: []string{mode}, Checks: checks}
+	}
+
+	tests := []struct {
+		check    model.Action
+		expected string
+	}{
+		{composite(model.CompositeAllOf, exists, rootLogin), compositeEvaluated},
+		{composite(model.CompositeAnyOf), compositeEvaluated},
+		{composite(model.CompositeNoneOf, missing), compositeEvaluated},
+		{composite("xor", exists), "invalid arguments"},
+		{model.Action{Type: model.ActionTypeComposite, Checks: []model.Action{exists}}, "invalid arguments"},
+	}
+	for i, test := range tests {
+		result := executeCheck(test.check, dir).Result
+		if result != test.expected {
+			t.Fatal("Unexpected result for test", i, result)
+		}
+	}
+
+	// every child runs, nested details included
+	detail := executeCheck(composite(model.CompositeAllOf, missing, rootLogin, composite(model.CompositeAnyOf, exists)), dir)
+	details := detail.Children
+	if len(details) != 3 {
+		t.Fatal("Unexpected details", details)
+	}
+	if details[0].Description != "missing" || details[0].Result != "false" || details[0].Passed {
+		t.Fatal("Unexpected detail", details[0])
+	}
+	if details[1].Result != "no" {
+		t.Fatal("Expected child after failed child to run", details[1])
+	}
+	if details[2].Result != compositeEvaluated || len(details[2].Children) != 1 || details[2].Children[0].Result != "true" {
+		t.Fatal("Unexpected nested details", details[2])
+	}
+
+	// nesting is bounded
+	deep := composite(model.CompositeAllOf, exists)
+	for i := 0; i < compositeMaxDepth; i++ {
+		deep = composite(model.CompositeAllOf, deep)
+	}
+	detail = executeCheck(deep, dir)
+	for detail.Result == compositeEvaluated {
+		detail = detail.Children[0]
+	}
+	if detail.Result != "max depth exceeded" {
+		t.Fatal("Expected too deep composite to fail", detail.Result)
+	}
+
+	detail = executeCheck(exists, dir)
+	if detail.Result != "true" || detail.Children != nil || detail.Description != "exists" {
+		t.Fatal("Unexpected detail for simple check", detail)
+	}
+}
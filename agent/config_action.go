@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+// package installs download, so allowed longer than EXEC checks
+const configCommandTimeout = 10 * time.Minute
+
+// combined output, config actions log it; bounded and killed with its
+// process group like configExec
+var configCommandOutput = func(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), configCommandTimeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if name == "apt-get" {
+		// no debconf prompts, there is no one to answer them
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	}
+	setProcessGroup(cmd)
+	err := runWithContext(ctx, cmd)
+	if ctx.Err() == context.DeadlineExceeded {
+		return output.Bytes(), errors.New("timed out")
+	}
+	return output.Bytes(), err
+}
+
+var configLookPath = exec.LookPath
+
+// what a config action did; actions other than EXEC only change what is not
+// already in place, so config can be applied again to reset a host
+type configResult struct {
+	Description string
+	Type        model.ActionType
+	Changed     bool
+	Message     string
+	Error       string
+}
+
+func applyConfig(config []model.Action) []configResult {
+	results := make([]configResult, 0, len(config))
+	for _, action := range config {
+		log.Println(" - ", action.Type, ": ", action.Command, "[", strings.Join(action.Args, ","), "]")
+		result := configResult{Description: action.Description, Type: action.Type}
+		changed, message, err := applyConfigAction(action)
+		result.Changed = changed
+		result.Message = message
+		if err != nil {
+			result.Error = err.Error()
+			log.Println("Unable to execute config action;", err)
+		} else if changed {
+			log.Println("   changed: " + message)
+		} else {
+			log.Println("   unchanged: " + message)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func summarizeConfigResults(results []configResult) string {
+	changed := 0
+	failed := 0
+	for _, result := range results {
+		if len(result.Error) > 0 {
+			failed++
+		} else if result.Changed {
+			changed++
+		}
+	}
+	return fmt.Sprintf("%d changed, %d unchanged, %d failed", changed, len(results)-changed-failed, failed)
+}
+
+func applyConfigAction(action model.Action) (bool, string, error) {
+	if action.Type == model.ActionTypeExec {
+		return configExec(action)
+	} else if action.Type == model.ActionTypeFileWrite {
+		return configFileWrite(action.Args)
+	} else if action.Type == model.ActionTypeUserCreate {
+		return configUserCreate(action.Args)
+	} else if action.Type == model.ActionTypeGroupAddMember {
+		return configGroupAddMember(action.Args)
+	} else if action.Type == model.ActionTypePackageInstall {
+		return configPackageInstall(action.Args)
+	} else if action.Type == model.ActionTypeServiceEnable {
+		return configServiceEnable(action.Args)
+	}
+	return false, "", errors.New("unsupported config action " + string(action.Type))
+}
+
+// always runs, commands are expected to be safe to repeat; bounded like EXEC
+// checks, so a hung command cannot stall the agent
+func configExec(action model.Action) (bool, string, error) {
+	if len(action.Command) == 0 {
+		return false, "no command", nil
+	}
+	timeout := execDefaultTimeout
+	if action.Timeout > 0 {
+		timeout = time.Duration(action.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// one writer, so stdout and stderr lines keep their order
+	var output bytes.Buffer
+	cmd := exec.Command(action.Command, action.Args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	setProcessGroup(cmd)
+	err := runWithContext(ctx, cmd)
+	if output.Len() > 0 {
+		log.Println(strings.TrimSpace(output.String()))
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return true, "ran " + action.Command, errors.New("timed out")
+	}
+	if err != nil {
+		return true, "ran " + action.Command, err
+	}
+	return true, "ran " + action.Command, nil
+}
+
+// args: path, content, optional octal mode, optional owner as user or
+// user:group
+func configFileWrite(args []string) (bool, string, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return false, "", errors.New("invalid arguments")
+	}
+	filePath := args[0]
+	content := []byte(args[1])
+	mode := os.FileMode(0644)
+	if len(args) > 2 && len(args[2]) > 0 {
+		m, err := strconv.ParseUint(args[2], 8, 32)
+		if err != nil || m > 07777 {
+			return false, "", errors.New("invalid mode " + args[2])
+		}
+		mode = os.FileMode(m)
+	}
+	uid, gid := -1, -1
+	if len(args) > 3 && len(args[3]) > 0 {
+		var err error
+		uid, gid, err = lookupOwner(args[3])
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	// write through symlinks such as /etc/resolv.conf, replacing the link
+	// would leave a plain file behind it
+	if info, err := os.Lstat(filePath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(filePath)
+		if err != nil {
+			return false, "", errors.New("could not resolve symlink " + filePath)
+		}
+		filePath = target
+	}
+
+	changes := make([]string, 0)
+	info, err := os.Stat(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, "", err
+	}
+	current, _ := ioutil.ReadFile(filePath)
+	if err != nil || !bytes.Equal(current, content) {
+		previous := info
+		err = os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			return false, "", err
+		}
+		err = writeFileAtomic(filePath, content, mode)
+		if err != nil {
+			return false, "", err
+		}
+		changes = append(changes, "content")
+		info, err = os.Stat(filePath)
+		if err != nil {
+			return true, "", err
+		}
+		// the replacement is owned by the agent, keep the owner it replaced
+		if previous != nil && uid < 0 {
+			previousUID, previousGID, ok := fileOwnerIDs(previous)
+			currentUID, currentGID, _ := fileOwnerIDs(info)
+			if ok && (previousUID != currentUID || previousGID != currentGID) {
+				err = os.Chown(filePath, int(previousUID), int(previousGID))
+				if err != nil {
+					return true, "", err
+				}
+			}
+		}
+	}
+	// windows only has the read-only bit
+	if runtime.GOOS != "windows" && info.Mode().Perm()|info.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != fileModeFromUnix(mode) {
+		err = os.Chmod(filePath, fileModeFromUnix(mode))
+		if err != nil {
+			return len(changes) > 0, "", err
+		}
+		changes = append(changes, "mode")
+	}
+	if uid >= 0 {
+		currentUID, currentGID, ok := fileOwnerIDs(info)
+		if !ok || int(currentUID) != uid || (gid >= 0 && int(currentGID) != gid) {
+			err = os.Chown(filePath, uid, gid)
+			if err != nil {
+				return len(changes) > 0, "", err
+			}
+			changes = append(changes, "owner")
+		}
+	}
+
+	if len(changes) == 0 {
+		return false, filePath + " already as configured", nil
+	}
+	return true, filePath + " " + strings.Join(changes, ", ") + " set", nil
+}
+
+// octal permission bits to os.FileMode, including setuid, setgid and sticky
+func fileModeFromUnix(mode os.FileMode) os.FileMode {
+	m := mode & os.ModePerm
+	if mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+func lookupOwner(owner string) (int, int, error) {
+	if runtime.GOOS == "windows" {
+		return -1, -1, errors.New("file owner not supported on windows")
+	}
+	tokens := strings.SplitN(owner, ":", 2)
+	u, err := user.Lookup(tokens[0])
+	if err != nil {
+		return -1, -1, err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return -1, -1, err
+	}
+	gid := -1
+	if len(tokens) == 2 && len(tokens[1]) > 0 {
+		g, err := user.LookupGroup(tokens[1])
+		if err != nil {
+			return -1, -1, err
+		}
+		gid, err = strconv.Atoi(g.Gid)
+		if err != nil {
+			return -1, -1, err
+		}
+	}
+	return uid, gid, nil
+}
+
+// args: user name
+func configUserCreate(args []string) (bool, string, error) {
+	if len(args) != 1 || len(args[0]) == 0 {
+		return false, "", errors.New("invalid arguments")
+	}
+	name := args[0]
+	if runtime.GOOS == "windows" {
+		_, err := configCommandOutput("net", "user", name)
+		if err == nil {
+			return false, "user " + name + " exists", nil
+		}
+		return configCommand("user "+name+" created", "net", "user", name, "/add")
+	}
+	u, _, err := readUserGroups(name)
+	if err != nil {
+		return false, "", err
+	}
+	if len(u.UID) > 0 {
+		return false, "user " + name + " exists", nil
+	}
+	return configCommand("user "+name+" created", "useradd", "--create-home", name)
+}
+
+// args: group, user
+func configGroupAddMember(args []string) (bool, string, error) {
+	if len(args) != 2 || len(args[0]) == 0 || len(args[1]) == 0 {
+		return false, "", errors.New("invalid arguments")
+	}
+	group := args[0]
+	name := args[1]
+	if runtime.GOOS == "windows" {
+		out, err := configCommandOutput("net", "localgroup", group)
+		if err != nil {
+			return false, "", errors.New("group " + group + " not found")
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			member := strings.TrimSpace(line)
+			if strings.EqualFold(member, name) || strings.HasSuffix(strings.ToLower(member), "\\"+strings.ToLower(name)) {
+				return false, name + " already in " + group, nil
+			}
+		}
+		return configCommand(name+" added to "+group, "net", "localgroup", group, name, "/add")
+	}
+	u, groups, err := readUserGroups(name)
+	if err != nil {
+		return false, "", err
+	}
+	if len(u.UID) == 0 {
+		return false, "", errors.New("user " + name + " not found")
+	}
+	for _, g := range groups {
+		if g.Name == group {
+			return false, name + " already in " + group, nil
+		}
+	}
+	return configCommand(name+" added to "+group, "usermod", "--append", "--groups", group, name)
+}
+
+// args: package names, installed with the first package manager found
+func configPackageInstall(args []string) (bool, string, error) {
+	if len(args) == 0 {
+		return false, "", errors.New("invalid arguments")
+	}
+	if runtime.GOOS == "windows" {
+		return false, "", errors.New("package install not supported on windows")
+	}
+	missing := make([]string, 0)
+	for _, pkg := range args {
+		if !packageInstalled(pkg) {
+			missing = append(missing, pkg)
+		}
+	}
+	if len(missing) == 0 {
+		return false, strings.Join(args, ", ") + " already installed", nil
+	}
+	message := strings.Join(missing, ", ") + " installed"
+	if _, err := configLookPath("apt-get"); err == nil {
+		return configCommand(message, "apt-get", append([]string{"install", "-y"}, missing...)...)
+	}
+	for _, manager := range []string{"dnf", "yum", "zypper"} {
+		if _, err := configLookPath(manager); err == nil {
+			return configCommand(message, manager, append([]string{"install", "-y"}, missing...)...)
+		}
+	}
+	return false, "", errors.New("no supported package manager")
+}
+
+func packageInstalled(pkg string) bool {
+	if _, err := configLookPath("dpkg-query"); err == nil {
+		out, err := configCommandOutput("dpkg-query", "-W", "-f=${Status}", pkg)
+		return err == nil && strings.Contains(string(out), "install ok installed")
+	}
+	if _, err := configLookPath("rpm"); err == nil {
+		_, err := configCommandOutput("rpm", "-q", pkg)
+		return err == nil
+	}
+	return false
+}
+
+// args: service, enabled at boot and started
+func configServiceEnable(args []string) (bool, string, error) {
+	if len(args) != 1 || len(args[0]) == 0 {
+		return false, "", errors.New("invalid arguments")
+	}
+	service := args[0]
+	if runtime.GOOS == "windows" {
+		out, err := configCommandOutput("sc", "qc", service)
+		if err != nil {
+			return false, "", errors.New("service " + service + " not found")
+		}
+		auto := strings.Contains(string(out), "AUTO_START")
+		out, _ = configCommandOutput("sc", "query", service)
+		running := strings.Contains(string(out), "RUNNING")
+		if auto && running {
+			return false, service + " already enabled", nil
+		}
+		if !auto {
+			_, _, err := configCommand("", "sc", "config", service, "start=", "auto")
+			if err != nil {
+				return false, "", err
+			}
+		}
+		if !running {
+			_, _, err := configCommand("", "sc", "start", service)
+			if err != nil {
+				return true, "", err
+			}
+		}
+		return true, service + " enabled", nil
+	}
+	unit := service
+	if !strings.Contains(unit, ".") {
+		unit += ".service"
+	}
+	out, err := configCommandOutput("systemctl", "show", unit, "--property=ActiveState,LoadState,UnitFileState")
+	if err != nil {
+		return false, "", err
+	}
+	props := parseSystemctlShow(out)
+	if props["LoadState"] == "not-found" {
+		return false, "", errors.New("service " + service + " not found")
+	}
+	if props["UnitFileState"] == "enabled" && props["ActiveState"] == "active" {
+		return false, service + " already enabled", nil
+	}
+	return configCommand(service+" enabled", "systemctl", "enable", "--now", unit)
+}
+
+func configCommand(message string, name string, args ...string) (bool, string, error) {
+	out, err := configCommandOutput(name, args...)
+	if len(out) > 0 {
+		log.Println(strings.TrimSpace(string(out)))
+	}
+	if err != nil {
+		return false, "", errors.New(name + " failed; " + err.Error())
+	}
+	return true, message, nil
+}
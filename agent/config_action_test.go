@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+// records commands, answers from outputs keyed by the joined command line
+func fakeConfigCommands(outputs map[string]string, failures map[string]bool) *[]string {
+	commands := make([]string, 0)
+	configCommandOutput = func(name string, args ...string) ([]byte, error) {
+		command := strings.Join(append([]string{name}, args...), " ")
+		commands = append(commands, command)
+		if failures[command] {
+			return []byte(outputs[command]), errors.New("exit status 1")
+		}
+		return []byte(outputs[command]), nil
+	}
+	return &commands
+}
+
+func TestConfigFileWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-action")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "sub", "motd")
+
+	changed, _, err := configFileWrite([]string{file, "hello\n", "0600"})
+	if err != nil || !changed {
+		t.Fatal("Expected file written", err)
+	}
+	bs, _ := ioutil.ReadFile(file)
+	if string(bs) != "hello\n" {
+		t.Fatal("Unexpected content", string(bs))
+	}
+	changed, _, err = configFileWrite([]string{file, "hello\n", "0600"})
+	if err != nil || changed {
+		t.Fatal("Expected no change", err)
+	}
+
+	ioutil.WriteFile(file, []byte("changed by team\n"), 0600)
+	changed, message, err := configFileWrite([]string{file, "hello\n", "0600"})
+	if err != nil || !changed || !strings.Contains(message, "content") {
+		t.Fatal("Expected content reset", message, err)
+	}
+
+	if runtime.GOOS != "windows" {
+		os.Chmod(file, 0644)
+		changed, message, err = configFileWrite([]string{file, "hello\n", "0600"})
+		if err != nil || !changed || message != file+" mode set" {
+			t.Fatal("Expected mode reset", message, err)
+		}
+		info, _ := os.Stat(file)
+		if info.Mode().Perm() != 0600 {
+			t.Fatal("Unexpected mode", info.Mode())
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		// written through, the link stays
+		link := filepath.Join(dir, "resolv.conf")
+		os.Symlink(file, link)
+		changed, _, err = configFileWrite([]string{link, "nameserver 127.0.0.1\n", "0600"})
+		if err != nil || !changed {
+			t.Fatal("Expected file written through link", err)
+		}
+		info, _ := os.Lstat(link)
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Fatal("Expected link kept", info.Mode())
+		}
+		bs, _ = ioutil.ReadFile(file)
+		if string(bs) != "nameserver 127.0.0.1\n" {
+			t.Fatal("Unexpected content", string(bs))
+		}
+		os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken"))
+		_, _, err = configFileWrite([]string{filepath.Join(dir, "broken"), "hello\n"})
+		if err == nil {
+			t.Fatal("Expected error for broken link")
+		}
+	}
+
+	if runtime.GOOS != "windows" && os.Geteuid() == 0 {
+		os.Chown(file, 65534, 65534)
+		changed, _, err = configFileWrite([]string{file, "hello again\n", "0600"})
+		if err != nil || !changed {
+			t.Fatal("Expected file written", err)
+		}
+		info, _ := os.Stat(file)
+		uid, gid, _ := fileOwnerIDs(info)
+		if uid != 65534 || gid != 65534 {
+			t.Fatal("Expected owner kept", uid, gid)
+		}
+	}
+
+	for _, args := range [][]string{{file}, {file, "", "999"}, {file, "", "0644", "missing-user-cp-scoring"}} {
+		_, _, err = configFileWrite(args)
+		if err == nil {
+			t.Fatal("Expected error for", args)
+		}
+	}
+}
+
+func TestConfigUserCreate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("reads /etc/passwd")
+	}
+	dir, err := ioutil.TempDir("", "config-action")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(passwd string, group string, output func(string, ...string) ([]byte, error)) {
+		etcPasswdFile, etcGroupFile, configCommandOutput = passwd, group, output
+	}(etcPasswdFile, etcGroupFile, configCommandOutput)
+	etcPasswdFile = filepath.Join(dir, "passwd")
+	etcGroupFile = filepath.Join(dir, "group")
+	ioutil.WriteFile(etcPasswdFile, []byte("root:x:0:0::/root:/bin/sh\nalice:x:1000:1000::/home/alice:/bin/sh\n"), 0644)
+	ioutil.WriteFile(etcGroupFile, []byte("root:x:0:\nalice:x:1000:\nsudo:x:27:alice\nadm:x:4:\n"), 0644)
+	commands := fakeConfigCommands(nil, nil)
+
+	changed, _, err := configUserCreate([]string{"alice"})
+	if err != nil || changed {
+		t.Fatal("Expected existing user unchanged", err)
+	}
+	changed, _, err = configUserCreate([]string{"bob"})
+	if err != nil || !changed {
+		t.Fatal("Expected user created", err)
+	}
+	changed, _, err = configGroupAddMember([]string{"sudo", "alice"})
+	if err != nil || changed {
+		t.Fatal("Expected existing member unchanged", err)
+	}
+	changed, _, err = configGroupAddMember([]string{"adm", "alice"})
+	if err != nil || !changed {
+		t.Fatal("Expected member added", err)
+	}
+	_, _, err = configGroupAddMember([]string{"adm", "carol"})
+	if err == nil {
+		t.Fatal("Expected missing user error")
+	}
+
+	expected := []string{"useradd --create-home bob", "usermod --append --groups adm alice"}
+	if strings.Join(*commands, "|") != strings.Join(expected, "|") {
+		t.Fatal("Unexpected commands", *commands)
+	}
+}
+
+func TestConfigServiceEnable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses systemctl")
+	}
+	defer func(output func(string, ...string) ([]byte, error)) {
+		configCommandOutput = output
+	}(configCommandOutput)
+	show := " --property=ActiveState,LoadState,UnitFileState"
+	commands := fakeConfigCommands(map[string]string{
+		"systemctl show ssh.service" + show:    "ActiveState=active\nLoadState=loaded\nUnitFileState=enabled\n",
+		"systemctl show cron.service" + show:   "ActiveState=inactive\nLoadState=loaded\nUnitFileState=disabled\n",
+		"systemctl show nope.service" + show:   "ActiveState=inactive\nLoadState=not-found\nUnitFileState=\n",
+		"systemctl show broken.service" + show: "ActiveState=inactive\nLoadState=loaded\nUnitFileState=disabled\n",
+	}, map[string]bool{"systemctl enable --now broken.service": true})
+
+	changed, _, err := configServiceEnable([]string{"ssh"})
+	if err != nil || changed {
+		t.Fatal("Expected enabled service unchanged", err)
+	}
+	changed, _, err = configServiceEnable([]string{"cron"})
+	if err != nil || !changed {
+		t.Fatal("Expected service enabled", err)
+	}
+	_, _, err = configServiceEnable([]string{"nope"})
+	if err == nil {
+		t.Fatal("Expected missing service error")
+	}
+	changed, _, err = configServiceEnable([]string{"broken"})
+	if err == nil || changed {
+		t.Fatal("Expected enable failure")
+	}
+	if (*commands)[2] != "systemctl enable --now cron.service" {
+		t.Fatal("Unexpected commands", *commands)
+	}
+}
+
+func TestConfigPackageInstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no package manager")
+	}
+	defer func(output func(string, ...string) ([]byte, error), lookPath func(string) (string, error)) {
+		configCommandOutput, configLookPath = output, lookPath
+	}(configCommandOutput, configLookPath)
+	configLookPath = func(file string) (string, error) {
+		if file == "dpkg-query" || file == "apt-get" {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+	commands := fakeConfigCommands(map[string]string{
+		"dpkg-query -W -f=${Status} vsftpd": "install ok installed",
+	}, map[string]bool{"dpkg-query -W -f=${Status} telnetd": true})
+
+	changed, _, err := configPackageInstall([]string{"vsftpd"})
+	if err != nil || changed {
+		t.Fatal("Expected installed package unchanged", err)
+	}
+	changed, message, err := configPackageInstall([]string{"vsftpd", "telnetd"})
+	if err != nil || !changed || message != "telnetd installed" {
+		t.Fatal("Expected package installed", message, err)
+	}
+	last := (*commands)[len(*commands)-1]
+	if last != "apt-get install -y telnetd" {
+		t.Fatal("Unexpected install command", last)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-action")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "flag")
+
+	config := []model.Action{
+		{Type: model.ActionTypeFileWrite, Args: []string{file, "vulnerable"}},
+		{Type: model.ActionTypeFileWrite, Args: []string{file, "vulnerable"}},
+		{Type: model.ActionTypeFileExist, Args: []string{file}},
+	}
+	results := applyConfig(config)
+	if len(results) != 3 || !results[0].Changed || results[1].Changed || len(results[2].Error) == 0 {
+		t.Fatal("Unexpected results", results)
+	}
+	if summary := summarizeConfigResults(results); summary != "1 changed, 1 unchanged, 1 failed" {
+		t.Fatal("Unexpected summary", summary)
+	}
+}
+
+func TestConfigExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	changed, _, err := configExec(model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", "echo out; echo err >&2"}})
+	if err != nil || !changed {
+		t.Fatal("Expected command run", err)
+	}
+	_, _, err = configExec(model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", "exit 3"}})
+	if err == nil {
+		t.Fatal("Expected command failure")
+	}
+
+	// a child holding the output open is killed with the command
+	start := time.Now()
+	_, _, err = configExec(model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", "sleep 30 & sleep 30"}, Timeout: 1})
+	if err == nil || err.Error() != "timed out" {
+		t.Fatal("Expected timeout", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("Expected process group killed", time.Since(start))
+	}
+}
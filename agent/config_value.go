@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configDialectINI string = "ini"
+const configDialectJSON string = "json"
+const configDialectKeyValue string = "keyvalue"
+const configDialectSSHD string = "sshd"
+const configDialectSudoers string = "sudoers"
+const configDialectYAML string = "yaml"
+const configIncludeMaxDepth int = 8
+const configSSHDDir string = "/etc/ssh"
+const configValueNotFound string = "not found"
+
+var errConfigIncludeDepth = errors.New("include depth exceeded")
+
+// args: file path, dialect, key, section (ini only)
+func checkConfigValue(args []string) string {
+	if len(args) < 3 {
+		return "invalid arguments"
+	}
+	fp, dialect, key := args[0], args[1], args[2]
+
+	var value string
+	var found bool
+	var err error
+	switch dialect {
+	case configDialectSSHD:
+		value, found, err = configValueSSHD(fp, key)
+	case configDialectSudoers:
+		value, found, err = configValueSudoers(fp, key)
+	case configDialectKeyValue, configDialectINI, configDialectJSON, configDialectYAML:
+		var bs []byte
+		bs, err = ioutil.ReadFile(fp)
+		if err != nil {
+			break
+		}
+		if dialect == configDialectKeyValue {
+			value, found = configValueKeyValue(bs, key)
+		} else if dialect == configDialectINI {
+			section := ""
+			if len(args) > 3 {
+				section = args[3]
+			}
+			value, found = configValueINI(bs, section, key)
+		} else if dialect == configDialectJSON {
+			value, found, err = configValueJSON(bs, key)
+		} else {
+			value, found, err = configValueYAML(bs, key)
+		}
+	default:
+		return "invalid dialect"
+	}
+	if err != nil {
+		return "could not read file"
+	}
+	if !found {
+		return configValueNotFound
+	}
+	return value
+}
+
+// sshd_config: keywords are case insensitive, first obtained value wins,
+// Match blocks are conditional and not part of the effective global value
+func configValueSSHD(fp string, key string) (string, bool, error) {
+	lines, err := readSSHDLines(fp, 0)
+	if err != nil {
+		return "", false, err
+	}
+	for _, line := range lines {
+		keyword, value := splitSSHDLine(line)
+		if strings.EqualFold(keyword, key) {
+			return value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func readSSHDLines(fp string, depth int) ([]string, error) {
+	if depth > configIncludeMaxDepth {
+		return nil, errConfigIncludeDepth
+	}
+	bs, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0)
+	inMatch := false
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyword, value := splitSSHDLine(line)
+		if strings.EqualFold(keyword, "Match") {
+			inMatch = !strings.EqualFold(value, "all")
+			continue
+		}
+		if inMatch {
+			continue
+		}
+		if strings.EqualFold(keyword, "Include") {
+			for _, pattern := range strings.Fields(value) {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(configSSHDDir, pattern)
+				}
+				// no match is not an error
+				includes, _ := filepath.Glob(pattern)
+				sort.Strings(includes)
+				for _, include := range includes {
+					included, err := readSSHDLines(include, depth+1)
+					if err != nil {
+						return nil, err
+					}
+					lines = append(lines, included...)
+				}
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+func splitSSHDLine(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, ""
+	}
+	keyword := line[:i]
+	value := strings.TrimLeft(line[i:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	value = strings.Join(strings.Fields(value), " ")
+	return keyword, strings.Trim(value, "\"")
+}
+
+// sudoers Defaults: flags are "true", negated flags are "false", last setting wins
+func configValueSudoers(fp string, key string) (string, bool, error) {
+	lines, err := readSudoersLines(fp, 0)
+	if err != nil {
+		return "", false, err
+	}
+	value := ""
+	found := false
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		// only global Defaults, not Defaults:user, Defaults@host, etc.
+		if len(fields) < 2 || fields[0] != "Defaults" {
+			continue
+		}
+		for _, setting := range strings.Split(strings.Join(fields[1:], " "), ",") {
+			setting = strings.TrimSpace(setting)
+			name := setting
+			settingValue := "true"
+			if i := strings.Index(setting, "="); i >= 0 {
+				name = strings.TrimSpace(strings.TrimRight(setting[:i], "+-"))
+				settingValue = strings.Trim(strings.TrimSpace(setting[i+1:]), "\"")
+			} else if strings.HasPrefix(setting, "!") {
+				name = strings.TrimSpace(setting[1:])
+				settingValue = "false"
+			}
+			if name == key {
+				value = settingValue
+				found = true
+			}
+		}
+	}
+	return value, found, nil
+}
+
+// sudoers lines with comments removed, continuation lines joined and includes resolved
+func readSudoersLines(fp string, depth int) ([]string, error) {
+	if depth > configIncludeMaxDepth {
+		return nil, errConfigIncludeDepth
+	}
+	bs, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0)
+	var current strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		line = strings.TrimSpace(current.String())
+		current.Reset()
+
+		directive, arg := splitSudoersInclude(line)
+		if directive == "include" {
+			if !filepath.IsAbs(arg) {
+				arg = filepath.Join(filepath.Dir(fp), arg)
+			}
+			included, err := readSudoersLines(arg, depth+1)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			lines = append(lines, included...)
+			continue
+		} else if directive == "includedir" {
+			if !filepath.IsAbs(arg) {
+				arg = filepath.Join(filepath.Dir(fp), arg)
+			}
+			files, err := ioutil.ReadDir(arg)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			for _, file := range files {
+				// sudo skips names ending in ~ or containing a .
+				name := file.Name()
+				if file.IsDir() || strings.HasSuffix(name, "~") || strings.Contains(name, ".") {
+					continue
+				}
+				included, err := readSudoersLines(filepath.Join(arg, name), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, included...)
+			}
+			continue
+		}
+
+		line = stripSudoersComment(line)
+		if len(line) == 0 {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// # followed by a digit is a uid or gid (#1005, (#0), %#27), not a comment
+func stripSudoersComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] != '#' {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9' {
+			continue
+		}
+		if i > 0 && line[i-1] == '%' {
+			continue
+		}
+		return strings.TrimSpace(line[:i])
+	}
+	return line
+}
+
+func splitSudoersInclude(line string) (string, string) {
+	for _, prefix := range []string{"#", "@"} {
+		for _, directive := range []string{"includedir", "include"} {
+			if strings.HasPrefix(line, prefix+directive+" ") {
+				arg := strings.TrimSpace(line[len(prefix+directive):])
+				return directive, strings.Trim(arg, "\"")
+			}
+		}
+	}
+	return "", ""
+}
+
+// key=value or key value (e.g. login.defs), last setting wins
+func configValueKeyValue(bs []byte, key string) (string, bool) {
+	value := ""
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		var k, v string
+		if i := strings.Index(line, "="); i >= 0 {
+			k, v = line[:i], line[i+1:]
+		} else {
+			fields := strings.Fields(line)
+			k = fields[0]
+			v = strings.Join(fields[1:], " ")
+		}
+		if strings.TrimSpace(k) != key {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		value = v
+		found = true
+	}
+	return value, found
+}
+
+// INI: empty section is before any section header, last setting wins
+func configValueINI(bs []byte, section string, key string) (string, bool) {
+	value := ""
+	found := false
+	currentSection := ""
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentSection = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if currentSection != section {
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			continue
+		}
+		if strings.TrimSpace(line[:i]) != key {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(line[i+1:]), "\"")
+		found = true
+	}
+	return value, found
+}
+
+func configValueJSON(bs []byte, path string) (string, bool, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return "", false, err
+	}
+	value, found := configValuePath(doc, path)
+	if !found {
+		return "", false, nil
+	}
+	return configValueString(value), true, nil
+}
+
+func configValueYAML(bs []byte, path string) (string, bool, error) {
+	var doc interface{}
+	err := yaml.Unmarshal(bs, &doc)
+	if err != nil {
+		return "", false, err
+	}
+	value, found := configValuePath(doc, path)
+	if !found {
+		return "", false, nil
+	}
+	return configValueString(value), true, nil
+}
+
+// dot separated path, numbers index into lists
+func configValuePath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	if len(path) == 0 {
+		return current, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, present := node[part]
+			if !present {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func configValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(bs)
+	}
+	return fmt.Sprint(value)
+}
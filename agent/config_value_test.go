@@ -0,0 +1,187 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigValueSSHD(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config_value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Mkdir(filepath.Join(dir, "sshd_config.d"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "sshd_config.d", "10-first.conf"), []byte("permitrootlogin prohibit-password\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sshd_config.d", "20-second.conf"), []byte("PermitRootLogin yes\nPasswordAuthentication=no\n"), 0644)
+	fp := filepath.Join(dir, "sshd_config")
+	text := "# PermitRootLogin no\n" +
+		"Include " + filepath.Join(dir, "sshd_config.d", "*.conf") + "\n" +
+		"PermitRootLogin no\n" +
+		"  X11Forwarding   yes  \n" +
+		"Match User bob\n" +
+		"  AllowTcpForwarding yes\n" +
+		"Match all\n" +
+		"AllowTcpForwarding no\n"
+	ioutil.WriteFile(fp, []byte(text), 0644)
+
+	value, found, err := configValueSSHD(fp, "PermitRootLogin")
+	if err != nil || !found || value != "prohibit-password" {
+		t.Fatal("Unexpected PermitRootLogin", value, found, err)
+	}
+	value, found, _ = configValueSSHD(fp, "passwordauthentication")
+	if !found || value != "no" {
+		t.Fatal("Unexpected PasswordAuthentication", value)
+	}
+	value, found, _ = configValueSSHD(fp, "X11Forwarding")
+	if !found || value != "yes" {
+		t.Fatal("Unexpected X11Forwarding", value)
+	}
+	value, found, _ = configValueSSHD(fp, "AllowTcpForwarding")
+	if !found || value != "no" {
+		t.Fatal("Unexpected AllowTcpForwarding", value)
+	}
+	_, found, _ = configValueSSHD(fp, "Port")
+	if found {
+		t.Fatal("Found unset keyword")
+	}
+
+	// include loop
+	loop := filepath.Join(dir, "loop")
+	ioutil.WriteFile(loop, []byte("Include "+loop), 0644)
+	_, _, err = configValueSSHD(loop, "Port")
+	if err == nil {
+		t.Fatal("Expected include depth error")
+	}
+}
+
+func TestConfigValueSudoers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config_value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Mkdir(filepath.Join(dir, "sudoers.d"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "sudoers.d", "timeout"), []byte("Defaults timestamp_timeout=0\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sudoers.d", "ignored.bak"), []byte("Defaults timestamp_timeout=99\n"), 0644)
+	fp := filepath.Join(dir, "sudoers")
+	text := "Defaults\tenv_reset\n" +
+		"Defaults\t!lecture, \\\n secure_path=\"/usr/bin:/bin\"\n" +
+		"Defaults:bob !env_reset\n" +
+		"#includedir " + filepath.Join(dir, "sudoers.d") + "\n"
+	ioutil.WriteFile(fp, []byte(text), 0644)
+
+	value, found, err := configValueSudoers(fp, "env_reset")
+	if err != nil || !found || value != "true" {
+		t.Fatal("Unexpected env_reset", value, found, err)
+	}
+	value, found, _ = configValueSudoers(fp, "lecture")
+	if !found || value != "false" {
+		t.Fatal("Unexpected lecture", value)
+	}
+	value, found, _ = configValueSudoers(fp, "secure_path")
+	if !found || value != "/usr/bin:/bin" {
+		t.Fatal("Unexpected secure_path", value)
+	}
+	value, found, _ = configValueSudoers(fp, "timestamp_timeout")
+	if !found || value != "0" {
+		t.Fatal("Unexpected timestamp_timeout", value)
+	}
+}
+
+func TestConfigValueKeyValue(t *testing.T) {
+	bs := []byte("# PASS_MAX_DAYS 1\nPASS_MAX_DAYS\t99999\nPASS_MAX_DAYS   90\nUMASK=022\nexport NAME=\"quoted value\"\n")
+	value, found := configValueKeyValue(bs, "PASS_MAX_DAYS")
+	if !found || value != "90" {
+		t.Fatal("Unexpected PASS_MAX_DAYS", value)
+	}
+	value, found = configValueKeyValue(bs, "UMASK")
+	if !found || value != "022" {
+		t.Fatal("Unexpected UMASK", value)
+	}
+	value, found = configValueKeyValue(bs, "NAME")
+	if !found || value != "quoted value" {
+		t.Fatal("Unexpected NAME", value)
+	}
+	_, found = configValueKeyValue(bs, "MISSING")
+	if found {
+		t.Fatal("Found missing key")
+	}
+}
+
+func TestConfigValueINI(t *testing.T) {
+	bs := []byte("top = 1\n; comment\n[main]\nkey = a\n[other]\nkey: b\n[main]\nkey = \"c\"\n")
+	value, found := configValueINI(bs, "", "top")
+	if !found || value != "1" {
+		t.Fatal("Unexpected top", value)
+	}
+	value, found = configValueINI(bs, "main", "key")
+	if !found || value != "c" {
+		t.Fatal("Unexpected main key", value)
+	}
+	value, found = configValueINI(bs, "other", "key")
+	if !found || value != "b" {
+		t.Fatal("Unexpected other key", value)
+	}
+	_, found = configValueINI(bs, "missing", "key")
+	if found {
+		t.Fatal("Found key in missing section")
+	}
+}
+
+func TestConfigValueJSON(t *testing.T) {
+	bs := []byte(`{"a": {"b": [1, {"c": true}], "d": "text", "e": 1.50}}`)
+	tests := map[string]string{
+		"a.b.0":   "1",
+		"a.b.1.c": "true",
+		"a.d":     "text",
+		"a.e":     "1.50",
+		"a.b":     `[1,{"c":true}]`,
+	}
+	for path, expected := range tests {
+		value, found, err := configValueJSON(bs, path)
+		if err != nil || !found || value != expected {
+			t.Fatal("Unexpected value for", path, value, found, err)
+		}
+	}
+	_, found, _ := configValueJSON(bs, "a.b.5")
+	if found {
+		t.Fatal("Found out of range index")
+	}
+	_, _, err := configValueJSON([]byte("{bad"), "a")
+	if err == nil {
+		t.Fatal("Parsed bad JSON")
+	}
+}
+
+func TestConfigValueYAML(t *testing.T) {
+	bs := []byte("network:\n  version: 2\n  ethernets:\n    - name: eth0\n      dhcp4: true\n")
+	value, found, err := configValueYAML(bs, "network.version")
+	if err != nil || !found || value != "2" {
+		t.Fatal("Unexpected version", value, found, err)
+	}
+	value, found, _ = configValueYAML(bs, "network.ethernets.0.dhcp4")
+	if !found || value != "true" {
+		t.Fatal("Unexpected dhcp4", value)
+	}
+	_, found, _ = configValueYAML(bs, "network.missing")
+	if found {
+		t.Fatal("Found missing key")
+	}
+}
+
+func TestCheckConfigValue(t *testing.T) {
+	if checkConfigValue([]string{"file", "sshd"}) != "invalid arguments" {
+		t.Fatal("Expected invalid arguments")
+	}
+	if checkConfigValue([]string{"file", "bad", "key"}) != "invalid dialect" {
+		t.Fatal("Expected invalid dialect")
+	}
+	if checkConfigValue([]string{"/nonexistent/file", "keyvalue", "key"}) != "could not read file" {
+		t.Fatal("Expected could not read file")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 // asdf
 const (
+	AgentUserLinux     = "cp-scoring"
 	FileAgentLinux     = "cp-scoring-agent-linux"
 	FileAgentWindows   = "cp-scoring-agent-windows.exe"
 	FileReadmeHTML     = "README.html"
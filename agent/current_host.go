@@ -1,6 +1,11 @@
 package main
 
 type currentHost interface {
+	agentPath() string
 	copyTeamFiles() error
 	install() error
+	repair() error
+	replaceAgent(binary []byte) error
+	restartAgent() error
+	uninstall() error
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+	"github.com/netwayfind/cp-scoring/processing"
+	"golang.org/x/crypto/openpgp"
+)
+
+// checks file is either a list of checks or a scenario host, as exported
+// from the scenario hosts API, answers file is a list of answers or such a
+// scenario host, which also gives the composite answers agents are not sent
+func dryRun(w io.Writer, dirConfig string, hostname string, scenarioID uint64, role string, checksFile string, answersFile string) error {
+	var host model.ScenarioHost
+	// scripts from a local file carry no server signature, scripts from the
+	// server are verified like the running agent does
+	var entities openpgp.EntityList
+	if len(checksFile) > 0 {
+		bs, err := ioutil.ReadFile(checksFile)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(bs, &host.Checks)
+		if err != nil {
+			err = json.Unmarshal(bs, &host)
+			if err != nil {
+				return errors.New("could not read checks file; " + err.Error())
+			}
+		}
+		// local scripts are trusted as written
+		for i, script := range host.Scripts {
+			host.Scripts[i].Hash = processing.ScriptHash([]byte(script.Body))
+		}
+	} else {
+		serverURL, err := readServerURL(dirConfig)
+		if err != nil {
+			return err
+		}
+		if scenarioID == 0 {
+			return errors.New("no scenario configured")
+		}
+		host.Checks, _, _, _, err = getScenarioChecks(serverURL, scenarioID, hostname, role, "", "Thu, 01 Jan 1970 00:00:00 GMT", defaultCheckSchedule)
+		if err != nil {
+			return err
+		}
+		host.Scripts, err = getScenarioScripts(serverURL, scenarioID, hostname, role)
+		if err != nil {
+			return err
+		}
+		entities, err = readServerPubKey(dirConfig)
+		if err != nil {
+			return errors.New("could not read server public key; " + err.Error())
+		}
+	}
+	if len(answersFile) > 0 {
+		bs, err := ioutil.ReadFile(answersFile)
+		if err != nil {
+			return err
+		}
+		host.Answers = nil
+		err = json.Unmarshal(bs, &host.Answers)
+		if err != nil {
+			var answersHost model.ScenarioHost
+			err = json.Unmarshal(bs, &answersHost)
+			if err != nil {
+				return errors.New("could not read answers file; " + err.Error())
+			}
+			host.Answers = answersHost.Answers
+			copyCompositeAnswers(host.Checks, answersHost.Checks)
+		}
+	}
+	if len(host.Answers) > 0 && len(host.Answers) != len(host.Checks) {
+		return fmt.Errorf("%d answers for %d checks", len(host.Answers), len(host.Checks))
+	}
+
+	// separate from the running agent's temp directory
+	tempDir, err := ioutil.TempDir("", "cp-scoring-dry-run")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	os.Chmod(tempDir, 0711)
+	err = installScripts(host.Scripts, tempDir, entities)
+	if err != nil {
+		return err
+	}
+
+	printDryRun(w, host.Checks, host.Answers, tempDir)
+	return nil
+}
+
+// composite checks from the server come without answers
+func copyCompositeAnswers(checks []model.Action, from []model.Action) {
+	for i := range checks {
+		if i >= len(from) || checks[i].Type != model.ActionTypeComposite || from[i].Type != model.ActionTypeComposite {
+			continue
+		}
+		checks[i].Answers = from[i].Answers
+		copyCompositeAnswers(checks[i].Checks, from[i].Checks)
+	}
+}
+
+// checks run one at a time, so each time is the check alone
+func printDryRun(w io.Writer, checks []model.Action, answers []model.Answer, tempDir string) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(answers) > 0 {
+		fmt.Fprintln(tw, "#\tDESCRIPTION\tRESULT\tTIME\tEXPECTED\tPASS\tPOINTS")
+	} else {
+		fmt.Fprintln(tw, "#\tDESCRIPTION\tRESULT\tTIME")
+	}
+
+	score := 0
+	for i, check := range checks {
+		start := time.Now()
+		detail := executeCheck(check, tempDir)
+		// scored like the server does, children were all run by the agent
+		if check.Type == model.ActionTypeComposite && len(answers) > 0 {
+			detail.Result = processing.CompositeResult(check, detail.Children)
+		}
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		line := fmt.Sprintf("%d\t%s\t%s\t%s", i+1, check.Description, dryRunValue(detail.Result), elapsed)
+		if len(answers) > 0 {
+			answer := answers[i]
+			points := 0
+			passed := processing.AnswerPasses(answer, detail.Result)
+			if passed {
+				points = answer.Points
+				score += points
+			}
+			line += fmt.Sprintf("\t%s %v\t%t\t%d", answer.Operator, answer.Value, passed, points)
+		}
+		fmt.Fprintln(tw, line)
+		printDryRunChildren(tw, detail.Children, "  ")
+		if len(detail.Diagnostics) > 0 {
+			fmt.Fprintf(tw, "\t  stderr: %s\n", dryRunValue(detail.Diagnostics))
+		}
+	}
+	tw.Flush()
+
+	if len(answers) > 0 {
+		fmt.Fprintf(w, "Score: %d\n", score)
+	}
+}
+
+func printDryRunChildren(tw io.Writer, children []model.CheckResultDetail, indent string) {
+	for _, child := range children {
+		fmt.Fprintf(tw, "\t%s%s\t%s\t\t\t%t\n", indent, child.Description, dryRunValue(child.Result), child.Passed)
+		printDryRunChildren(tw, child.Children, indent+"  ")
+	}
+}
+
+// keeps multi-line results on one table row
+func dryRunValue(s string) string {
+	s = strings.Replace(s, "\n", "\\n", -1)
+	if len(s) > 60 {
+		return s[:57] + "..."
+	}
+	return s
+}
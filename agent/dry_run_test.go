@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/netwayfind/cp-scoring/model"
+	"github.com/netwayfind/cp-scoring/processing"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestDryRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	dir, err := ioutil.TempDir("", "dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	present := filepath.Join(dir, "present")
+	ioutil.WriteFile(present, []byte{}, 0644)
+	host := model.ScenarioHost{
+		Checks: []model.Action{
+			{Type: model.ActionTypeFileExist, Description: "file exists", Args: []string{present}},
+			{Type: model.ActionTypeExec, Description: "script", Command: "/bin/sh", Script: "check.sh", RunAs: model.RunAsPrivileged},
+			{Type: model.ActionTypeComposite, Description: "composite", Args: []string{model.CompositeAnyOf},
+				Checks:  []model.Action{{Type: model.ActionTypeFileExist, Description: "child", Args: []string{present}}},
+				Answers: []model.Answer{{Operator: model.OperatorTypeEqual, Value: "true"}}},
+		},
+		Answers: []model.Answer{
+			{Operator: model.OperatorTypeEqual, Value: "true", Points: 2},
+			{Operator: model.OperatorTypeEqual, Value: "7", Points: 3},
+			{Operator: model.OperatorTypeEqual, Value: "false", Points: 5},
+		},
+		Scripts: []model.Script{{Name: "check.sh", Body: "echo 7\n"}},
+	}
+	bs, _ := json.Marshal(host)
+	hostFile := filepath.Join(dir, "host.json")
+	ioutil.WriteFile(hostFile, bs, 0644)
+
+	var out bytes.Buffer
+	err = dryRun(&out, dir, "host", 0, "", hostFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatal("Unexpected output", out.String())
+	}
+	if !strings.HasPrefix(lines[0], "#") || !strings.Contains(lines[0], "PASS") {
+		t.Fatal("Unexpected header", lines[0])
+	}
+	fields := strings.Fields(lines[2])
+	if fields[1] != "script" || fields[2] != "7" || fields[len(fields)-2] != "true" || fields[len(fields)-1] != "3" {
+		t.Fatal("Unexpected script row", lines[2])
+	}
+	if fields = strings.Fields(lines[3]); fields[2] != "true" {
+		t.Fatal("Expected composite scored", lines[3])
+	}
+	if !strings.Contains(lines[4], "child") {
+		t.Fatal("Expected composite child row", lines[4])
+	}
+	if lines[5] != "Score: 5" {
+		t.Fatal("Unexpected score", lines[5])
+	}
+
+	// checks as sent to agents, composite answers from the exported host
+	agentChecks := append([]model.Action{}, host.Checks...)
+	agentChecks[2].Answers = nil
+	bs, _ = json.Marshal(agentChecks)
+	agentChecksFile := filepath.Join(dir, "agent-checks.json")
+	ioutil.WriteFile(agentChecksFile, bs, 0644)
+	out.Reset()
+	err = dryRun(&out, dir, "host", 0, "", agentChecksFile, hostFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	if fields = strings.Fields(lines[3]); fields[2] != "true" {
+		t.Fatal("Expected composite scored with exported answers", out.String())
+	}
+
+	// list of checks, no answers
+	bs, _ = json.Marshal(host.Checks[:1])
+	checksFile := filepath.Join(dir, "checks.json")
+	ioutil.WriteFile(checksFile, bs, 0644)
+	out.Reset()
+	err = dryRun(&out, dir, "host", 0, "", checksFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "PASS") || strings.Contains(out.String(), "Score") {
+		t.Fatal("Expected no answers", out.String())
+	}
+
+	// answers must line up with checks
+	answersFile := filepath.Join(dir, "answers.json")
+	bs, _ = json.Marshal(host.Answers)
+	ioutil.WriteFile(answersFile, bs, 0644)
+	if dryRun(&out, dir, "host", 0, "", checksFile, answersFile) == nil {
+		t.Fatal("Expected answer count mismatch")
+	}
+}
+
+func TestDryRunServerScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	defer runAsTestUser()()
+	dir, err := ioutil.TempDir("", "dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pubKey, privKey, err := processing.NewPubPrivKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privEntities, _ := openpgp.ReadArmoredKeyRing(bytes.NewReader(privKey))
+	body := "echo 7\n"
+	signature, _ := processing.SignDetached([]byte(body), privEntities[0])
+	script := model.Script{Name: "check.sh", Body: body, Hash: processing.ScriptHash([]byte(body)), Signature: string(signature)}
+	checks := []model.Action{{Type: model.ActionTypeExec, Description: "script", Command: "/bin/sh", Script: "check.sh"}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/scenario-checks/4" {
+			json.NewEncoder(w).Encode(checks)
+		} else if r.URL.Path == "/api/scenario-scripts/4" {
+			json.NewEncoder(w).Encode([]model.Script{script})
+		}
+	}))
+	defer ts.Close()
+	saveFile(dir, fileNameServer, ts.URL)
+	saveFile(dir, fileNameServerPubKey, string(pubKey))
+
+	var out bytes.Buffer
+	err = dryRun(&out, dir, "host", 4, "", "", "")
+	if err != nil || !strings.Contains(out.String(), "script") {
+		t.Fatal("Expected signed script to run", err, out.String())
+	}
+
+	// a tampered response is not run
+	script.Body = "echo tampered\n"
+	script.Hash = processing.ScriptHash([]byte(script.Body))
+	out.Reset()
+	if dryRun(&out, dir, "host", 4, "", "", "") == nil {
+		t.Fatal("Expected signature failure", out.String())
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+)
+
+const dirNameScenarios = "scenarios"
+
+// one scenario the host takes part in; the scenario from config keeps its
+// files where agents before enrollments kept them, the ones added with
+// -enroll have their own folders
+type enrollment struct {
+	ScenarioID uint64
+	// declared role
+	dirConfig string
+	// host token, matched role and team key
+	dirData string
+	// scripts, checks run here
+	dirTemp string
+}
+
+func (e enrollment) role() string {
+	return readRole(e.dirConfig, e.dirData)
+}
+
+func readEnrollments(dirConfig string, dirData string) ([]enrollment, error) {
+	dirTemp := path.Join(dirData, "temp")
+	enrollments := make([]enrollment, 0)
+	seen := make(map[uint64]bool)
+
+	scenarioID, err := readScenarioID(dirConfig)
+	if err == nil {
+		enrollments = append(enrollments, enrollment{
+			ScenarioID: scenarioID,
+			dirConfig:  dirConfig,
+			dirData:    dirData,
+			dirTemp:    path.Join(dirTemp, strconv.FormatUint(scenarioID, 10)),
+		})
+		seen[scenarioID] = true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fileInfos, err := ioutil.ReadDir(path.Join(dirConfig, dirNameScenarios))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	added := make([]uint64, 0)
+	for _, fileInfo := range fileInfos {
+		id, err := strconv.ParseUint(fileInfo.Name(), 10, 64)
+		if err != nil || !fileInfo.IsDir() || seen[id] {
+			continue
+		}
+		added = append(added, id)
+		seen[id] = true
+	}
+	sort.Slice(added, func(i, j int) bool {
+		return added[i] < added[j]
+	})
+	for _, id := range added {
+		enrollments = append(enrollments, addedEnrollment(dirConfig, dirData, id))
+	}
+
+	return enrollments, nil
+}
+
+func addedEnrollment(dirConfig string, dirData string, scenarioID uint64) enrollment {
+	id := strconv.FormatUint(scenarioID, 10)
+	return enrollment{
+		ScenarioID: scenarioID,
+		dirConfig:  path.Join(dirConfig, dirNameScenarios, id),
+		dirData:    path.Join(dirData, dirNameScenarios, id),
+		dirTemp:    path.Join(dirData, "temp", id),
+	}
+}
+
+// folders are created by the running agent as well, so enrollments added
+// while it runs are picked up
+func createEnrollmentDirs(e enrollment) error {
+	for _, dir := range []string{e.dirConfig, e.dirData, e.dirTemp} {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return err
+		}
+	}
+	// checks run as an unprivileged account start in the temp directory
+	return os.Chmod(e.dirTemp, 0711)
+}
+
+func saveEnrollment(dirConfig string, dirData string, scenarioID uint64, role string) (enrollment, error) {
+	enrollments, err := readEnrollments(dirConfig, dirData)
+	if err != nil {
+		return enrollment{}, err
+	}
+	for _, e := range enrollments {
+		if e.ScenarioID == scenarioID {
+			return enrollment{}, errors.New("already enrolled in scenario " + strconv.FormatUint(scenarioID, 10))
+		}
+	}
+
+	e := addedEnrollment(dirConfig, dirData, scenarioID)
+	err = createEnrollmentDirs(e)
+	if err != nil {
+		return e, err
+	}
+	if len(role) > 0 {
+		err = saveFile(e.dirConfig, fileNameRole, role)
+	}
+	return e, err
+}
+
+// the scenario given, or the first one
+func selectEnrollment(enrollments []enrollment, scenarioID string) (enrollment, error) {
+	if len(enrollments) == 0 {
+		return enrollment{}, errors.New("no scenario configured")
+	}
+	if len(scenarioID) == 0 {
+		return enrollments[0], nil
+	}
+	for _, e := range enrollments {
+		if strconv.FormatUint(e.ScenarioID, 10) == scenarioID {
+			return e, nil
+		}
+	}
+	return enrollment{}, errors.New("not enrolled in scenario " + scenarioID)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadEnrollments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-scoring-enrollment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dirConfig := filepath.Join(dir, "config")
+	dirData := filepath.Join(dir, "data")
+	createDir(dirConfig)
+	createDir(dirData)
+
+	enrollments, err := readEnrollments(dirConfig, dirData)
+	if err != nil || len(enrollments) != 0 {
+		t.Fatal("Expected no enrollments", enrollments, err)
+	}
+	_, err = selectEnrollment(enrollments, "")
+	if err == nil {
+		t.Fatal("Expected error without enrollments")
+	}
+
+	// scenario from config keeps its files in place
+	saveFile(dirConfig, fileNameScenario, "5")
+	saveFile(dirConfig, fileNameRole, "server")
+	_, err = saveEnrollment(dirConfig, dirData, 12, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = saveEnrollment(dirConfig, dirData, 9, "workstation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = saveEnrollment(dirConfig, dirData, 5, "")
+	if err == nil {
+		t.Fatal("Expected error for scenario already enrolled")
+	}
+
+	enrollments, err = readEnrollments(dirConfig, dirData)
+	if err != nil || len(enrollments) != 3 {
+		t.Fatal("Unexpected enrollments", enrollments, err)
+	}
+	if enrollments[0].ScenarioID != 5 || enrollments[0].dirData != dirData || enrollments[0].role() != "server" {
+		t.Fatal("Unexpected config enrollment", enrollments[0])
+	}
+	if enrollments[1].ScenarioID != 9 || enrollments[1].role() != "workstation" || enrollments[2].ScenarioID != 12 {
+		t.Fatal("Unexpected added enrollments", enrollments)
+	}
+	if enrollments[1].dirData == dirData || enrollments[1].dirTemp == enrollments[2].dirTemp {
+		t.Fatal("Expected separate directories", enrollments)
+	}
+	fileInfo, err := os.Stat(enrollments[1].dirTemp)
+	if err != nil || fileInfo.Mode().Perm() != 0711 {
+		t.Fatal("Expected temp directory for unprivileged checks", err)
+	}
+
+	e, err := selectEnrollment(enrollments, "12")
+	if err != nil || e.ScenarioID != 12 {
+		t.Fatal("Unexpected selected enrollment", e, err)
+	}
+	e, err = selectEnrollment(enrollments, "")
+	if err != nil || e.ScenarioID != 5 {
+		t.Fatal("Expected first enrollment", e, err)
+	}
+	_, err = selectEnrollment(enrollments, "6")
+	if err == nil {
+		t.Fatal("Expected error for scenario not enrolled")
+	}
+}
+
+func TestRunScenarios(t *testing.T) {
+	runs := make(map[uint64]*scenarioRun)
+	enrollments := []enrollment{{ScenarioID: 1}}
+	ran := make(map[uint64]int)
+	run := func(r *scenarioRun) {
+		ran[r.ScenarioID]++
+		if r.ScenarioID == 2 {
+			r.schedule = checkSchedule{Interval: 10 * time.Second}
+		}
+	}
+
+	now := time.Unix(1000, 0)
+	next := runScenarios(runs, enrollments, now, run)
+	if ran[1] != 1 || !next.Equal(now.Add(time.Minute)) {
+		t.Fatal("Unexpected first run", ran, next)
+	}
+
+	// added scenario runs at once, then on its own interval
+	enrollments = append(enrollments, enrollment{ScenarioID: 2})
+	now = now.Add(5 * time.Second)
+	next = runScenarios(runs, enrollments, now, run)
+	if ran[1] != 1 || ran[2] != 1 || !next.Equal(now.Add(10*time.Second)) {
+		t.Fatal("Unexpected run of added scenario", ran, next)
+	}
+	now = next
+	runScenarios(runs, enrollments, now, run)
+	if ran[1] != 1 || ran[2] != 2 {
+		t.Fatal("Expected only the scenario due to run", ran)
+	}
+}
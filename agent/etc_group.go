@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+var etcGroupFile = "/etc/group"
+var etcPasswdFile = "/etc/passwd"
+
+type etcUser struct {
+	Name string
+	UID  string
+	GID  string
+}
+
+type etcGroup struct {
+	Name    string
+	GID     string
+	Members []string
+}
+
+func parseEtcPasswd(bs []byte) []etcUser {
+	users := make([]etcUser, 0)
+	for _, line := range strings.Split(string(bs), "\n") {
+		tokens := strings.Split(line, ":")
+		if len(tokens) != 7 {
+			continue
+		}
+		users = append(users, etcUser{Name: tokens[0], UID: tokens[2], GID: tokens[3]})
+	}
+	return users
+}
+
+func parseEtcGroup(bs []byte) []etcGroup {
+	groups := make([]etcGroup, 0)
+	for _, line := range strings.Split(string(bs), "\n") {
+		tokens := strings.Split(line, ":")
+		if len(tokens) != 4 {
+			continue
+		}
+		group := etcGroup{Name: tokens[0], GID: tokens[2], Members: make([]string, 0)}
+		if len(tokens[3]) > 0 {
+			group.Members = strings.Split(tokens[3], ",")
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// user from /etc/passwd and every group it is in, primary group included;
+// a user missing from /etc/passwd has only its name set
+func readUserGroups(name string) (etcUser, []etcGroup, error) {
+	user := etcUser{Name: name}
+	bs, err := ioutil.ReadFile(etcPasswdFile)
+	if err != nil {
+		return user, nil, err
+	}
+	for _, u := range parseEtcPasswd(bs) {
+		if u.Name == name {
+			user = u
+			break
+		}
+	}
+
+	bs, err = ioutil.ReadFile(etcGroupFile)
+	if err != nil {
+		return user, nil, err
+	}
+	groups := make([]etcGroup, 0)
+	for _, group := range parseEtcGroup(bs) {
+		member := len(user.GID) > 0 && group.GID == user.GID
+		for _, m := range group.Members {
+			if m == name {
+				member = true
+			}
+		}
+		if member {
+			groups = append(groups, group)
+		}
+	}
+	return user, groups, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+const execDefaultTimeout = 30 * time.Second
+
+// how long to wait for output pipes after the command exits, a detached
+// grandchild may still hold them
+const execWaitDelay = 5 * time.Second
+const execStderrMaxLength int = 4096
+
+// account for EXEC checks with RunAs empty or unprivileged, created on install
+var runAsDefaultUser = AgentUserLinux
+
+// stderr of a timed out command may be incomplete
+func executeCommand(check model.Action, tempDir string) (string, string) {
+	command := check.Command
+	args := check.Args
+	if len(check.Script) > 0 {
+		path, err := resolveScript(check.Script, tempDir)
+		if os.IsNotExist(err) {
+			return "script not found", ""
+		} else if err != nil {
+			return "invalid script", err.Error()
+		}
+		// a script run by an interpreter goes after the interpreter args,
+		// a script run directly is given the args
+		if len(command) == 0 {
+			command = path
+		} else {
+			args = append(append([]string{}, check.Args...), path)
+		}
+	}
+	if len(command) == 0 {
+		return "invalid command", ""
+	}
+	if check.Result != "" && check.Result != model.ExecResultStdout && check.Result != model.ExecResultExitCode {
+		return "invalid result", ""
+	}
+	timeout := execDefaultTimeout
+	if check.Timeout > 0 {
+		timeout = time.Duration(check.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command, args...)
+	cmd.Dir = tempDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+	err := setRunAs(cmd, check.RunAs)
+	if err != nil {
+		return "invalid run as user", err.Error()
+	}
+	err = runWithContext(ctx, cmd)
+	diagnostics := truncateStderr(stderr.String())
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return "timed out", diagnostics
+	}
+	var exitErr *exec.ExitError
+	if check.Result == model.ExecResultExitCode && errors.As(err, &exitErr) {
+		return strconv.Itoa(exitErr.ExitCode()), diagnostics
+	}
+	if err != nil {
+		if len(diagnostics) == 0 {
+			diagnostics = err.Error()
+		}
+		return "could not execute file", diagnostics
+	}
+	if check.Result == model.ExecResultExitCode {
+		return "0", diagnostics
+	}
+	return strings.TrimSpace(stdout.String()), diagnostics
+}
+
+// kills the process group on cancellation, so children cannot hold the
+// output pipes open
+func runWithContext(ctx context.Context, cmd *exec.Cmd) error {
+	cmd.WaitDelay = execWaitDelay
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		// the command itself succeeded, output so far is kept
+		if errors.Is(err, exec.ErrWaitDelay) {
+			err = nil
+		}
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		return <-done
+	}
+}
+
+func truncateStderr(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > execStderrMaxLength {
+		return s[:execStderrMaxLength]
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+// checks without RunAs switch to the default account when run as root, tests
+// run them as root instead of creating it
+func runAsTestUser() func() {
+	user := runAsDefaultUser
+	runAsDefaultUser = "root"
+	return func() {
+		runAsDefaultUser = user
+	}
+}
+
+func TestExecuteCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	defer runAsTestUser()()
+	dir, err := ioutil.TempDir("", "exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sh := func(script string) model.Action {
+		return model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", script}}
+	}
+
+	result, _ := executeCommand(model.Action{Type: model.ActionTypeExec}, dir)
+	if result != "invalid command" {
+		t.Fatal("Expected invalid command", result)
+	}
+	result, _ = executeCommand(model.Action{Type: model.ActionTypeExec, Command: "/nonexistent"}, dir)
+	if result != "could not execute file" {
+		t.Fatal("Expected could not execute file", result)
+	}
+
+	result, diagnostics := executeCommand(sh("echo ' out '; echo err >&2; pwd >&2"), dir)
+	if result != "out" {
+		t.Fatal("Unexpected stdout", result)
+	}
+	// runs in the temp directory
+	if !strings.HasPrefix(diagnostics, "err\n") || !strings.HasSuffix(diagnostics, filepath.Base(dir)) {
+		t.Fatal("Unexpected stderr", diagnostics)
+	}
+
+	// stdout is not the result of a failed command
+	result, diagnostics = executeCommand(sh("echo partial; echo failed >&2; exit 3"), dir)
+	if result != "could not execute file" || diagnostics != "failed" {
+		t.Fatal("Unexpected failure result", result, diagnostics)
+	}
+
+	check := sh("echo ignored; exit 3")
+	check.Result = model.ExecResultExitCode
+	result, _ = executeCommand(check, dir)
+	if result != "3" {
+		t.Fatal("Unexpected exit code", result)
+	}
+	check = sh("true")
+	check.Result = model.ExecResultExitCode
+	result, _ = executeCommand(check, dir)
+	if result != "0" {
+		t.Fatal("Unexpected exit code", result)
+	}
+	check.Result = "BOTH"
+	result, _ = executeCommand(check, dir)
+	if result != "invalid result" {
+		t.Fatal("Expected invalid result", result)
+	}
+
+	// background child keeps stdout open, killing the group ends it
+	check = sh("sleep 30 & sleep 30")
+	check.Timeout = 1
+	start := time.Now()
+	result, _ = executeCommand(check, dir)
+	if result != "timed out" {
+		t.Fatal("Expected timed out", result)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("Timed out command was not killed", time.Since(start))
+	}
+
+	// detached grandchild outside the group keeps stdout open after the
+	// command exits, output so far is the result
+	if _, err := exec.LookPath("setsid"); err == nil {
+		check = sh("setsid sleep 30 & echo done")
+		start = time.Now()
+		result, _ = executeCommand(check, dir)
+		if result != "done" {
+			t.Fatal("Unexpected result with detached child", result)
+		}
+		if time.Since(start) > execWaitDelay+5*time.Second {
+			t.Fatal("Wait blocked on detached child", time.Since(start))
+		}
+	}
+
+	if len(truncateStderr(strings.Repeat("x", execStderrMaxLength+10))) != execStderrMaxLength {
+		t.Fatal("Expected stderr to be truncated")
+	}
+}
+
+func TestExecuteCommandRunAs(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+		t.Skip("needs root to switch accounts")
+	}
+	dir, err := ioutil.TempDir("", "exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Chmod(dir, 0711)
+
+	defer func(passwd string, group string, user string) {
+		etcPasswdFile, etcGroupFile, runAsDefaultUser = passwd, group, user
+	}(etcPasswdFile, etcGroupFile, runAsDefaultUser)
+	etcPasswdFile = filepath.Join(dir, "passwd")
+	etcGroupFile = filepath.Join(dir, "group")
+	ioutil.WriteFile(etcPasswdFile, []byte("root:x:0:0::/root:/bin/sh\nchecker:x:65534:65534::/:/usr/sbin/nologin\nother:x:65533:65533::/:/usr/sbin/nologin\n"), 0644)
+	ioutil.WriteFile(etcGroupFile, []byte("root:x:0:\nnogroup:x:65534:\nextra:x:65000:checker\n"), 0644)
+	runAsDefaultUser = "checker"
+
+	id := func(runAs string) string {
+		check := model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", "echo $(id -u) $(id -G)"}, RunAs: runAs}
+		result, _ := executeCommand(check, dir)
+		return result
+	}
+
+	if result := id(""); result != "65534 65534 65000" {
+		t.Fatal("Expected empty RunAs to use the default account", result)
+	}
+	if result := id(model.RunAsUnprivileged); result != "65534 65534 65000" {
+		t.Fatal("Expected default account", result)
+	}
+	if result := id("other"); result != "65533 65533" {
+		t.Fatal("Expected named account", result)
+	}
+	if result := id(model.RunAsPrivileged); !strings.HasPrefix(result, "0 ") {
+		t.Fatal("Expected privileged command", result)
+	}
+	if result := id("missing"); result != "invalid run as user" {
+		t.Fatal("Expected invalid run as user", result)
+	}
+
+	// default account not created yet
+	runAsDefaultUser = "missing"
+	if result := id(model.RunAsUnprivileged); result != "invalid run as user" {
+		t.Fatal("Expected invalid run as user", result)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	// negative pid is the process group
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if err != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// only an agent running as root can switch accounts, otherwise commands run
+// as the agent; only checks marked privileged keep root, checks stored before
+// RunAs were marked privileged by the server
+func setRunAs(cmd *exec.Cmd, runAs string) error {
+	if runAs == model.RunAsPrivileged || os.Geteuid() != 0 {
+		return nil
+	}
+	name := runAs
+	if len(name) == 0 || name == model.RunAsUnprivileged {
+		name = runAsDefaultUser
+	}
+	user, groups, err := readUserGroups(name)
+	if err != nil {
+		return err
+	}
+	if len(user.UID) == 0 {
+		return errors.New("user not found: " + name)
+	}
+
+	uid, err := strconv.ParseUint(user.UID, 10, 32)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.ParseUint(user.GID, 10, 32)
+	if err != nil {
+		return err
+	}
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}
+	for _, group := range groups {
+		id, err := strconv.ParseUint(group.GID, 10, 32)
+		if err != nil {
+			continue
+		}
+		credential.Groups = append(credential.Groups, uint32(id))
+	}
+	cmd.SysProcAttr.Credential = credential
+	return nil
+}
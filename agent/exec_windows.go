@@ -0,0 +1,33 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	// taskkill /T also ends child processes
+	err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	if err != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// no unprivileged account on Windows, commands run as the agent
+func setRunAs(cmd *exec.Cmd, runAs string) error {
+	if len(runAs) == 0 || runAs == model.RunAsPrivileged {
+		return nil
+	}
+	return errors.New("run as not supported")
+}
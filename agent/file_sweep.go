@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileSweepCacheMaxAge = 10 * time.Minute
+
+// change times are coarse, entries changed this close to a sweep could
+// change again with the same change time
+const fileSweepCacheRacyWindow = time.Second
+const fileSweepOutputCount string = "count"
+const fileSweepOutputDigest string = "digest"
+
+type fileSweepPredicate func(info os.FileInfo) bool
+
+type fileSweepCacheEntry struct {
+	timestamp time.Time
+	// every entry swept, files and directories
+	ctimes  map[string]int64
+	matches []string
+}
+
+var fileSweepCache = make(map[string]fileSweepCacheEntry)
+var fileSweepCacheLock sync.Mutex
+
+// args: root directory, predicate, output (count, digest), max depth, exclusion globs...
+func checkFileSweep(args []string) string {
+	if len(args) < 2 {
+		return "invalid arguments"
+	}
+	root := filepath.Clean(args[0])
+	predicate, err := parseFileSweepPredicate(args[1])
+	if err != nil {
+		return "invalid predicate"
+	}
+	output := fileSweepOutputCount
+	if len(args) > 2 && len(args[2]) > 0 {
+		output = args[2]
+	}
+	if output != fileSweepOutputCount && output != fileSweepOutputDigest {
+		return "invalid output"
+	}
+	maxDepth := -1
+	if len(args) > 3 && len(args[3]) > 0 {
+		maxDepth, err = strconv.Atoi(args[3])
+		if err != nil {
+			return "invalid max depth"
+		}
+	}
+	var excludes []string
+	if len(args) > 4 {
+		excludes = args[4:]
+	}
+
+	// output does not change the sweep
+	cacheKey := strings.Join(append([]string{root, args[1], strconv.Itoa(maxDepth)}, excludes...), "\x00")
+	matches, ok := fileSweepCacheGet(cacheKey)
+	if !ok {
+		var ctimes map[string]int64
+		start := time.Now()
+		matches, ctimes, err = fileSweep(root, maxDepth, excludes, predicate)
+		if err != nil {
+			return "could not read directory"
+		}
+		if !fileSweepRacy(ctimes, start) {
+			fileSweepCachePut(cacheKey, fileSweepCacheEntry{
+				timestamp: start,
+				ctimes:    ctimes,
+				matches:   matches,
+			})
+		}
+	}
+
+	if output == fileSweepOutputDigest {
+		return fileSweepDigest(matches)
+	}
+	return strconv.Itoa(len(matches))
+}
+
+// returns matches and the change time of every entry swept
+func fileSweep(root string, maxDepth int, excludes []string, predicate fileSweepPredicate) ([]string, map[string]int64, error) {
+	rootInfo, err := os.Lstat(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rootInfo.IsDir() {
+		return nil, nil, errors.New("not a directory: " + root)
+	}
+
+	matches := make([]string, 0)
+	ctimes := make(map[string]int64)
+	filepath.Walk(root, func(fp string, info os.FileInfo, err error) error {
+		if err != nil {
+			// unreadable entries are skipped, not fatal
+			if info != nil && info.IsDir() && fp != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fp != root && fileSweepExcluded(fp, excludes) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		depth := 0
+		if rel, err := filepath.Rel(root, fp); err == nil && rel != "." {
+			depth = strings.Count(rel, string(os.PathSeparator)) + 1
+		}
+		if maxDepth >= 0 && depth > maxDepth {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ctimes[fp] = fileChangeTime(info)
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		if predicate(info) {
+			matches = append(matches, fp)
+		}
+		return nil
+	})
+	sort.Strings(matches)
+
+	return matches, ctimes, nil
+}
+
+func fileSweepExcluded(fp string, excludes []string) bool {
+	base := filepath.Base(fp)
+	for _, pattern := range excludes {
+		if matched, _ := filepath.Match(pattern, fp); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func fileSweepDigest(matches []string) string {
+	h := sha256.New()
+	h.Write([]byte(strings.Join(matches, "\n")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func fileSweepRacy(ctimes map[string]int64, start time.Time) bool {
+	racy := start.Add(-fileSweepCacheRacyWindow).UnixNano()
+	for _, ctime := range ctimes {
+		if ctime >= racy {
+			return true
+		}
+	}
+	return false
+}
+
+func fileSweepCacheGet(key string) ([]string, bool) {
+	fileSweepCacheLock.Lock()
+	entry, present := fileSweepCache[key]
+	fileSweepCacheLock.Unlock()
+	if !present {
+		return nil, false
+	}
+	if time.Since(entry.timestamp) > fileSweepCacheMaxAge {
+		return nil, false
+	}
+	// added, removed, or renamed entries change their directory, permission
+	// and owner changes the file itself, so a file that just became SUID or
+	// world-writable is not missed
+	for fp, ctime := range entry.ctimes {
+		info, err := os.Lstat(fp)
+		if err != nil || fileChangeTime(info) != ctime {
+			return nil, false
+		}
+	}
+	return entry.matches, true
+}
+
+func fileSweepCachePut(key string, entry fileSweepCacheEntry) {
+	fileSweepCacheLock.Lock()
+	defer fileSweepCacheLock.Unlock()
+	fileSweepCache[key] = entry
+}
+
+// predicates: suid, sgid, world_writable, perm:<octal bits>, owner:<user>, group:<group>
+func parseFileSweepPredicate(s string) (fileSweepPredicate, error) {
+	name := s
+	value := ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		name = s[:i]
+		value = s[i+1:]
+	}
+
+	switch name {
+	case "suid":
+		return func(info os.FileInfo) bool {
+			return info.Mode()&os.ModeSetuid != 0
+		}, nil
+	case "sgid":
+		return func(info os.FileInfo) bool {
+			return info.Mode()&os.ModeSetgid != 0
+		}, nil
+	case "world_writable":
+		return func(info os.FileInfo) bool {
+			return info.Mode().Perm()&0002 != 0
+		}, nil
+	case "perm":
+		bits, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			return nil, err
+		}
+		mask := os.FileMode(bits & 0777)
+		if bits&04000 != 0 {
+			mask |= os.ModeSetuid
+		}
+		if bits&02000 != 0 {
+			mask |= os.ModeSetgid
+		}
+		if bits&01000 != 0 {
+			mask |= os.ModeSticky
+		}
+		return func(info os.FileInfo) bool {
+			return info.Mode()&mask != 0
+		}, nil
+	case "owner":
+		uid, err := lookupUserID(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(info os.FileInfo) bool {
+			fileUID, _, ok := fileOwnerIDs(info)
+			return ok && fileUID == uid
+		}, nil
+	case "group":
+		gid, err := lookupGroupID(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(info os.FileInfo) bool {
+			_, fileGID, ok := fileOwnerIDs(info)
+			return ok && fileGID == gid
+		}, nil
+	}
+
+	return nil, errors.New("unknown predicate: " + s)
+}
+
+func lookupUserID(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	return uint32(id), err
+}
+
+func lookupGroupID(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(id), err
+}
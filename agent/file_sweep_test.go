@@ -0,0 +1,169 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFileSweepPredicate(t *testing.T) {
+	_, err := parseFileSweepPredicate("bad")
+	if err == nil {
+		t.Fatal("Parsed unknown predicate")
+	}
+	_, err = parseFileSweepPredicate("perm:abc")
+	if err == nil {
+		t.Fatal("Parsed invalid perm predicate")
+	}
+	for _, s := range []string{"suid", "sgid", "world_writable", "perm:0002", "owner:0", "group:0"} {
+		_, err = parseFileSweepPredicate(s)
+		if err != nil {
+			t.Fatal("Could not parse predicate", s, err)
+		}
+	}
+}
+
+func TestFileSweep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file_sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+	os.MkdirAll(filepath.Join(dir, "skip"), 0755)
+	for _, fp := range []string{"1", "a/2", "a/b/3", "skip/4", "5"} {
+		ioutil.WriteFile(filepath.Join(dir, fp), []byte(""), 0644)
+	}
+	for _, fp := range []string{"1", "a/2", "a/b/3", "skip/4"} {
+		os.Chmod(filepath.Join(dir, fp), 0666)
+	}
+
+	predicate, _ := parseFileSweepPredicate("world_writable")
+
+	// no limits
+	matches, _, err := fileSweep(dir, -1, nil, predicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 4 {
+		t.Fatal("Unexpected match count", matches)
+	}
+	if matches[0] != filepath.Join(dir, "1") || matches[3] != filepath.Join(dir, "skip", "4") {
+		t.Fatal("Unexpected match order", matches)
+	}
+
+	// depth
+	matches, _, _ = fileSweep(dir, 1, nil, predicate)
+	if len(matches) != 1 {
+		t.Fatal("Unexpected match count with max depth 1", matches)
+	}
+	matches, _, _ = fileSweep(dir, 2, nil, predicate)
+	if len(matches) != 3 {
+		t.Fatal("Unexpected match count with max depth 2", matches)
+	}
+
+	// exclusions
+	matches, _, _ = fileSweep(dir, -1, []string{"skip"}, predicate)
+	if len(matches) != 3 {
+		t.Fatal("Unexpected match count with exclusion", matches)
+	}
+	matches, _, _ = fileSweep(dir, -1, []string{filepath.Join(dir, "a")}, predicate)
+	if len(matches) != 2 {
+		t.Fatal("Unexpected match count with path exclusion", matches)
+	}
+
+	// not a directory
+	_, _, err = fileSweep(filepath.Join(dir, "1"), -1, nil, predicate)
+	if err == nil {
+		t.Fatal("Swept a file")
+	}
+}
+
+func TestCheckFileSweep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file_sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if checkFileSweep([]string{dir}) != "invalid arguments" {
+		t.Fatal("Expected invalid arguments")
+	}
+	if checkFileSweep([]string{dir, "bad"}) != "invalid predicate" {
+		t.Fatal("Expected invalid predicate")
+	}
+	if checkFileSweep([]string{dir, "suid", "bad"}) != "invalid output" {
+		t.Fatal("Expected invalid output")
+	}
+	if checkFileSweep([]string{filepath.Join(dir, "missing"), "suid"}) != "could not read directory" {
+		t.Fatal("Expected could not read directory")
+	}
+
+	fp := filepath.Join(dir, "file")
+	ioutil.WriteFile(fp, []byte(""), 0644)
+	os.Chmod(fp, 0666)
+
+	args := []string{dir, "world_writable"}
+	if result := checkFileSweep(args); result != "1" {
+		t.Fatal("Unexpected result", result)
+	}
+	digest := checkFileSweep([]string{dir, "world_writable", "digest"})
+	if digest != fileSweepDigest([]string{fp}) {
+		t.Fatal("Unexpected digest", digest)
+	}
+
+	// permission change does not change directory modification time
+	os.Chmod(fp, 0644)
+	if result := checkFileSweep(args); result != "0" {
+		t.Fatal("Cached result not invalidated by permission change", result)
+	}
+
+	// a file that was not a match becoming one
+	os.Chmod(fp, 0666)
+	if result := checkFileSweep(args); result != "1" {
+		t.Fatal("Cached result not invalidated by new match", result)
+	}
+	os.Chmod(fp, 0644)
+
+	// new file changes directory modification time
+	fp2 := filepath.Join(dir, "file2")
+	ioutil.WriteFile(fp2, []byte(""), 0644)
+	os.Chmod(fp2, 0666)
+	if result := checkFileSweep(args); result != "1" {
+		t.Fatal("Cached result not invalidated by new file", result)
+	}
+}
+
+func TestFileSweepCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "file")
+	ioutil.WriteFile(fp, []byte(""), 0644)
+
+	predicate, _ := parseFileSweepPredicate("world_writable")
+	start := time.Now()
+	matches, ctimes, err := fileSweep(dir, -1, nil, predicate)
+	if err != nil || len(matches) != 0 {
+		t.Fatal("Unexpected sweep", matches, err)
+	}
+	if !fileSweepRacy(ctimes, start) {
+		t.Fatal("Expected just created entries to be racy")
+	}
+	fileSweepCachePut(dir, fileSweepCacheEntry{timestamp: start, ctimes: ctimes, matches: matches})
+	if _, ok := fileSweepCacheGet(dir); !ok {
+		t.Fatal("Expected cached sweep")
+	}
+
+	// change times are coarse, wait for the next one
+	time.Sleep(50 * time.Millisecond)
+	os.Chmod(fp, 0666)
+	if _, ok := fileSweepCacheGet(dir); ok {
+		t.Fatal("Expected cache invalidated by file becoming a match")
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const firewallBackendIptables string = "iptables"
+const firewallBackendIp6tables string = "ip6tables"
+const firewallBackendNftables string = "nftables"
+const firewallBackendUfw string = "ufw"
+const firewallPolicyNotFound string = "not found"
+
+var ufwConfFile = "/etc/ufw/ufw.conf"
+var ufwDefaultFile = "/etc/default/ufw"
+var ufwRulesFiles = []string{"/etc/ufw/user.rules", "/etc/ufw/user6.rules"}
+
+// ufw user chains are reported as the built-in chain they hook into
+var ufwChains = map[string]string{
+	"ufw-user-input":    "INPUT",
+	"ufw-user-output":   "OUTPUT",
+	"ufw-user-forward":  "FORWARD",
+	"ufw6-user-input":   "INPUT",
+	"ufw6-user-output":  "OUTPUT",
+	"ufw6-user-forward": "FORWARD",
+}
+
+var firewallCommandOutput = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+type firewallPortRange struct {
+	Low  int
+	High int
+}
+
+type firewallRule struct {
+	Chain    string
+	Protocol string
+	Ports    []firewallPortRange
+	Target   string
+	// matches on something not modelled, e.g. interface, address, state
+	Conditional bool
+}
+
+type firewallRuleset struct {
+	Policies map[string]string
+	Rules    []firewallRule
+}
+
+// args: backend, chain
+func checkFirewallPolicy(args []string) string {
+	if len(args) != 2 {
+		return "invalid arguments"
+	}
+	ruleset, result := readFirewallRuleset(args[0])
+	if ruleset == nil {
+		return result
+	}
+	policy, present := ruleset.Policies[strings.ToUpper(args[1])]
+	if !present {
+		return firewallPolicyNotFound
+	}
+	return policy
+}
+
+// args: backend, chain, target (ACCEPT, DROP, REJECT), protocol (empty for any), port (0 for any)
+func checkFirewallRule(args []string) string {
+	if len(args) < 3 {
+		return "invalid arguments"
+	}
+	protocol := ""
+	if len(args) > 3 {
+		protocol = strings.ToLower(args[3])
+	}
+	port := 0
+	if len(args) > 4 && len(args[4]) > 0 {
+		p, err := strconv.Atoi(args[4])
+		if err != nil {
+			return "invalid port"
+		}
+		port = p
+	}
+	ruleset, result := readFirewallRuleset(args[0])
+	if ruleset == nil {
+		return result
+	}
+	if ruleset.hasRule(strings.ToUpper(args[1]), strings.ToUpper(args[2]), protocol, port) {
+		return "true"
+	}
+	return "false"
+}
+
+// args: backend
+func checkFirewallEnabled(args []string) string {
+	if len(args) != 1 {
+		return "invalid arguments"
+	}
+	if args[0] == firewallBackendUfw {
+		bs, err := ioutil.ReadFile(ufwConfFile)
+		if err != nil {
+			return "could not read file"
+		}
+		value, _ := configValueKeyValue(bs, "ENABLED")
+		if strings.ToLower(value) == "yes" {
+			return "true"
+		}
+		return "false"
+	}
+	ruleset, result := readFirewallRuleset(args[0])
+	if ruleset == nil {
+		return result
+	}
+	if len(ruleset.Rules) > 0 {
+		return "true"
+	}
+	for _, policy := range ruleset.Policies {
+		if policy != "ACCEPT" {
+			return "true"
+		}
+	}
+	return "false"
+}
+
+// on failure, ruleset is nil and the result string is given
+func readFirewallRuleset(backend string) (*firewallRuleset, string) {
+	switch backend {
+	case firewallBackendIptables, firewallBackendIp6tables:
+		out, err := firewallCommandOutput(backend + "-save")
+		if err != nil {
+			return nil, "could not read firewall rules"
+		}
+		return parseIptablesSave(out), ""
+	case firewallBackendNftables:
+		out, err := firewallCommandOutput("nft", "-j", "list", "ruleset")
+		if err != nil {
+			return nil, "could not read firewall rules"
+		}
+		ruleset, err := parseNftRuleset(out)
+		if err != nil {
+			return nil, "invalid firewall rules"
+		}
+		return ruleset, ""
+	case firewallBackendUfw:
+		return readUfwRuleset()
+	}
+	return nil, "invalid backend"
+}
+
+func readUfwRuleset() (*firewallRuleset, string) {
+	ruleset := &firewallRuleset{Policies: make(map[string]string)}
+	bs, err := ioutil.ReadFile(ufwDefaultFile)
+	if err != nil {
+		return nil, "could not read file"
+	}
+	for _, chain := range []string{"INPUT", "OUTPUT", "FORWARD"} {
+		policy, found := configValueKeyValue(bs, "DEFAULT_"+chain+"_POLICY")
+		if found {
+			ruleset.Policies[chain] = strings.ToUpper(policy)
+		}
+	}
+	for _, fp := range ufwRulesFiles {
+		bs, err := ioutil.ReadFile(fp)
+		if err != nil {
+			continue
+		}
+		for _, rule := range parseIptablesSave(bs).Rules {
+			chain, present := ufwChains[rule.Chain]
+			if !present {
+				continue
+			}
+			rule.Chain = chain
+			ruleset.Rules = append(ruleset.Rules, rule)
+		}
+	}
+	return ruleset, ""
+}
+
+func (ruleset *firewallRuleset) hasRule(chain string, target string, protocol string, port int) bool {
+	for _, rule := range ruleset.Rules {
+		if rule.Conditional || rule.Chain != chain || rule.Target != target {
+			continue
+		}
+		// empty protocol and port 0 match any rule
+		if len(protocol) > 0 && len(rule.Protocol) > 0 && rule.Protocol != protocol {
+			continue
+		}
+		if port == 0 || len(rule.Ports) == 0 {
+			return true
+		}
+		for _, r := range rule.Ports {
+			if port >= r.Low && port <= r.High {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filter table only
+func parseIptablesSave(bs []byte) *firewallRuleset {
+	ruleset := &firewallRuleset{Policies: make(map[string]string)}
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "*") {
+			table = line[1:]
+			continue
+		}
+		if table != "filter" {
+			continue
+		}
+		fields := splitIptablesFields(line)
+		if strings.HasPrefix(line, ":") {
+			// user chains have no policy
+			if len(fields) > 1 && fields[1] != "-" {
+				ruleset.Policies[fields[0][1:]] = fields[1]
+			}
+			continue
+		}
+		if fields[0] != "-A" || len(fields) < 2 {
+			continue
+		}
+		ruleset.Rules = append(ruleset.Rules, parseIptablesRule(fields[1], fields[2:]))
+	}
+	return ruleset
+}
+
+// comments are double quoted and may contain spaces
+func splitIptablesFields(line string) []string {
+	fields := make([]string, 0)
+	var field strings.Builder
+	quoted := false
+	inField := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && quoted && i+1 < len(line) {
+			i++
+			field.WriteByte(line[i])
+		} else if c == '"' {
+			quoted = !quoted
+			inField = true
+		} else if (c == ' ' || c == '\t') && !quoted {
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		} else {
+			field.WriteByte(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+func parseIptablesRule(chain string, fields []string) firewallRule {
+	rule := firewallRule{Chain: chain}
+	for i := 0; i < len(fields); i++ {
+		value := ""
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+		switch fields[i] {
+		case "-p", "--protocol":
+			rule.Protocol = strings.ToLower(value)
+			i++
+		case "-m", "--match":
+			// modules are judged by their options
+			i++
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			ports, ok := parsePortRanges(value, ":")
+			if !ok {
+				rule.Conditional = true
+			}
+			rule.Ports = append(rule.Ports, ports...)
+			i++
+		case "-j", "--jump":
+			rule.Target = value
+			// ufw limit rules accept once under the limit
+			if strings.HasSuffix(value, "-limit-accept") {
+				rule.Target = "ACCEPT"
+			}
+			i++
+		case "--comment":
+			i++
+		default:
+			rule.Conditional = true
+		}
+	}
+	return rule
+}
+
+// e.g. 22,80,8000:8080
+func parsePortRanges(s string, sep string) ([]firewallPortRange, bool) {
+	ranges := make([]firewallPortRange, 0)
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, sep, 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, false
+		}
+		high := low
+		if len(bounds) == 2 {
+			high, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, false
+			}
+		}
+		ranges = append(ranges, firewallPortRange{Low: low, High: high})
+	}
+	return ranges, true
+}
+
+type nftChain struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Hook   string `json:"hook"`
+	Policy string `json:"policy"`
+}
+
+type nftRule struct {
+	Family string                       `json:"family"`
+	Table  string                       `json:"table"`
+	Chain  string                       `json:"chain"`
+	Expr   []map[string]json.RawMessage `json:"expr"`
+}
+
+type nftMatch struct {
+	Op    string                     `json:"op"`
+	Left  map[string]json.RawMessage `json:"left"`
+	Right json.RawMessage            `json:"right"`
+}
+
+// base chains are reported by hook, e.g. INPUT
+func parseNftRuleset(bs []byte) (*firewallRuleset, error) {
+	var doc struct {
+		Nftables []map[string]json.RawMessage `json:"nftables"`
+	}
+	err := json.Unmarshal(bs, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleset := &firewallRuleset{Policies: make(map[string]string)}
+	chainNames := make(map[string]string)
+	rules := make([]nftRule, 0)
+	for _, obj := range doc.Nftables {
+		if raw, present := obj["chain"]; present {
+			var chain nftChain
+			err = json.Unmarshal(raw, &chain)
+			if err != nil {
+				return nil, err
+			}
+			name := chain.Name
+			if chain.Type == "filter" && len(chain.Hook) > 0 {
+				name = strings.ToUpper(chain.Hook)
+				policy := strings.ToUpper(chain.Policy)
+				if len(policy) == 0 {
+					policy = "ACCEPT"
+				}
+				// a packet must pass every base chain on the hook
+				if current, present := ruleset.Policies[name]; !present || current == "ACCEPT" {
+					ruleset.Policies[name] = policy
+				}
+			}
+			chainNames[chain.Family+" "+chain.Table+" "+chain.Name] = name
+		} else if raw, present := obj["rule"]; present {
+			var rule nftRule
+			err = json.Unmarshal(raw, &rule)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+	}
+	for _, rule := range rules {
+		chain, present := chainNames[rule.Family+" "+rule.Table+" "+rule.Chain]
+		if !present {
+			chain = rule.Chain
+		}
+		ruleset.Rules = append(ruleset.Rules, parseNftRule(chain, rule.Expr))
+	}
+	return ruleset, nil
+}
+
+func parseNftRule(chain string, exprs []map[string]json.RawMessage) firewallRule {
+	rule := firewallRule{Chain: chain}
+	for _, expr := range exprs {
+		for key, raw := range expr {
+			switch key {
+			case "accept", "drop", "reject":
+				rule.Target = strings.ToUpper(key)
+			case "jump", "goto":
+				var verdict struct {
+					Target string `json:"target"`
+				}
+				json.Unmarshal(raw, &verdict)
+				rule.Target = verdict.Target
+			case "counter", "log", "comment":
+			case "match":
+				if !parseNftMatch(raw, &rule) {
+					rule.Conditional = true
+				}
+			default:
+				rule.Conditional = true
+			}
+		}
+	}
+	return rule
+}
+
+// protocol and destination port matches, false for anything else
+func parseNftMatch(raw json.RawMessage, rule *firewallRule) bool {
+	var match nftMatch
+	err := json.Unmarshal(raw, &match)
+	if err != nil || (match.Op != "==" && match.Op != "in") {
+		return false
+	}
+	if payload, present := match.Left["payload"]; present {
+		var p struct {
+			Protocol string `json:"protocol"`
+			Field    string `json:"field"`
+		}
+		json.Unmarshal(payload, &p)
+		if (p.Protocol == "ip" && p.Field == "protocol") || (p.Protocol == "ip6" && p.Field == "nexthdr") {
+			return json.Unmarshal(match.Right, &rule.Protocol) == nil
+		}
+		if p.Field != "dport" {
+			return false
+		}
+		rule.Protocol = p.Protocol
+		ports, ok := parseNftPorts(match.Right)
+		rule.Ports = append(rule.Ports, ports...)
+		return ok
+	}
+	if meta, present := match.Left["meta"]; present {
+		var m struct {
+			Key string `json:"key"`
+		}
+		json.Unmarshal(meta, &m)
+		if m.Key != "l4proto" {
+			return false
+		}
+		return json.Unmarshal(match.Right, &rule.Protocol) == nil
+	}
+	return false
+}
+
+// number, {"range": [low, high]} or {"set": [...]}
+func parseNftPorts(raw json.RawMessage) ([]firewallPortRange, bool) {
+	var port int
+	if json.Unmarshal(raw, &port) == nil {
+		return []firewallPortRange{{Low: port, High: port}}, true
+	}
+	var obj struct {
+		Range []int             `json:"range"`
+		Set   []json.RawMessage `json:"set"`
+	}
+	if json.Unmarshal(raw, &obj) != nil {
+		return nil, false
+	}
+	if len(obj.Range) == 2 {
+		return []firewallPortRange{{Low: obj.Range[0], High: obj.Range[1]}}, true
+	}
+	if obj.Set == nil {
+		return nil, false
+	}
+	ranges := make([]firewallPortRange, 0)
+	for _, element := range obj.Set {
+		r, ok := parseNftPorts(element)
+		if !ok {
+			return nil, false
+		}
+		ranges = append(ranges, r...)
+	}
+	return ranges, true
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testIptablesSave = `# Generated by iptables-save v1.8.7 on Mon Jan  1 00:00:00 2024
+*nat
+:PREROUTING ACCEPT [0:0]
+-A PREROUTING -p tcp --dport 8080 -j REDIRECT --to-ports 80
+COMMIT
+*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+:custom - [0:0]
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -i lo -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 22 -m comment --comment "allow ssh" -j ACCEPT
+-A INPUT -p tcp -m multiport --dports 80,443,8000:8010 -j ACCEPT
+-A INPUT -p udp --dport 53 -j DROP
+-A INPUT -p icmp -j ACCEPT
+COMMIT
+`
+
+const testNftRuleset = `{"nftables": [
+{"metainfo": {"version": "1.0.2", "release_name": "Lester Gooch", "json_schema_version": 1}},
+{"table": {"family": "inet", "name": "filter", "handle": 1}},
+{"chain": {"family": "inet", "table": "filter", "name": "input", "handle": 1, "type": "filter", "hook": "input", "prio": 0, "policy": "drop"}},
+{"chain": {"family": "inet", "table": "filter", "name": "forward", "handle": 2, "type": "filter", "hook": "forward", "prio": 0, "policy": "accept"}},
+{"chain": {"family": "inet", "table": "filter", "name": "services", "handle": 3}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "handle": 4, "expr": [{"match": {"op": "in", "left": {"ct": {"key": "state"}}, "right": ["established", "related"]}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "handle": 5, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 22}}, {"counter": {"packets": 0, "bytes": 0}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "handle": 6, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": {"set": [80, 443, {"range": [8000, 8010]}]}}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "handle": 7, "expr": [{"match": {"op": "!=", "left": {"payload": {"protocol": "udp", "field": "dport"}}, "right": 53}}, {"drop": null}]}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "handle": 8, "expr": [{"match": {"op": "==", "left": {"meta": {"key": "l4proto"}}, "right": "icmp"}}, {"jump": {"target": "services"}}]}},
+{"rule": {"family": "inet", "table": "filter", "chain": "services", "handle": 9, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "udp", "field": "dport"}}, "right": 123}}, {"reject": {"type": "icmpx", "expr": "port-unreachable"}}]}}
+]}`
+
+const testUfwUserRules = `*filter
+:ufw-user-input - [0:0]
+:ufw-user-output - [0:0]
+:ufw-user-forward - [0:0]
+:ufw-user-limit - [0:0]
+:ufw-user-limit-accept - [0:0]
+### RULES ###
+
+### tuple ### limit tcp 22 0.0.0.0/0 any 0.0.0.0/0 in
+-A ufw-user-input -p tcp --dport 22 -m conntrack --ctstate NEW -m recent --set
+-A ufw-user-input -p tcp --dport 22 -m conntrack --ctstate NEW -m recent --update --seconds 30 --hitcount 6 -j ufw-user-limit
+-A ufw-user-input -p tcp --dport 22 -j ufw-user-limit-accept
+
+### tuple ### deny tcp 23 0.0.0.0/0 any 0.0.0.0/0 in
+-A ufw-user-input -p tcp --dport 23 -j DROP
+
+### END RULES ###
+-A ufw-user-limit -j REJECT
+-A ufw-user-limit-accept -j ACCEPT
+COMMIT
+`
+
+func TestParsePortRanges(t *testing.T) {
+	ranges, ok := parsePortRanges("22,8000:8010", ":")
+	if !ok || len(ranges) != 2 {
+		t.Fatal("Unexpected ranges", ranges)
+	}
+	if ranges[0].Low != 22 || ranges[0].High != 22 || ranges[1].Low != 8000 || ranges[1].High != 8010 {
+		t.Fatal("Unexpected ranges", ranges)
+	}
+	_, ok = parsePortRanges("ssh", ":")
+	if ok {
+		t.Fatal("Expected invalid port range")
+	}
+}
+
+func TestSplitIptablesFields(t *testing.T) {
+	fields := splitIptablesFields(`-A INPUT -m comment --comment "allow \"web\" traffic" -j ACCEPT`)
+	if len(fields) != 8 || fields[5] != `allow "web" traffic` {
+		t.Fatal("Unexpected fields", fields)
+	}
+}
+
+func TestParseIptablesSave(t *testing.T) {
+	ruleset := parseIptablesSave([]byte(""))
+	if len(ruleset.Policies) != 0 || len(ruleset.Rules) != 0 {
+		t.Fatal("Parsed ruleset out of empty string")
+	}
+
+	ruleset = parseIptablesSave([]byte(testIptablesSave))
+	if len(ruleset.Policies) != 3 {
+		t.Fatal("Unexpected policies", ruleset.Policies)
+	}
+	if ruleset.Policies["INPUT"] != "DROP" || ruleset.Policies["OUTPUT"] != "ACCEPT" {
+		t.Fatal("Unexpected policies", ruleset.Policies)
+	}
+	if len(ruleset.Rules) != 6 {
+		t.Fatal("Unexpected rules", ruleset.Rules)
+	}
+	if !ruleset.Rules[0].Conditional || !ruleset.Rules[1].Conditional || ruleset.Rules[2].Conditional {
+		t.Fatal("Unexpected conditional rules", ruleset.Rules)
+	}
+
+	testFirewallRules(t, ruleset)
+	if !ruleset.hasRule("INPUT", "ACCEPT", "icmp", 0) {
+		t.Fatal("Expected icmp rule")
+	}
+	if !ruleset.hasRule("INPUT", "DROP", "udp", 53) {
+		t.Fatal("Expected udp 53 drop rule")
+	}
+}
+
+func TestParseNftRuleset(t *testing.T) {
+	_, err := parseNftRuleset([]byte("not json"))
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	ruleset, err := parseNftRuleset([]byte(testNftRuleset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ruleset.Policies) != 2 || ruleset.Policies["INPUT"] != "DROP" || ruleset.Policies["FORWARD"] != "ACCEPT" {
+		t.Fatal("Unexpected policies", ruleset.Policies)
+	}
+	if len(ruleset.Rules) != 6 {
+		t.Fatal("Unexpected rules", ruleset.Rules)
+	}
+
+	testFirewallRules(t, ruleset)
+	if ruleset.hasRule("INPUT", "DROP", "udp", 53) {
+		t.Fatal("Negated match should not count")
+	}
+	if !ruleset.hasRule("INPUT", "services", "icmp", 0) {
+		t.Fatal("Expected jump rule")
+	}
+	if !ruleset.hasRule("services", "REJECT", "udp", 123) {
+		t.Fatal("Expected reject rule")
+	}
+}
+
+func testFirewallRules(t *testing.T, ruleset *firewallRuleset) {
+	tests := []struct {
+		protocol string
+		port     int
+		expected bool
+	}{
+		{"tcp", 22, true},
+		{"tcp", 443, true},
+		{"tcp", 8005, true},
+		{"tcp", 8011, false},
+		{"udp", 22, false},
+		{"tcp", 25, false},
+	}
+	for _, test := range tests {
+		if ruleset.hasRule("INPUT", "ACCEPT", test.protocol, test.port) != test.expected {
+			t.Fatal("Unexpected rule match for", test.protocol, test.port)
+		}
+	}
+	if ruleset.hasRule("OUTPUT", "ACCEPT", "tcp", 22) {
+		t.Fatal("Matched rule in wrong chain")
+	}
+}
+
+func TestCheckFirewallIptables(t *testing.T) {
+	defer func(f func(string, ...string) ([]byte, error)) {
+		firewallCommandOutput = f
+	}(firewallCommandOutput)
+	firewallCommandOutput = func(name string, args ...string) ([]byte, error) {
+		if name == "iptables-save" {
+			return []byte(testIptablesSave), nil
+		}
+		if name == "nft" {
+			return []byte(testNftRuleset), nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	if checkFirewallPolicy([]string{"iptables"}) != "invalid arguments" {
+		t.Fatal("Expected invalid arguments")
+	}
+	if checkFirewallPolicy([]string{"pf", "INPUT"}) != "invalid backend" {
+		t.Fatal("Expected invalid backend")
+	}
+	if checkFirewallPolicy([]string{"ip6tables", "INPUT"}) != "could not read firewall rules" {
+		t.Fatal("Expected command failure")
+	}
+	if checkFirewallPolicy([]string{"iptables", "input"}) != "DROP" {
+		t.Fatal("Unexpected policy")
+	}
+	if checkFirewallPolicy([]string{"iptables", "custom"}) != firewallPolicyNotFound {
+		t.Fatal("Expected policy not found")
+	}
+	if checkFirewallPolicy([]string{"nftables", "forward"}) != "ACCEPT" {
+		t.Fatal("Unexpected policy")
+	}
+	if checkFirewallRule([]string{"iptables", "INPUT", "ACCEPT", "tcp", "ssh"}) != "invalid port" {
+		t.Fatal("Expected invalid port")
+	}
+	if checkFirewallRule([]string{"iptables", "INPUT", "accept", "tcp", "80"}) != "true" {
+		t.Fatal("Expected rule")
+	}
+	if checkFirewallRule([]string{"nftables", "INPUT", "ACCEPT", "tcp", "25"}) != "false" {
+		t.Fatal("Unexpected rule")
+	}
+	if checkFirewallRule([]string{"iptables", "INPUT", "ACCEPT", "", "22"}) != "true" {
+		t.Fatal("Expected rule for any protocol")
+	}
+	if checkFirewallRule([]string{"iptables", "INPUT", "DROP", "", "22"}) != "false" {
+		t.Fatal("Unexpected rule for any protocol")
+	}
+	if checkFirewallRule([]string{"iptables", "INPUT", "DROP", "udp", "0"}) != "true" {
+		t.Fatal("Expected rule for any port")
+	}
+	if checkFirewallRule([]string{"iptables", "INPUT", "DROP", "tcp", "0"}) != "false" {
+		t.Fatal("Unexpected rule for any port")
+	}
+	if checkFirewallEnabled([]string{"iptables"}) != "true" {
+		t.Fatal("Expected enabled")
+	}
+}
+
+func TestCheckFirewallUfw(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ufw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(confFile string, defaultFile string, rulesFiles []string) {
+		ufwConfFile = confFile
+		ufwDefaultFile = defaultFile
+		ufwRulesFiles = rulesFiles
+	}(ufwConfFile, ufwDefaultFile, ufwRulesFiles)
+	ufwConfFile = filepath.Join(dir, "ufw.conf")
+	ufwDefaultFile = filepath.Join(dir, "ufw")
+	ufwRulesFiles = []string{filepath.Join(dir, "user.rules"), filepath.Join(dir, "user6.rules")}
+
+	if checkFirewallEnabled([]string{"ufw"}) != "could not read file" {
+		t.Fatal("Expected could not read file")
+	}
+	if checkFirewallPolicy([]string{"ufw", "INPUT"}) != "could not read file" {
+		t.Fatal("Expected could not read file")
+	}
+
+	ioutil.WriteFile(ufwConfFile, []byte("# comment\nENABLED=no\nLOGLEVEL=low\n"), 0644)
+	ioutil.WriteFile(ufwDefaultFile, []byte("IPV6=yes\nDEFAULT_INPUT_POLICY=\"DROP\"\nDEFAULT_OUTPUT_POLICY=\"ACCEPT\"\nDEFAULT_FORWARD_POLICY=\"DROP\"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "user.rules"), []byte(testUfwUserRules), 0644)
+
+	if checkFirewallEnabled([]string{"ufw"}) != "false" {
+		t.Fatal("Expected disabled")
+	}
+	ioutil.WriteFile(ufwConfFile, []byte("ENABLED=yes\n"), 0644)
+	if checkFirewallEnabled([]string{"ufw"}) != "true" {
+		t.Fatal("Expected enabled")
+	}
+	if checkFirewallPolicy([]string{"ufw", "input"}) != "DROP" {
+		t.Fatal("Unexpected policy")
+	}
+	if checkFirewallPolicy([]string{"ufw", "OUTPUT"}) != "ACCEPT" {
+		t.Fatal("Unexpected policy")
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"ufw", "INPUT", "ACCEPT", "tcp", "22"}, "true"},
+		{[]string{"ufw", "INPUT", "DROP", "tcp", "23"}, "true"},
+		{[]string{"ufw", "INPUT", "ACCEPT", "tcp", "23"}, "false"},
+		{[]string{"ufw", "INPUT", "ACCEPT", "udp", "22"}, "false"},
+		// limit chain itself is not reported as INPUT
+		{[]string{"ufw", "INPUT", "REJECT"}, "false"},
+	}
+	for _, test := range tests {
+		result := checkFirewallRule(test.args)
+		if result != test.expected {
+			t.Fatal("Unexpected result for", test.args, result)
+		}
+	}
+}
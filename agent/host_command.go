@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func getHostCommands(serverURL string, scenarioID uint64, hostname string, role string, hostToken string) ([]model.HostCommand, error) {
+	log.Println("Read host commands")
+
+	scenarioIDStr := strconv.FormatUint(scenarioID, 10)
+	req, err := http.NewRequest("GET", serverURL+"/api/agent-commands/"+scenarioIDStr+hostQuery(hostname, role), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(model.HeaderHostToken, hostToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ERROR: could not get host commands: %d", resp.StatusCode)
+	}
+
+	var commands []model.HostCommand
+	err = json.NewDecoder(resp.Body).Decode(&commands)
+	if err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+func sendHostCommandResult(serverURL string, hostToken string, commandID uint64, result model.HostCommandResult) error {
+	bs, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", serverURL+"/api/agent-commands/results/"+strconv.FormatUint(commandID, 10), bytes.NewBuffer(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", applicationJSON)
+	req.Header.Set(model.HeaderHostToken, hostToken)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ERROR: could not send host command result: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// commands are sent again until a result is reported, so every result is
+// reported, even failures; every command type is safe to repeat
+func (r *scenarioRun) runHostCommands(serverURL string, hostname string, status *statusRecorder) {
+	scenario := "scenario " + strconv.FormatUint(r.ScenarioID, 10)
+	hostToken := r.hostToken
+	commands, err := getHostCommands(serverURL, r.ScenarioID, hostname, r.role, hostToken)
+	if err != nil {
+		log.Println("ERROR: unable to get host commands;", err)
+		status.recordError(scenario+": unable to get host commands", err)
+		return
+	}
+	for _, command := range commands {
+		log.Println("Running host command", command.ID, command.Type)
+		result := r.executeHostCommand(command, status)
+		if !result.Success {
+			status.recordError(scenario+": host command "+string(command.Type)+" failed", errors.New(result.Result))
+		}
+		// a re-enrolled host reports with the token the command came for
+		err = sendHostCommandResult(serverURL, hostToken, command.ID, result)
+		if err != nil {
+			log.Println("ERROR: unable to send host command result;", err)
+			status.recordError(scenario+": unable to send host command result", err)
+		}
+	}
+}
+
+func (r *scenarioRun) executeHostCommand(command model.HostCommand, status *statusRecorder) model.HostCommandResult {
+	if command.Type == model.HostCommandTypeApplyConfig {
+		results := executeConfig(command.Config)
+		summary := summarizeConfigResults(results)
+		for _, result := range results {
+			if len(result.Error) > 0 {
+				return model.HostCommandResult{Success: false, Result: summary}
+			}
+		}
+		return model.HostCommandResult{Success: true, Result: summary}
+	} else if command.Type == model.HostCommandTypeClearTeamKey {
+		err := removeDataFiles(r.dirData, fileNameTeamKey)
+		if err != nil {
+			return model.HostCommandResult{Success: false, Result: err.Error()}
+		}
+		r.teamKey = ""
+		status.updateScenario(r.ScenarioID, func(s *scenarioStatus) {
+			s.TeamKeyRegistered = false
+		})
+		log.Println("Team key cleared, run team setup again")
+		return model.HostCommandResult{Success: true, Result: "team key cleared"}
+	} else if command.Type == model.HostCommandTypeReEnroll {
+		// the next pass asks for a new host token, matched by role again
+		err := removeDataFiles(r.dirData, fileNameHostToken, fileNameHostRole, fileNameTeamKey)
+		if err != nil {
+			return model.HostCommandResult{Success: false, Result: err.Error()}
+		}
+		*r = *newScenarioRun(r.enrollment, r.nextTime)
+		status.updateScenario(r.ScenarioID, func(s *scenarioStatus) {
+			s.HostToken = ""
+			s.HostTokenIssued = false
+			s.Role = r.role
+			s.TeamKeyRegistered = false
+			s.ChecksLastModified = ""
+		})
+		log.Println("Host token cleared, run team setup again")
+		return model.HostCommandResult{Success: true, Result: "host token cleared"}
+	}
+	return model.HostCommandResult{Success: false, Result: "unsupported host command " + string(command.Type)}
+}
+
+func removeDataFiles(dirData string, fileNames ...string) error {
+	for _, fileName := range fileNames {
+		err := os.Remove(path.Join(dirData, fileName))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func TestRunHostCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-scoring-host-command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dirConfig := filepath.Join(dir, "config")
+	dirData := filepath.Join(dir, "data")
+	createDir(dirConfig)
+	createDir(dirData)
+	saveFile(dirData, fileNameHostToken, "token1")
+	saveFile(dirData, fileNameTeamKey, "key1")
+	file := filepath.Join(dir, "motd")
+
+	commands := []model.HostCommand{
+		{ID: 1, Type: model.HostCommandTypeApplyConfig, Config: []model.Action{{Type: model.ActionTypeFileWrite, Args: []string{file, "vulnerable"}}}},
+		{ID: 2, Type: model.HostCommandTypeClearTeamKey},
+		{ID: 3, Type: "REBOOT"},
+	}
+	results := make(map[string]model.HostCommandResult)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(model.HeaderHostToken) != "token1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == "GET" && r.URL.Path == "/api/agent-commands/4" {
+			json.NewEncoder(w).Encode(commands)
+			return
+		}
+		var result model.HostCommandResult
+		json.NewDecoder(r.Body).Decode(&result)
+		results[r.URL.Path] = result
+	}))
+	defer ts.Close()
+
+	status := newStatusRecorder(dirData)
+	run := newScenarioRun(enrollment{ScenarioID: 4, dirConfig: dirConfig, dirData: dirData}, time.Now())
+	run.teamKey = "key1"
+	run.runHostCommands(ts.URL, "host1", status)
+
+	if !results["/api/agent-commands/results/1"].Success {
+		t.Fatal("Expected config applied", results)
+	}
+	bs, _ := ioutil.ReadFile(file)
+	if string(bs) != "vulnerable" {
+		t.Fatal("Unexpected config file content", string(bs))
+	}
+	if !results["/api/agent-commands/results/2"].Success || len(run.teamKey) != 0 {
+		t.Fatal("Expected team key cleared", results)
+	}
+	if _, err := readTeamKey(dirData); err == nil {
+		t.Fatal("Expected team key file removed")
+	}
+	if results["/api/agent-commands/results/3"].Success {
+		t.Fatal("Expected unsupported command to fail", results)
+	}
+
+	// next pass asks for a new host token
+	commands = []model.HostCommand{{ID: 4, Type: model.HostCommandTypeReEnroll}}
+	run.runHostCommands(ts.URL, "host1", status)
+	if !results["/api/agent-commands/results/4"].Success || len(run.hostToken) != 0 {
+		t.Fatal("Expected host token cleared", results)
+	}
+	if _, err := readHostToken(dirData); err == nil {
+		t.Fatal("Expected host token file removed")
+	}
+}
+
+func TestScenarioRunCommandsWithoutTeamKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-scoring-host-command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dirConfig := filepath.Join(dir, "config")
+	dirData := filepath.Join(dir, "data")
+	createDir(dirConfig)
+	createDir(dirData)
+	saveFile(dirData, fileNameHostToken, "token1")
+	file := filepath.Join(dir, "motd")
+
+	results := make(map[string]model.HostCommandResult)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/scenario-checks/4" {
+			w.Header().Set(model.HeaderHostCommands, "1")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method == "GET" && r.URL.Path == "/api/agent-commands/4" {
+			json.NewEncoder(w).Encode([]model.HostCommand{{ID: 1, Type: model.HostCommandTypeApplyConfig, Config: []model.Action{{Type: model.ActionTypeFileWrite, Args: []string{file, "vulnerable"}}}}})
+			return
+		}
+		var result model.HostCommandResult
+		json.NewDecoder(r.Body).Decode(&result)
+		results[r.URL.Path] = result
+	}))
+	defer ts.Close()
+
+	// no team setup yet, admins can still reset the host
+	status := newStatusRecorder(dirData)
+	run := newScenarioRun(enrollment{ScenarioID: 4, dirConfig: dirConfig, dirData: dirData}, time.Now())
+	run.run(ts.URL, "host1", dir, nil, status)
+
+	if !results["/api/agent-commands/results/1"].Success {
+		t.Fatal("Expected config applied", results)
+	}
+	bs, _ := ioutil.ReadFile(file)
+	if string(bs) != "vulnerable" {
+		t.Fatal("Unexpected config file content", string(bs))
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path"
+)
+
+const fileNameRole string = "role"
+const fileNameHostRole string = "host_role"
+
+// the role the server matched when giving the host token wins over the one
+// declared in config, both survive the host being renamed
+func readRole(dirConfig string, dirData string) string {
+	bs, err := ioutil.ReadFile(path.Join(dirData, fileNameHostRole))
+	if err == nil && len(bs) > 0 {
+		return string(bs)
+	}
+	bs, err = ioutil.ReadFile(path.Join(dirConfig, fileNameRole))
+	if err == nil {
+		return string(bs)
+	}
+	return ""
+}
+
+func hostQuery(hostname string, role string) string {
+	query := "?hostname=" + url.QueryEscape(hostname)
+	if len(role) > 0 {
+		query += "&role=" + url.QueryEscape(role)
+	}
+	return query
+}
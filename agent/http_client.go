@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"time"
+)
+
+const fileNameCA string = "ca.pem"
+const fileNameProxy string = "proxy"
+const httpTimeout = time.Minute
+const httpDownloadTimeout = 30 * time.Minute
+
+// every server call goes through this client, replaced in main once the
+// proxy and CA settings are known
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+// empty proxy uses the standard proxy environment variables
+type httpSettings struct {
+	ProxyURL string
+	CAFile   string
+	Timeout  time.Duration
+}
+
+func userAgent() string {
+	v := version
+	if len(v) == 0 {
+		v = "dev"
+	}
+	return "cp-scoring-agent/" + v + " (" + runtime.GOOS + "; " + runtime.GOARCH + ")"
+}
+
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent())
+	return t.base.RoundTrip(req)
+}
+
+func newHTTPClient(settings httpSettings) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if len(settings.ProxyURL) > 0 {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil {
+			return nil, errors.New("invalid proxy URL " + settings.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{}
+	if len(settings.CAFile) > 0 {
+		bs, err := ioutil.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		// the bundle adds to the system roots
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, errors.New("no certificates in CA file " + settings.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	timeout := settings.Timeout
+	if timeout == 0 {
+		timeout = httpTimeout
+	}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Transport: userAgentTransport{base: transport},
+		Timeout:   timeout,
+	}, nil
+}
+
+// settings given now win over the ones saved by config
+func readHTTPSettings(settings agentSettings, dirConfig string) httpSettings {
+	s := httpSettings{
+		ProxyURL: settings.Proxy,
+		CAFile:   settings.CAFile,
+	}
+	if len(s.ProxyURL) == 0 {
+		bs, err := ioutil.ReadFile(path.Join(dirConfig, fileNameProxy))
+		if err == nil {
+			s.ProxyURL = string(bs)
+		}
+	}
+	if len(s.CAFile) == 0 {
+		caFile := path.Join(dirConfig, fileNameCA)
+		if _, err := os.Stat(caFile); err == nil {
+			s.CAFile = caFile
+		}
+	}
+	return s
+}
+
+// the running agent has no flags, so config keeps its own copies
+func saveHTTPSettings(dirConfig string, settings agentSettings) error {
+	if len(settings.Proxy) > 0 {
+		err := saveFile(dirConfig, fileNameProxy, settings.Proxy)
+		if err != nil {
+			return err
+		}
+	}
+	if len(settings.CAFile) > 0 {
+		bs, err := ioutil.ReadFile(settings.CAFile)
+		if err != nil {
+			return err
+		}
+		err = saveFile(dirConfig, fileNameCA, string(bs))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the whole body of a large download may take longer than one API call
+func downloadClient() *http.Client {
+	c := *httpClient
+	c.Timeout = httpDownloadTimeout
+	return &c
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewHTTPClientUserAgent(t *testing.T) {
+	var agent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get("User-Agent")
+	}))
+	defer ts.Close()
+
+	c, err := newHTTPClient(httpSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if agent != userAgent() || !strings.HasPrefix(agent, "cp-scoring-agent/") {
+		t.Fatal("Unexpected user agent", agent)
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	c, err := newHTTPClient(httpSettings{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get("http://cp-scoring.invalid/api/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if requested != "http://cp-scoring.invalid/api/version" {
+		t.Fatal("Expected request through proxy", requested)
+	}
+}
+
+func TestNewHTTPClientCAFile(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "cp-scoring-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// not trusted without the bundle
+	c, err := newHTTPClient(httpSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Get(ts.URL)
+	if err == nil {
+		t.Fatal("Expected unknown certificate authority")
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	bs := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	ioutil.WriteFile(caFile, bs, 0600)
+	c, err = newHTTPClient(httpSettings{CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	empty := filepath.Join(dir, "empty.pem")
+	ioutil.WriteFile(empty, []byte("none"), 0600)
+	_, err = newHTTPClient(httpSettings{CAFile: empty})
+	if err == nil {
+		t.Fatal("Expected error for CA file without certificates")
+	}
+}
+
+func TestReadHTTPSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-scoring-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := readHTTPSettings(agentSettings{}, dir)
+	if s != (httpSettings{}) {
+		t.Fatal("Expected no settings", s)
+	}
+
+	caFile := filepath.Join(dir, "bundle.pem")
+	ioutil.WriteFile(caFile, []byte("bundle"), 0600)
+	err = saveHTTPSettings(dir, agentSettings{Proxy: "http://proxy:3128", CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = readHTTPSettings(agentSettings{}, dir)
+	if s.ProxyURL != "http://proxy:3128" || s.CAFile != filepath.Join(dir, fileNameCA) {
+		t.Fatal("Unexpected saved settings", s)
+	}
+	s = readHTTPSettings(agentSettings{Proxy: "http://other:8080"}, dir)
+	if s.ProxyURL != "http://other:8080" {
+		t.Fatal("Expected given proxy to win", s)
+	}
+}
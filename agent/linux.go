@@ -11,6 +11,13 @@ import (
 type hostLinux struct {
 }
 
+// home and desktop folders, where team files are usually copied to
+var teamFileDirsLinux = []string{"/root", "/root/Desktop", "/home/*", "/home/*/Desktop"}
+
+func (h hostLinux) agentPath() string {
+	return filepath.Join(InstallPathLinux, FileAgentLinux)
+}
+
 func (h hostLinux) copyTeamFiles() error {
 	installPath := InstallPathLinux
 	currentDir, err := os.Getwd()
@@ -34,11 +41,16 @@ func (h hostLinux) copyTeamFiles() error {
 	copyFile(filepath.Join(installPath, fileName), filepath.Join(currentDir, fileName))
 	os.Chmod(filepath.Join(currentDir, fileName), 0755)
 
+	err = recordTeamFileCopies(installPath, currentDir)
+	if err != nil {
+		log.Println("ERROR: unable to record where team files were copied;", err)
+	}
+
 	log.Println("Finished copying files")
 	return nil
 }
 
-func (h hostLinux) install() error {
+func (h hostLinux) setup() error {
 	installPath := InstallPathLinux
 
 	// create installation folder
@@ -49,18 +61,26 @@ func (h hostLinux) install() error {
 	}
 	log.Println("Created installation folder: " + installPath)
 
-	// copy agent
-	log.Println("Copying this executable to installation folder")
-	ex, err := os.Executable()
+	// unprivileged account for checks
+	user, _, err := readUserGroups(AgentUserLinux)
 	if err != nil {
-		log.Println("ERROR: unable to copy executable")
+		log.Println("ERROR: unable to read accounts")
 		return err
 	}
-	binFile := filepath.Join(installPath, FileAgentLinux)
-	copyFile(ex, binFile)
-	err = os.Chmod(binFile, 0755)
+	if len(user.UID) == 0 {
+		log.Println("Creating account: " + AgentUserLinux)
+		err = exec.Command("/usr/sbin/useradd", "--system", "--no-create-home", "--shell", "/usr/sbin/nologin", AgentUserLinux).Run()
+		if err != nil {
+			log.Println("ERROR: unable to create account " + AgentUserLinux)
+			return err
+		}
+	}
+
+	// copy agent
+	log.Println("Copying this executable to installation folder")
+	err = copyAgent(h.agentPath())
 	if err != nil {
-		log.Println("ERROR: unable to set file permissions")
+		log.Println("ERROR: unable to copy executable")
 		return err
 	}
 
@@ -99,10 +119,90 @@ func (h hostLinux) install() error {
 		return err
 	}
 
+	return nil
+}
+
+func (h hostLinux) install() error {
+	err := h.setup()
+	if err != nil {
+		return err
+	}
 	log.Println("Finished install")
 	return nil
 }
 
+// config and data are left as they are
+func (h hostLinux) repair() error {
+	if _, err := os.Stat(InstallPathLinux); err != nil {
+		log.Println("ERROR: not installed in " + InstallPathLinux)
+		return err
+	}
+	err := h.setup()
+	if err != nil {
+		return err
+	}
+	log.Println("Finished repair")
+	return nil
+}
+
+func (h hostLinux) uninstall() error {
+	installPath := InstallPathLinux
+
+	log.Println("Removing service")
+	exec.Command("/bin/systemctl", "stop", "cp-scoring.service").Run()
+	exec.Command("/bin/systemctl", "disable", "cp-scoring.service").Run()
+	exec.Command("/bin/systemctl", "daemon-reload").Run()
+
+	removeTeamFileCopies(installPath, []string{FileReadmeHTML, "team_setup.sh", "team_setup.desktop"}, teamFileDirsLinux)
+
+	log.Println("Removing installation folder: " + installPath)
+	err := os.RemoveAll(installPath)
+	if err != nil {
+		log.Println("ERROR: unable to remove installation folder")
+		return err
+	}
+
+	user, _, err := readUserGroups(AgentUserLinux)
+	if err == nil && len(user.UID) > 0 {
+		log.Println("Removing account: " + AgentUserLinux)
+		err = exec.Command("/usr/sbin/userdel", AgentUserLinux).Run()
+		if err != nil {
+			log.Println("ERROR: unable to remove account " + AgentUserLinux)
+			return err
+		}
+	}
+
+	log.Println("Finished uninstall")
+	return nil
+}
+
+// rename over the running binary is atomic, the old inode stays open until
+// restart
+func (h hostLinux) replaceAgent(binary []byte) error {
+	binFile := h.agentPath()
+	newFile := binFile + ".new"
+	err := ioutil.WriteFile(newFile, binary, 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(newFile, 0755)
+	if err != nil {
+		os.Remove(newFile)
+		return err
+	}
+	err = os.Rename(newFile, binFile)
+	if err != nil {
+		os.Remove(newFile)
+		return err
+	}
+	return nil
+}
+
+// --no-block, as systemd stops this process while restarting
+func (h hostLinux) restartAgent() error {
+	return exec.Command("/bin/systemctl", "--no-block", "restart", "cp-scoring.service").Run()
+}
+
 func getSystemdScript() []byte {
 	return []byte(`[Unit]
 Description=cp-scoring
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const pamModuleNotFound string = "module not found"
+
+// vendor directory is used when a service is not in /etc
+var pamConfDirs = []string{"/etc/pam.d", "/usr/lib/pam.d"}
+
+// modules that read defaults from their own config file
+var pamModuleConfFiles = map[string]string{
+	"pam_faillock":  "/etc/security/faillock.conf",
+	"pam_pwhistory": "/etc/security/pwhistory.conf",
+	"pam_pwquality": "/etc/security/pwquality.conf",
+}
+
+var errPAMServiceNotFound = errors.New("PAM service not found")
+
+type pamEntry struct {
+	Type    string
+	Control string
+	Module  string
+	Options map[string]string
+}
+
+// args: service, type (auth, account, password, session), module, option
+func checkPAMSetting(args []string) string {
+	if len(args) != 4 {
+		return "invalid arguments"
+	}
+	entries, err := readPAMStack(args[0], args[1], 0)
+	if err != nil {
+		return "could not read file"
+	}
+	module := pamModuleName(args[2])
+	option := args[3]
+
+	moduleFound := false
+	for _, entry := range entries {
+		if entry.Module != module {
+			continue
+		}
+		moduleFound = true
+		if value, present := entry.Options[option]; present {
+			return value
+		}
+	}
+	if !moduleFound {
+		return pamModuleNotFound
+	}
+
+	// option given on the module line takes precedence over the module config
+	fp, present := pamModuleConfFiles[module]
+	if present {
+		bs, err := ioutil.ReadFile(fp)
+		if err == nil {
+			value, found := configValueKeyValue(bs, option)
+			if found {
+				if len(value) == 0 {
+					return "true"
+				}
+				return value
+			}
+		}
+	}
+	return configValueNotFound
+}
+
+// pam_unix.so, /lib/security/pam_unix.so and pam_unix are the same module
+func pamModuleName(module string) string {
+	return strings.TrimSuffix(filepath.Base(module), ".so")
+}
+
+func pamServiceFile(service string) (string, error) {
+	// services are names, not paths
+	if strings.ContainsAny(service, "/") || service == "." || service == ".." {
+		return "", errPAMServiceNotFound
+	}
+	for _, dir := range pamConfDirs {
+		fp := filepath.Join(dir, service)
+		if _, err := os.Stat(fp); err == nil {
+			return fp, nil
+		}
+	}
+	return "", errPAMServiceNotFound
+}
+
+// entries of the given type, with includes and substacks expanded in place
+func readPAMStack(service string, pamType string, depth int) ([]pamEntry, error) {
+	if depth > configIncludeMaxDepth {
+		return nil, errConfigIncludeDepth
+	}
+	fp, err := pamServiceFile(service)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]pamEntry, 0)
+	for _, line := range parsePAMLines(bs) {
+		if line[0] == "@include" {
+			if len(line) < 2 {
+				continue
+			}
+			included, err := readPAMStack(line[1], pamType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, included...)
+			continue
+		}
+		if len(line) < 3 || strings.TrimPrefix(line[0], "-") != pamType {
+			continue
+		}
+		control := line[1]
+		if control == "include" || control == "substack" {
+			included, err := readPAMStack(line[2], pamType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, included...)
+			continue
+		}
+		entries = append(entries, pamEntry{
+			Type:    pamType,
+			Control: control,
+			Module:  pamModuleName(line[2]),
+			Options: parsePAMOptions(line[3:]),
+		})
+	}
+	return entries, nil
+}
+
+// fields of each line, with bracketed controls and options kept whole
+func parsePAMLines(bs []byte) [][]string {
+	lines := make([][]string, 0)
+	joined := ""
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasSuffix(line, "\\") {
+			joined += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		line = joined + line
+		joined = ""
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := splitPAMFields(line)
+		if len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	return lines
+}
+
+func splitPAMFields(line string) []string {
+	fields := make([]string, 0)
+	var field strings.Builder
+	bracketed := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '[' && field.Len() == 0 {
+			bracketed = true
+		} else if c == ']' && bracketed {
+			bracketed = false
+		}
+		if (c == ' ' || c == '\t') && !bracketed {
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			continue
+		}
+		field.WriteByte(c)
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// flags without a value are "true"
+func parsePAMOptions(fields []string) map[string]string {
+	options := make(map[string]string)
+	for _, field := range fields {
+		field = strings.TrimSuffix(strings.TrimPrefix(field, "["), "]")
+		if i := strings.Index(field, "="); i >= 0 {
+			options[field[:i]] = field[i+1:]
+		} else {
+			options[field] = "true"
+		}
+	}
+	return options
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitPAMFields(t *testing.T) {
+	fields := splitPAMFields("auth\t[success=1 default=ignore]  pam_unix.so nullok")
+	if len(fields) != 4 || fields[1] != "[success=1 default=ignore]" || fields[3] != "nullok" {
+		t.Fatal("Unexpected fields", fields)
+	}
+	fields = splitPAMFields("   ")
+	if len(fields) != 0 {
+		t.Fatal("Unexpected fields", fields)
+	}
+}
+
+func TestParsePAMLines(t *testing.T) {
+	bs := []byte("# comment\n\npassword requisite pam_pwquality.so retry=3 \\\n  minlen=12 # trailing\n@include common-auth\n")
+	lines := parsePAMLines(bs)
+	if len(lines) != 2 {
+		t.Fatal("Unexpected lines", lines)
+	}
+	if len(lines[0]) != 5 || lines[0][4] != "minlen=12" {
+		t.Fatal("Unexpected line", lines[0])
+	}
+	if lines[1][0] != "@include" || lines[1][1] != "common-auth" {
+		t.Fatal("Unexpected line", lines[1])
+	}
+}
+
+func TestParsePAMOptions(t *testing.T) {
+	options := parsePAMOptions([]string{"minlen=12", "enforce_for_root", "[default=die]"})
+	if options["minlen"] != "12" || options["enforce_for_root"] != "true" || options["default"] != "die" {
+		t.Fatal("Unexpected options", options)
+	}
+	if pamModuleName("/lib/x86_64-linux-gnu/security/pam_unix.so") != "pam_unix" {
+		t.Fatal("Unexpected module name")
+	}
+}
+
+func TestCheckPAMSetting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(confDirs []string, confFiles map[string]string) {
+		pamConfDirs = confDirs
+		pamModuleConfFiles = confFiles
+	}(pamConfDirs, pamModuleConfFiles)
+	dirEtc := filepath.Join(dir, "etc")
+	dirVendor := filepath.Join(dir, "vendor")
+	pamConfDirs = []string{dirEtc, dirVendor}
+	pamModuleConfFiles = map[string]string{"pam_pwquality": filepath.Join(dir, "pwquality.conf")}
+	os.MkdirAll(dirEtc, 0755)
+	os.MkdirAll(dirVendor, 0755)
+
+	ioutil.WriteFile(filepath.Join(dirEtc, "common-password"), []byte("password requisite pam_pwquality.so retry=3\npassword [success=1 default=ignore] pam_unix.so obscure use_authtok yescrypt remember=5\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirEtc, "common-auth"), []byte("auth required pam_faillock.so preauth\nauth [success=1 default=ignore] pam_unix.so nullok\nauth [default=die] pam_faillock.so authfail deny=5\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirEtc, "passwd"), []byte("@include common-password\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirEtc, "sshd"), []byte("@include common-auth\naccount required pam_nologin.so\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirVendor, "login"), []byte("auth substack system-login\n-session optional pam_systemd.so\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirVendor, "system-login"), []byte("auth include common-auth\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirEtc, "loop"), []byte("auth include loop\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "pwquality.conf"), []byte("# minlen = 8\nminlen = 14\nenforce_for_root\n"), 0644)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"passwd", "password"}, "invalid arguments"},
+		{[]string{"missing", "password", "pam_unix", "remember"}, "could not read file"},
+		{[]string{"../passwd", "password", "pam_unix", "remember"}, "could not read file"},
+		{[]string{"loop", "auth", "pam_unix", "nullok"}, "could not read file"},
+		{[]string{"passwd", "password", "pam_unix.so", "remember"}, "5"},
+		{[]string{"passwd", "password", "pam_unix", "use_authtok"}, "true"},
+		{[]string{"passwd", "password", "pam_unix", "sha512"}, configValueNotFound},
+		{[]string{"passwd", "password", "pam_pwquality", "retry"}, "3"},
+		{[]string{"passwd", "password", "pam_pwquality", "minlen"}, "14"},
+		{[]string{"passwd", "password", "pam_pwquality", "enforce_for_root"}, "true"},
+		{[]string{"passwd", "auth", "pam_unix", "nullok"}, pamModuleNotFound},
+		{[]string{"sshd", "auth", "pam_faillock", "deny"}, "5"},
+		{[]string{"sshd", "account", "pam_faillock", "deny"}, pamModuleNotFound},
+		{[]string{"login", "auth", "pam_faillock", "deny"}, "5"},
+		{[]string{"login", "session", "pam_systemd", "debug"}, configValueNotFound},
+	}
+	for _, test := range tests {
+		result := checkPAMSetting(test.args)
+		if result != test.expected {
+			t.Fatal("Unexpected result for", test.args, result)
+		}
+	}
+}
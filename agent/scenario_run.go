@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+	"golang.org/x/crypto/openpgp"
+)
+
+// check state of one enrollment, kept between runs
+type scenarioRun struct {
+	enrollment
+	hostToken    string
+	role         string
+	teamKey      string
+	lastModified string
+	schedule     checkSchedule
+	checks       []model.Action
+	nextTime     time.Time
+	// nextTime with jitter
+	due time.Time
+}
+
+func newScenarioRun(e enrollment, now time.Time) *scenarioRun {
+	hostToken, _ := readHostToken(e.dirData)
+	return &scenarioRun{
+		enrollment:   e,
+		hostToken:    hostToken,
+		role:         e.role(),
+		lastModified: "Thu, 01 Jan 1970 00:00:00 GMT",
+		schedule:     defaultCheckSchedule,
+		nextTime:     now,
+		due:          now,
+	}
+}
+
+func (r *scenarioRun) run(serverURL string, hostname string, outputDir string, entities []*openpgp.Entity, status *statusRecorder) {
+	scenario := "scenario " + strconv.FormatUint(r.ScenarioID, 10)
+	if len(r.hostToken) == 0 {
+		hostToken, hostRole, err := requestHostToken(r.dirData, serverURL, r.ScenarioID, hostname, r.role)
+		if err != nil {
+			log.Println("ERROR: could not get host token;", err)
+			status.recordError(scenario+": could not get host token", err)
+		} else {
+			log.Println("Saving host token")
+			r.hostToken = hostToken
+			err = saveFile(r.dirData, fileNameHostToken, hostToken)
+			if err != nil {
+				log.Println("ERROR: unable to save host token;", err)
+			}
+			if len(hostRole) > 0 && hostRole != r.role {
+				log.Println("Host matched to role " + hostRole)
+				r.role = hostRole
+				err = saveFile(r.dirData, fileNameHostRole, hostRole)
+				if err != nil {
+					log.Println("ERROR: unable to save host role;", err)
+				}
+			}
+			status.updateScenario(r.ScenarioID, func(s *scenarioStatus) {
+				s.HostToken = hostToken
+				s.HostTokenIssued = true
+				s.Role = r.role
+			})
+		}
+	}
+	if len(r.hostToken) == 0 {
+		return
+	}
+
+	// make sure team key registered before doing scenario checks
+	if len(r.teamKey) == 0 {
+		r.teamKey, _ = readTeamKey(r.dirData)
+		if len(r.teamKey) > 0 {
+			status.updateScenario(r.ScenarioID, func(s *scenarioStatus) {
+				s.TeamKeyRegistered = true
+			})
+		}
+	}
+
+	// checks are fetched without a team key too, the response says whether
+	// commands are queued
+	checks, lastModified, schedule, commands, err := getScenarioChecks(serverURL, r.ScenarioID, hostname, r.role, r.hostToken, r.lastModified, r.schedule)
+	if err != nil {
+		log.Println("ERROR: unable to get checks;", err)
+		status.recordError(scenario+": unable to get checks", err)
+	}
+	if schedule != r.schedule {
+		log.Println("Check interval", schedule.Interval, "jitter", schedule.Jitter, "for", scenario)
+		r.schedule = schedule
+	}
+	if checks != nil {
+		// keep the old checks until their scripts are in place
+		scripts, err := getScenarioScripts(serverURL, r.ScenarioID, hostname, r.role)
+		if err == nil {
+			err = installScripts(scripts, r.dirTemp, entities)
+		}
+		if err != nil {
+			log.Println("ERROR: unable to install scripts;", err)
+			status.recordError(scenario+": unable to install scripts", err)
+		} else {
+			r.checks = checks
+			r.lastModified = lastModified
+		}
+	}
+	if err == nil {
+		status.updateScenario(r.ScenarioID, func(s *scenarioStatus) {
+			s.LastChecksFetch = time.Now().Unix()
+			s.ChecksLastModified = r.lastModified
+		})
+	}
+	// admins reset hosts through queued commands, also before team setup
+	if commands > 0 {
+		r.runHostCommands(serverURL, hostname, status)
+	}
+	if len(r.hostToken) == 0 || len(r.teamKey) == 0 {
+		return
+	}
+	if r.checks != nil {
+		executeScenarioChecks(r.ScenarioID, r.hostToken, r.checks, r.lastModified, outputDir, r.dirTemp, entities)
+	}
+}
+
+// each scenario keeps its own interval
+func (r *scenarioRun) advance() {
+	r.nextTime = r.nextTime.Add(r.schedule.Interval)
+	r.due = r.nextTime.Add(r.schedule.offset())
+}
+
+// runs the scenarios that are due, returns when to run again; enrollments
+// added since the last pass start now
+func runScenarios(runs map[uint64]*scenarioRun, enrollments []enrollment, now time.Time, run func(r *scenarioRun)) time.Time {
+	next := now.Add(defaultCheckSchedule.Interval)
+	for _, e := range enrollments {
+		r, present := runs[e.ScenarioID]
+		if !present {
+			r = newScenarioRun(e, now)
+			runs[e.ScenarioID] = r
+		}
+		if !now.Before(r.due) {
+			run(r)
+			r.advance()
+		}
+		if r.due.Before(next) {
+			next = r.due
+		}
+	}
+	return next
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+const checkIntervalDefault = time.Minute
+const checkIntervalMin = 5 * time.Second
+
+type checkSchedule struct {
+	Interval time.Duration
+	Jitter   time.Duration
+}
+
+var defaultCheckSchedule = checkSchedule{Interval: checkIntervalDefault}
+
+// missing or invalid headers keep the current schedule, 0 is the default
+func parseCheckSchedule(header http.Header, current checkSchedule) checkSchedule {
+	schedule := current
+	if value := header.Get(model.HeaderCheckInterval); len(value) > 0 {
+		seconds, err := strconv.Atoi(value)
+		if err == nil && seconds >= 0 {
+			schedule.Interval = time.Duration(seconds) * time.Second
+			if seconds == 0 {
+				schedule.Interval = checkIntervalDefault
+			} else if schedule.Interval < checkIntervalMin {
+				schedule.Interval = checkIntervalMin
+			}
+		}
+	}
+	if value := header.Get(model.HeaderCheckJitter); len(value) > 0 {
+		seconds, err := strconv.Atoi(value)
+		if err == nil && seconds >= 0 {
+			schedule.Jitter = time.Duration(seconds) * time.Second
+		}
+	}
+	return schedule
+}
+
+// random offset from the run time, so hosts started together spread out
+func (schedule checkSchedule) offset() time.Duration {
+	if schedule.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(schedule.Jitter)))
+}
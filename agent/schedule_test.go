@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func TestParseCheckSchedule(t *testing.T) {
+	header := func(interval string, jitter string) http.Header {
+		h := http.Header{}
+		if len(interval) > 0 {
+			h.Set(model.HeaderCheckInterval, interval)
+		}
+		if len(jitter) > 0 {
+			h.Set(model.HeaderCheckJitter, jitter)
+		}
+		return h
+	}
+	current := checkSchedule{Interval: 2 * time.Minute, Jitter: 10 * time.Second}
+
+	tests := []struct {
+		header   http.Header
+		expected checkSchedule
+	}{
+		{header("300", "30"), checkSchedule{5 * time.Minute, 30 * time.Second}},
+		{header("0", "0"), checkSchedule{checkIntervalDefault, 0}},
+		{header("1", ""), checkSchedule{checkIntervalMin, 10 * time.Second}},
+		{header("", ""), current},
+		{header("-5", "soon"), current},
+	}
+	for i, test := range tests {
+		schedule := parseCheckSchedule(test.header, current)
+		if schedule != test.expected {
+			t.Fatal("Unexpected schedule for test", i, schedule)
+		}
+	}
+
+	if defaultCheckSchedule.offset() != 0 {
+		t.Fatal("Expected no offset without jitter")
+	}
+	for i := 0; i < 100; i++ {
+		offset := current.offset()
+		if offset < 0 || offset >= current.Jitter {
+			t.Fatal("Unexpected offset", offset)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const scheduledJobOutputCount string = "count"
+const scheduledJobOutputExists string = "exists"
+
+var cronSystemFiles = []string{"/etc/crontab"}
+var cronSystemDirs = []string{"/etc/cron.d"}
+
+// file name is the user
+var cronUserDirs = []string{"/var/spool/cron/crontabs", "/var/spool/cron"}
+
+// scripts run by run-parts
+var cronPeriodicDirs = map[string]string{
+	"@hourly":  "/etc/cron.hourly",
+	"@daily":   "/etc/cron.daily",
+	"@weekly":  "/etc/cron.weekly",
+	"@monthly": "/etc/cron.monthly",
+}
+
+var cronVariable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*=`)
+
+type scheduledJob struct {
+	Source   string
+	User     string
+	Schedule string
+	Command  string
+}
+
+// args: command regex, user, schedule regex, output (exists, count)
+func checkScheduledJob(args []string) string {
+	if len(args) < 1 {
+		return "invalid arguments"
+	}
+	rgxCommand, err := regexp.Compile(args[0])
+	if err != nil {
+		return "invalid regex"
+	}
+	user := ""
+	if len(args) > 1 {
+		user = args[1]
+	}
+	var rgxSchedule *regexp.Regexp
+	if len(args) > 2 && len(args[2]) > 0 {
+		rgxSchedule, err = regexp.Compile(args[2])
+		if err != nil {
+			return "invalid regex"
+		}
+	}
+	output := scheduledJobOutputExists
+	if len(args) > 3 && len(args[3]) > 0 {
+		output = args[3]
+	}
+	if output != scheduledJobOutputExists && output != scheduledJobOutputCount {
+		return "invalid output"
+	}
+
+	count := 0
+	for _, job := range getScheduledJobs() {
+		if !rgxCommand.MatchString(job.Command) {
+			continue
+		}
+		if len(user) > 0 && job.User != user {
+			continue
+		}
+		if rgxSchedule != nil && !rgxSchedule.MatchString(job.Schedule) {
+			continue
+		}
+		count++
+	}
+
+	if output == scheduledJobOutputCount {
+		return strconv.Itoa(count)
+	}
+	if count > 0 {
+		return "true"
+	}
+	return "false"
+}
+
+func getScheduledJobs() []scheduledJob {
+	jobs := make([]scheduledJob, 0)
+
+	systemFiles := append([]string{}, cronSystemFiles...)
+	for _, dir := range cronSystemDirs {
+		systemFiles = append(systemFiles, cronDirFiles(dir)...)
+	}
+	for _, fp := range systemFiles {
+		bs, err := ioutil.ReadFile(fp)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, parseCrontab(bs, "", fp)...)
+	}
+
+	for _, dir := range cronUserDirs {
+		for _, fp := range cronDirFiles(dir) {
+			bs, err := ioutil.ReadFile(fp)
+			if err != nil {
+				continue
+			}
+			jobs = append(jobs, parseCrontab(bs, filepath.Base(fp), fp)...)
+		}
+	}
+
+	schedules := make([]string, 0, len(cronPeriodicDirs))
+	for schedule := range cronPeriodicDirs {
+		schedules = append(schedules, schedule)
+	}
+	sort.Strings(schedules)
+	for _, schedule := range schedules {
+		for _, fp := range cronDirFiles(cronPeriodicDirs[schedule]) {
+			jobs = append(jobs, scheduledJob{
+				Source:   fp,
+				User:     "root",
+				Schedule: schedule,
+				Command:  fp,
+			})
+		}
+	}
+
+	jobs = append(jobs, getSystemdTimerJobs()...)
+
+	return jobs
+}
+
+// regular files, skipping hidden files and editor backups
+func cronDirFiles(dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0)
+	for _, file := range files {
+		name := file.Name()
+		if !file.Mode().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths
+}
+
+// user is empty for system crontabs, which have a user field
+func parseCrontab(bs []byte, user string, source string) []scheduledJob {
+	jobs := make([]scheduledJob, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || cronVariable.MatchString(line) {
+			continue
+		}
+		fields := strings.Fields(line)
+		scheduleFields := 5
+		if strings.HasPrefix(fields[0], "@") {
+			scheduleFields = 1
+		}
+		commandField := scheduleFields
+		if len(user) == 0 {
+			commandField++
+		}
+		if len(fields) <= commandField {
+			continue
+		}
+		job := scheduledJob{
+			Source:   source,
+			User:     user,
+			Schedule: strings.Join(fields[:scheduleFields], " "),
+			Command:  strings.Join(fields[commandField:], " "),
+		}
+		if len(user) == 0 {
+			job.User = fields[scheduleFields]
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// enabled timers, with the command and user of the unit they activate
+func getSystemdTimerJobs() []scheduledJob {
+	jobs := make([]scheduledJob, 0)
+
+	timers := make(map[string]bool)
+	for _, dir := range systemdUnitDirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.timer"))
+		for _, match := range matches {
+			timers[filepath.Base(match)] = true
+		}
+	}
+	names := make([]string, 0, len(timers))
+	for name := range timers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if systemdUnitFileState(name, 0) != systemdStateEnabled {
+			continue
+		}
+		fp, found := systemdFindUnit(name)
+		if !found {
+			continue
+		}
+		bs, err := ioutil.ReadFile(fp)
+		if err != nil {
+			continue
+		}
+		job := parseSystemdTimer(bs, name)
+		job.Source = fp
+
+		serviceFile, found := systemdFindUnit(job.Command)
+		if !found {
+			continue
+		}
+		bs, err = ioutil.ReadFile(serviceFile)
+		if err != nil {
+			continue
+		}
+		service := parseSystemdUnitSection(bs, "Service")
+		job.Command = parseSystemdExecStart(bs)
+		job.User = "root"
+		if len(service["User"]) > 0 {
+			job.User = service["User"][0]
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// Command is set to the unit activated by the timer
+func parseSystemdTimer(bs []byte, name string) scheduledJob {
+	timer := parseSystemdUnitSection(bs, "Timer")
+	schedules := make([]string, 0)
+	for _, key := range []string{"OnActiveSec", "OnBootSec", "OnCalendar", "OnStartupSec", "OnUnitActiveSec", "OnUnitInactiveSec"} {
+		if len(timer[key]) > 0 {
+			schedules = append(schedules, key+"="+strings.Join(timer[key], " "))
+		}
+	}
+	unit := strings.TrimSuffix(name, ".timer") + ".service"
+	if len(timer["Unit"]) > 0 {
+		unit = timer["Unit"][0]
+	}
+	return scheduledJob{
+		Schedule: strings.Join(schedules, ";"),
+		Command:  unit,
+	}
+}
+
+// ExecStart lines are not split on whitespace like other settings
+func parseSystemdExecStart(bs []byte) string {
+	commands := make([]string, 0)
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+		if section != "Service" || !strings.HasPrefix(line, "ExecStart") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 || strings.TrimSpace(line[:i]) != "ExecStart" {
+			continue
+		}
+		// prefixes change how the command is run, not what is run
+		command := strings.TrimLeft(strings.TrimSpace(line[i+1:]), "@-:+!")
+		if len(command) == 0 {
+			commands = commands[:0]
+			continue
+		}
+		commands = append(commands, command)
+	}
+	return strings.Join(commands, "; ")
+}
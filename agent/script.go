@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/netwayfind/cp-scoring/model"
+	"github.com/netwayfind/cp-scoring/processing"
+	"golang.org/x/crypto/openpgp"
+)
+
+// installed scripts are named <hash>-<name>
+var scriptFileRegex = regexp.MustCompile(`^[0-9a-f]{64}-`)
+
+// hashes from the server's script list, by temp directory and script name;
+// files on disk are only trusted to match these
+var installedScripts = struct {
+	sync.RWMutex
+	hashes map[string]string
+}{hashes: make(map[string]string)}
+
+// writes scripts to the temp directory and removes scripts no longer in the
+// scenario; scripts must be signed by the server, unless entities is nil for
+// a dry run of local files
+func installScripts(scripts []model.Script, tempDir string, entities openpgp.EntityList) error {
+	keep := make(map[string]bool)
+	hashes := make(map[string]string)
+	for _, script := range scripts {
+		if !processing.ValidScriptName(script.Name) {
+			return errors.New("invalid script name " + script.Name)
+		}
+		if processing.ScriptHash([]byte(script.Body)) != script.Hash {
+			return errors.New("hash mismatch for script " + script.Name)
+		}
+		if entities != nil {
+			err := processing.VerifyDetached([]byte(script.Body), []byte(script.Signature), entities)
+			if err != nil {
+				return errors.New("could not verify signature for script " + script.Name + "; " + err.Error())
+			}
+		}
+		hashes[filepath.Join(tempDir, script.Name)] = script.Hash
+		fileName := script.Hash + "-" + script.Name
+		keep[fileName] = true
+		// readable by the unprivileged account checks run as
+		err := ioutil.WriteFile(filepath.Join(tempDir, fileName), []byte(script.Body), 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	installedScripts.Lock()
+	for key := range installedScripts.hashes {
+		if filepath.Dir(key) == tempDir {
+			delete(installedScripts.hashes, key)
+		}
+	}
+	for key, hash := range hashes {
+		installedScripts.hashes[key] = hash
+	}
+	installedScripts.Unlock()
+
+	fileInfos, err := ioutil.ReadDir(tempDir)
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		if scriptFileRegex.MatchString(fileInfo.Name()) && !keep[fileInfo.Name()] {
+			os.Remove(filepath.Join(tempDir, fileInfo.Name()))
+		}
+	}
+
+	return nil
+}
+
+// returns the path of the installed script, after checking its content
+// still matches the hash the server listed for it
+func resolveScript(name string, tempDir string) (string, error) {
+	if !processing.ValidScriptName(name) {
+		return "", errors.New("invalid script name")
+	}
+	installedScripts.RLock()
+	hash, found := installedScripts.hashes[filepath.Join(tempDir, name)]
+	installedScripts.RUnlock()
+	if !found {
+		return "", os.ErrNotExist
+	}
+	path := filepath.Join(tempDir, hash+"-"+name)
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if processing.ScriptHash(bs) != hash {
+		return "", errors.New("hash mismatch")
+	}
+	// commands run in the temp directory
+	return filepath.Abs(path)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/netwayfind/cp-scoring/model"
+	"github.com/netwayfind/cp-scoring/processing"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestInstallScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	defer runAsTestUser()()
+	dir, err := ioutil.TempDir("", "script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pubKey, privKey, err := processing.NewPubPrivKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privEntities, _ := openpgp.ReadArmoredKeyRing(bytes.NewReader(privKey))
+	entities, _ := openpgp.ReadArmoredKeyRing(bytes.NewReader(pubKey))
+
+	script := func(name string, body string) model.Script {
+		signature, _ := processing.SignDetached([]byte(body), privEntities[0])
+		return model.Script{Name: name, Body: body, Hash: processing.ScriptHash([]byte(body)), Signature: string(signature)}
+	}
+	old := script("check.sh", "#!/bin/sh\necho old\n")
+	current := script("check.sh", "#!/bin/sh\necho \"$1\"\n")
+	other := script("other.sh", "echo other \"$1\"\n")
+	ioutil.WriteFile(filepath.Join(dir, "unrelated"), []byte{}, 0644)
+
+	err = installScripts([]model.Script{old}, dir, entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = installScripts([]model.Script{current, other}, dir, entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileInfos, _ := ioutil.ReadDir(dir)
+	if len(fileInfos) != 3 {
+		t.Fatal("Expected old script to be removed", len(fileInfos))
+	}
+	if _, err = os.Stat(filepath.Join(dir, "unrelated")); err != nil {
+		t.Fatal("Expected other files to be kept")
+	}
+
+	bad := script("bad.sh", "true")
+	bad.Body = "rm -rf /"
+	if installScripts([]model.Script{bad}, dir, entities) == nil {
+		t.Fatal("Expected hash mismatch")
+	}
+	if installScripts([]model.Script{script("../bad.sh", "true")}, dir, entities) == nil {
+		t.Fatal("Expected invalid name")
+	}
+	unsigned := script("unsigned.sh", "true")
+	unsigned.Signature = ""
+	if installScripts([]model.Script{unsigned}, dir, entities) == nil {
+		t.Fatal("Expected missing signature")
+	}
+	forged := script("forged.sh", "true")
+	forged.Body = "rm -rf /"
+	forged.Hash = processing.ScriptHash([]byte(forged.Body))
+	if installScripts([]model.Script{forged}, dir, entities) == nil {
+		t.Fatal("Expected bad signature")
+	}
+
+	// run directly, args given to the script
+	result, _ := executeCommand(model.Action{Type: model.ActionTypeExec, Script: "check.sh", Args: []string{"arg"}}, dir)
+	if result != "arg" {
+		t.Fatal("Unexpected result", result)
+	}
+	// run by an interpreter
+	result, _ = executeCommand(model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Script: "other.sh", Args: []string{"-e"}}, dir)
+	if result != "other" {
+		t.Fatal("Unexpected result", result)
+	}
+	result, _ = executeCommand(model.Action{Type: model.ActionTypeExec, Script: "missing.sh"}, dir)
+	if result != "script not found" {
+		t.Fatal("Expected script not found", result)
+	}
+
+	// changed after install
+	ioutil.WriteFile(filepath.Join(dir, other.Hash+"-other.sh"), []byte("echo changed\n"), 0700)
+	result, _ = executeCommand(model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Script: "other.sh"}, dir)
+	if result != "invalid script" {
+		t.Fatal("Expected invalid script", result)
+	}
+
+	// replaced by a file named for its own hash
+	replaced := script("check.sh", "echo replaced\n")
+	os.Remove(filepath.Join(dir, current.Hash+"-check.sh"))
+	ioutil.WriteFile(filepath.Join(dir, replaced.Hash+"-check.sh"), []byte(replaced.Body), 0700)
+	result, _ = executeCommand(model.Action{Type: model.ActionTypeExec, Command: "/bin/sh", Script: "check.sh"}, dir)
+	if result != "script not found" {
+		t.Fatal("Expected replaced script to be refused", result)
+	}
+}
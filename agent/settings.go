@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// environment variables for unattended setup
+const (
+	envServer      = "CP_SCORING_SERVER"
+	envScenario    = "CP_SCORING_SCENARIO"
+	envEnrollToken = "CP_SCORING_ENROLL_TOKEN"
+	envTeamKey     = "CP_SCORING_TEAM_KEY"
+	envRole        = "CP_SCORING_ROLE"
+	envProxy       = "CP_SCORING_PROXY"
+	envCAFile      = "CP_SCORING_CA_FILE"
+)
+
+type agentSettings struct {
+	ServerURL   string
+	ScenarioID  string
+	EnrollToken string
+	TeamKey     string
+	Role        string
+	Proxy       string
+	CAFile      string
+}
+
+// flags win over environment variables, which win over the settings file
+func resolveSettings(flags agentSettings, getenv func(string) string, settingsFile string) (agentSettings, error) {
+	var settings agentSettings
+	if len(settingsFile) > 0 {
+		bs, err := ioutil.ReadFile(settingsFile)
+		if err != nil {
+			return settings, err
+		}
+		settings, err = parseSettingsFile(bs)
+		if err != nil {
+			return settings, err
+		}
+	}
+
+	pick := func(current string, env string, flag string) string {
+		if len(flag) > 0 {
+			return flag
+		}
+		if value := getenv(env); len(value) > 0 {
+			return value
+		}
+		return current
+	}
+	settings.ServerURL = pick(settings.ServerURL, envServer, flags.ServerURL)
+	settings.ScenarioID = pick(settings.ScenarioID, envScenario, flags.ScenarioID)
+	settings.EnrollToken = pick(settings.EnrollToken, envEnrollToken, flags.EnrollToken)
+	settings.TeamKey = pick(settings.TeamKey, envTeamKey, flags.TeamKey)
+	settings.Role = pick(settings.Role, envRole, flags.Role)
+	settings.Proxy = pick(settings.Proxy, envProxy, flags.Proxy)
+	settings.CAFile = pick(settings.CAFile, envCAFile, flags.CAFile)
+	return validateSettings(settings)
+}
+
+// empty settings are valid, they are asked for when needed
+func validateSettings(settings agentSettings) (agentSettings, error) {
+	// remove trailing slash
+	settings.ServerURL = strings.TrimRight(strings.TrimSpace(settings.ServerURL), "/")
+	if len(settings.ServerURL) > 0 {
+		u, err := url.Parse(settings.ServerURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return settings, errors.New("invalid server URL " + settings.ServerURL)
+		}
+	}
+	settings.ScenarioID = strings.TrimSpace(settings.ScenarioID)
+	if len(settings.ScenarioID) > 0 {
+		_, err := strconv.ParseUint(settings.ScenarioID, 10, 64)
+		if err != nil {
+			return settings, errors.New("invalid scenario " + settings.ScenarioID)
+		}
+	}
+	settings.Proxy = strings.TrimSpace(settings.Proxy)
+	if len(settings.Proxy) > 0 {
+		u, err := url.Parse(settings.Proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || len(u.Host) == 0 {
+			return settings, errors.New("invalid proxy URL " + settings.Proxy)
+		}
+	}
+	settings.CAFile = strings.TrimSpace(settings.CAFile)
+	settings.Role = strings.TrimSpace(settings.Role)
+	return settings, nil
+}
+
+// same format as the server config file, one setting and value per line
+func parseSettingsFile(bs []byte) (agentSettings, error) {
+	var settings agentSettings
+	for _, line := range strings.Split(string(bs), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens := strings.Fields(line)
+		if len(tokens) != 2 {
+			return settings, errors.New("invalid settings line " + tokens[0])
+		}
+		if tokens[0] == "server" {
+			settings.ServerURL = tokens[1]
+		} else if tokens[0] == "scenario" {
+			settings.ScenarioID = tokens[1]
+		} else if tokens[0] == "enroll_token" {
+			settings.EnrollToken = tokens[1]
+		} else if tokens[0] == "team_key" {
+			settings.TeamKey = tokens[1]
+		} else if tokens[0] == "role" {
+			settings.Role = tokens[1]
+		} else if tokens[0] == "proxy" {
+			settings.Proxy = tokens[1]
+		} else if tokens[0] == "ca_file" {
+			settings.CAFile = tokens[1]
+		} else {
+			return settings, errors.New("unknown setting " + tokens[0])
+		}
+	}
+	return settings, nil
+}
+
+func stdinIsTerminal() bool {
+	fileInfo, err := os.Stdin.Stat()
+	return err == nil && fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// asking without a terminal would block automated builds, so fail instead
+func prompt(name string) (string, error) {
+	if !stdinIsTerminal() {
+		return "", errors.New("no terminal to ask for " + name)
+	}
+	log.Println("Enter " + name + ": ")
+	var value string
+	_, err := fmt.Scan(&value)
+	return strings.TrimSpace(value), err
+}
+
+func exitWith(code int, v ...interface{}) {
+	log.Println(v...)
+	os.Exit(code)
+}
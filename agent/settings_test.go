@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "agent.conf")
+	ioutil.WriteFile(file, []byte("# image build\nserver http://file:8000/\nscenario 1\nenroll_token file-token\n\nteam_key file-key\n"), 0600)
+	env := map[string]string{
+		envScenario: "2",
+		envTeamKey:  "env-key",
+	}
+	getenv := func(name string) string {
+		return env[name]
+	}
+
+	settings, err := resolveSettings(agentSettings{TeamKey: "flag-key"}, getenv, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := agentSettings{ServerURL: "http://file:8000", ScenarioID: "2", EnrollToken: "file-token", TeamKey: "flag-key"}
+	if settings != expected {
+		t.Fatal("Unexpected settings", settings)
+	}
+
+	// nothing set, asked for later
+	settings, err = resolveSettings(agentSettings{}, func(string) string { return "" }, "")
+	if err != nil || settings != (agentSettings{}) {
+		t.Fatal("Expected empty settings", settings, err)
+	}
+
+	invalid := []agentSettings{
+		{ServerURL: "file:8000"},
+		{ServerURL: "ftp://file"},
+		{Proxy: "proxy:3128"},
+		{ScenarioID: "one"},
+	}
+	for _, flags := range invalid {
+		_, err = resolveSettings(flags, getenv, "")
+		if err == nil {
+			t.Fatal("Expected invalid settings", flags)
+		}
+	}
+
+	ioutil.WriteFile(file, []byte("password secret\n"), 0600)
+	_, err = resolveSettings(agentSettings{}, getenv, file)
+	if err == nil {
+		t.Fatal("Expected unknown setting")
+	}
+	_, err = resolveSettings(agentSettings{}, getenv, filepath.Join(dir, "missing"))
+	if err == nil {
+		t.Fatal("Expected missing file")
+	}
+}
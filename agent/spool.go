@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+const spoolInterval = 5 * time.Second
+const spoolBackoffMax = 10 * time.Minute
+const spoolMaxBytes int64 = 50 * 1024 * 1024
+const quarantineMaxBytes int64 = 10 * 1024 * 1024
+
+type spoolOutcome int
+
+const (
+	spoolRetry spoolOutcome = iota
+	spoolDelete
+	spoolQuarantine
+)
+
+// doubles the wait after each failure, jitter keeps hosts that went offline
+// together from coming back together
+type backoff struct {
+	Min      time.Duration
+	Max      time.Duration
+	failures int
+}
+
+func (b *backoff) failure() time.Duration {
+	wait := b.Min << uint(b.failures)
+	if wait > b.Max || wait <= 0 {
+		wait = b.Max
+	} else {
+		b.failures++
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func (b *backoff) reset() {
+	b.failures = 0
+}
+
+// client errors will not succeed on retry, except the ones about timing
+func spoolOutcomeForStatus(status int) spoolOutcome {
+	if status >= 200 && status < 300 {
+		return spoolDelete
+	}
+	if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		return spoolQuarantine
+	}
+	return spoolRetry
+}
+
+// oldest first, without files still being written
+func spoolFiles(dir string) ([]os.FileInfo, error) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]os.FileInfo, 0, len(fileInfos))
+	for _, fileInfo := range fileInfos {
+		if fileInfo.Mode().IsRegular() && !strings.HasSuffix(fileInfo.Name(), tempFileSuffix) {
+			files = append(files, fileInfo)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].ModTime().Equal(files[j].ModTime()) {
+			return files[i].Name() < files[j].Name()
+		}
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	return files, nil
+}
+
+// removes the oldest files until the directory fits
+func enforceSpoolLimit(dir string, maxBytes int64) error {
+	files, err := spoolFiles(dir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, file := range files {
+		total += file.Size()
+	}
+	for _, file := range files {
+		if total <= maxBytes {
+			break
+		}
+		log.Println("Spool full, removing " + file.Name())
+		err = os.Remove(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+		total -= file.Size()
+	}
+	return nil
+}
+
+func settleSpoolFile(dir string, quarantineDir string, name string, outcome spoolOutcome) {
+	filePath := filepath.Join(dir, name)
+	if outcome == spoolDelete {
+		os.Remove(filePath)
+	} else if outcome == spoolQuarantine {
+		log.Println("Server rejected results, quarantining " + name)
+		err := os.Rename(filePath, filepath.Join(quarantineDir, name))
+		if err != nil {
+			log.Println("ERROR: unable to quarantine results file;", err)
+			os.Remove(filePath)
+		}
+	}
+}
+
+// sends the oldest results first, an error means the rest should wait
+func executeSubmitScenarioCheckResults(serverURL string, outputDir string, quarantineDir string) error {
+	err := enforceSpoolLimit(outputDir, spoolMaxBytes)
+	if err != nil {
+		log.Println("ERROR: cannot limit results directory;", err)
+	}
+	err = enforceSpoolLimit(quarantineDir, quarantineMaxBytes)
+	if err != nil {
+		log.Println("ERROR: cannot limit quarantine directory;", err)
+	}
+
+	files, err := spoolFiles(outputDir)
+	if err != nil {
+		log.Println("ERROR: cannot read results directory;", err)
+		return nil
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	log.Println("Sending results to server")
+
+	for len(files) > 0 {
+		count := len(files)
+		if count > model.AuditBatchMaxSize {
+			count = model.AuditBatchMaxSize
+		}
+		batch := files[:count]
+		files = files[count:]
+
+		names := make([]string, 0, len(batch))
+		bodies := make([][]byte, 0, len(batch))
+		for _, file := range batch {
+			bs, err := ioutil.ReadFile(filepath.Join(outputDir, file.Name()))
+			if err != nil {
+				log.Println("ERROR: unable to read results file;", err)
+				continue
+			}
+			names = append(names, file.Name())
+			bodies = append(bodies, bs)
+		}
+		if len(bodies) == 0 {
+			continue
+		}
+
+		// results sent before an error are settled too
+		outcomes, err := submitResultsBatch(serverURL, bodies)
+		failed := false
+		for i, outcome := range outcomes {
+			settleSpoolFile(outputDir, quarantineDir, names[i], outcome)
+			if outcome == spoolRetry {
+				failed = true
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if failed {
+			return errors.New("server could not accept all results")
+		}
+	}
+	return nil
+}
+
+// falls back to one request per result for servers without batches
+func submitResultsBatch(serverURL string, bodies [][]byte) ([]spoolOutcome, error) {
+	bs, err := json.Marshal(bodies)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Post(serverURL+"/api/audit/batch", applicationJSON, bytes.NewBuffer(bs))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	outcomes := make([]spoolOutcome, len(bodies))
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		for i, body := range bodies {
+			r, err := httpClient.Post(serverURL+"/api/audit/", applicationOctetStream, bytes.NewBuffer(body))
+			if err != nil {
+				return outcomes[:i], err
+			}
+			r.Body.Close()
+			outcomes[i] = spoolOutcomeForStatus(r.StatusCode)
+		}
+		return outcomes, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected server response: %d", resp.StatusCode)
+	}
+
+	var statuses []int
+	err = json.NewDecoder(resp.Body).Decode(&statuses)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) != len(bodies) {
+		return nil, fmt.Errorf("%d statuses for %d results", len(statuses), len(bodies))
+	}
+	for i, status := range statuses {
+		outcomes[i] = spoolOutcomeForStatus(status)
+	}
+	return outcomes, nil
+}
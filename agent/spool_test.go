@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExecuteSubmitScenarioCheckResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputDir := filepath.Join(dir, "results")
+	quarantineDir := filepath.Join(dir, "quarantine")
+	os.Mkdir(outputDir, 0700)
+	os.Mkdir(quarantineDir, 0700)
+
+	// result body is the status the server gives it
+	writeResult := func(name string, status int, age time.Duration) {
+		file := filepath.Join(outputDir, name)
+		ioutil.WriteFile(file, []byte(strconv.Itoa(status)), 0600)
+		modTime := time.Now().Add(-age)
+		os.Chtimes(file, modTime, modTime)
+	}
+	writeResult("accepted", http.StatusOK, 3*time.Minute)
+	writeResult("rejected", http.StatusBadRequest, 2*time.Minute)
+	writeResult("failed", http.StatusInternalServerError, time.Minute)
+
+	batches := 0
+	singles := 0
+	batchSupported := true
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/audit/batch", func(w http.ResponseWriter, r *http.Request) {
+		if !batchSupported {
+			http.NotFound(w, r)
+			return
+		}
+		batches++
+		var bodies [][]byte
+		json.NewDecoder(r.Body).Decode(&bodies)
+		statuses := make([]int, len(bodies))
+		for i, body := range bodies {
+			statuses[i], _ = strconv.Atoi(string(body))
+		}
+		json.NewEncoder(w).Encode(statuses)
+	})
+	mux.HandleFunc("/api/audit/", func(w http.ResponseWriter, r *http.Request) {
+		singles++
+		body, _ := ioutil.ReadAll(r.Body)
+		status, _ := strconv.Atoi(string(body))
+		w.WriteHeader(status)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	err = executeSubmitScenarioCheckResults(server.URL, outputDir, quarantineDir)
+	if err == nil {
+		t.Fatal("Expected error for result server could not accept")
+	}
+	if batches != 1 || singles != 0 {
+		t.Fatal("Expected one batch", batches, singles)
+	}
+	if _, err = os.Stat(filepath.Join(outputDir, "accepted")); !os.IsNotExist(err) {
+		t.Fatal("Expected accepted result to be deleted")
+	}
+	if _, err = os.Stat(filepath.Join(quarantineDir, "rejected")); err != nil {
+		t.Fatal("Expected rejected result in quarantine")
+	}
+	if _, err = os.Stat(filepath.Join(outputDir, "failed")); err != nil {
+		t.Fatal("Expected failed result to be kept")
+	}
+
+	// older server
+	batchSupported = false
+	writeResult("failed", http.StatusCreated, time.Minute)
+	err = executeSubmitScenarioCheckResults(server.URL, outputDir, quarantineDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if singles != 1 {
+		t.Fatal("Expected single result request", singles)
+	}
+	files, _ := ioutil.ReadDir(outputDir)
+	if len(files) != 0 {
+		t.Fatal("Expected results directory to be empty", len(files))
+	}
+
+	// server down, nothing lost
+	writeResult("offline", http.StatusOK, 0)
+	server.Close()
+	err = executeSubmitScenarioCheckResults(server.URL, outputDir, quarantineDir)
+	if err == nil {
+		t.Fatal("Expected error for server down")
+	}
+	if _, err = os.Stat(filepath.Join(outputDir, "offline")); err != nil {
+		t.Fatal("Expected result to be kept")
+	}
+}
+
+func TestEnforceSpoolLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 5; i++ {
+		file := filepath.Join(dir, strconv.Itoa(i))
+		ioutil.WriteFile(file, make([]byte, 10), 0600)
+		modTime := time.Now().Add(time.Duration(i-5) * time.Minute)
+		os.Chtimes(file, modTime, modTime)
+	}
+	err = enforceSpoolLimit(dir, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := spoolFiles(dir)
+	if len(files) != 2 || files[0].Name() != "3" || files[1].Name() != "4" {
+		t.Fatal("Expected oldest files removed", files)
+	}
+
+	// results still being written are not sent
+	ioutil.WriteFile(filepath.Join(dir, "5"+tempFileSuffix), make([]byte, 10), 0600)
+	files, _ = spoolFiles(dir)
+	if len(files) != 2 {
+		t.Fatal("Expected temp file skipped", files)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := backoff{Min: time.Second, Max: 8 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for _, max := range expected {
+		wait := b.failure()
+		if wait < max/2 || wait > max {
+			t.Fatal("Unexpected wait", wait, max)
+		}
+	}
+	b.reset()
+	if wait := b.failure(); wait > time.Second {
+		t.Fatal("Expected wait to reset", wait)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// changes with content, permissions and owner
+func fileChangeTime(info os.FileInfo) int64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime().UnixNano()
+	}
+	return stat.Ctim.Sec*int64(1e9) + stat.Ctim.Nsec
+}
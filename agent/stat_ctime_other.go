@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"os"
+)
+
+// no change time, only the modification time
+func fileChangeTime(info os.FileInfo) int64 {
+	return info.ModTime().UnixNano()
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"os"
+)
+
+func fileOwnerIDs(info os.FileInfo) (uint32, uint32, bool) {
+	// no uid, gid on windows
+	return 0, 0, false
+}
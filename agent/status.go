@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const fileNameStatus = "status.json"
+const fileNameStatusScript = "status.js"
+const statusMaxErrors = 10
+
+type statusError struct {
+	Timestamp int64
+	Message   string
+}
+
+// times are unix seconds, zero if it has not happened yet
+type scenarioStatus struct {
+	ScenarioID uint64
+	Role       string
+	// the status files are readable by every local user, so only -status,
+	// run as root, shows the token itself
+	HostToken          string `json:"-"`
+	HostTokenIssued    bool
+	TeamKeyRegistered  bool
+	LastChecksFetch    int64
+	ChecksLastModified string
+}
+
+type agentStatus struct {
+	ServerURL       string
+	Scenarios       []scenarioStatus
+	LastSubmission  int64
+	SpoolDepth      int
+	QuarantineDepth int
+	RecentErrors    []statusError
+	Updated         int64
+}
+
+func (status *agentStatus) scenario(scenarioID uint64) *scenarioStatus {
+	for i := range status.Scenarios {
+		if status.Scenarios[i].ScenarioID == scenarioID {
+			return &status.Scenarios[i]
+		}
+	}
+	status.Scenarios = append(status.Scenarios, scenarioStatus{ScenarioID: scenarioID})
+	return &status.Scenarios[len(status.Scenarios)-1]
+}
+
+// the running service shares one recorder between its loops
+type statusRecorder struct {
+	mu     sync.Mutex
+	dir    string
+	status agentStatus
+}
+
+// continues from the last saved status so a restart keeps the history
+func newStatusRecorder(dir string) *statusRecorder {
+	status, _ := readStatus(dir)
+	return &statusRecorder{dir: dir, status: status}
+}
+
+func (r *statusRecorder) update(change func(status *agentStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(&r.status)
+	r.status.Updated = time.Now().Unix()
+	err := writeStatus(r.dir, r.status)
+	if err != nil {
+		log.Println("ERROR: unable to save status;", err)
+	}
+}
+
+func (r *statusRecorder) updateScenario(scenarioID uint64, change func(s *scenarioStatus)) {
+	r.update(func(status *agentStatus) {
+		change(status.scenario(scenarioID))
+	})
+}
+
+func (r *statusRecorder) recordError(message string, err error) {
+	r.update(func(status *agentStatus) {
+		status.RecentErrors = append(status.RecentErrors, statusError{
+			Timestamp: time.Now().Unix(),
+			Message:   message + "; " + err.Error(),
+		})
+		if len(status.RecentErrors) > statusMaxErrors {
+			status.RecentErrors = status.RecentErrors[len(status.RecentErrors)-statusMaxErrors:]
+		}
+	})
+}
+
+func readStatus(dir string) (agentStatus, error) {
+	var status agentStatus
+	bs, err := ioutil.ReadFile(filepath.Join(dir, fileNameStatus))
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal(bs, &status)
+	return status, err
+}
+
+// readable by everyone so the team README page can show it, the script copy
+// is for browsers that will not fetch local files
+func writeStatus(dir string, status agentStatus) error {
+	bs, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(filepath.Join(dir, fileNameStatus), bs, 0644)
+	if err != nil {
+		return err
+	}
+	script := append([]byte("var cpScoringStatus = "), bs...)
+	script = append(script, []byte(";\n")...)
+	return writeFileAtomic(filepath.Join(dir, fileNameStatusScript), script, 0644)
+}
+
+// readers never see a partly written file
+// written next to the file, then renamed over it
+const tempFileSuffix = ".tmp"
+
+func writeFileAtomic(file string, bs []byte, perm os.FileMode) error {
+	tempFile := file + tempFileSuffix
+	err := ioutil.WriteFile(tempFile, bs, perm)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tempFile, perm)
+	if err != nil {
+		os.Remove(tempFile)
+		return err
+	}
+	err = os.Rename(tempFile, file)
+	if err != nil {
+		os.Remove(tempFile)
+	}
+	return err
+}
+
+func countFiles(dir string) int {
+	files, err := spoolFiles(dir)
+	if err != nil {
+		return 0
+	}
+	return len(files)
+}
+
+func printStatus(w io.Writer, status agentStatus) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Server:\t%s\n", statusValue(status.ServerURL))
+	for _, scenario := range status.Scenarios {
+		fmt.Fprintf(tw, "Scenario:\t%d\n", scenario.ScenarioID)
+		fmt.Fprintf(tw, "  Role:\t%s\n", statusValue(scenario.Role))
+		fmt.Fprintf(tw, "  Host token:\t%s\n", statusValue(scenario.HostToken))
+		fmt.Fprintf(tw, "  Team key registered:\t%t\n", scenario.TeamKeyRegistered)
+		fmt.Fprintf(tw, "  Last checks fetch:\t%s\n", statusTime(scenario.LastChecksFetch))
+		fmt.Fprintf(tw, "  Checks last modified:\t%s\n", statusValue(scenario.ChecksLastModified))
+	}
+	fmt.Fprintf(tw, "Last submission:\t%s\n", statusTime(status.LastSubmission))
+	fmt.Fprintf(tw, "Results waiting:\t%d\n", status.SpoolDepth)
+	fmt.Fprintf(tw, "Results quarantined:\t%d\n", status.QuarantineDepth)
+	fmt.Fprintf(tw, "Status updated:\t%s\n", statusTime(status.Updated))
+	tw.Flush()
+
+	if len(status.RecentErrors) == 0 {
+		fmt.Fprintln(w, "Recent errors: none")
+		return
+	}
+	fmt.Fprintln(w, "Recent errors:")
+	for _, statusErr := range status.RecentErrors {
+		fmt.Fprintf(w, "  %s  %s\n", statusTime(statusErr.Timestamp), statusErr.Message)
+	}
+}
+
+func statusTime(timestamp int64) string {
+	if timestamp == 0 {
+		return "never"
+	}
+	return time.Unix(timestamp, 0).Format(time.RFC1123)
+}
+
+func statusValue(s string) string {
+	if len(s) == 0 {
+		return "(none)"
+	}
+	return s
+}
+
+// config files are the truth for settings, the status file for what the
+// service last did
+func showStatus(w io.Writer, dirConfig string, dirData string, dirResults string, dirQuarantine string) {
+	status, err := readStatus(dirData)
+	if err != nil {
+		fmt.Fprintln(w, "No status saved, the agent service has not run yet")
+	}
+	status.ServerURL, _ = readServerURL(dirConfig)
+	enrollments, _ := readEnrollments(dirConfig, dirData)
+	scenarios := make([]scenarioStatus, 0, len(enrollments))
+	for _, e := range enrollments {
+		scenario := *status.scenario(e.ScenarioID)
+		fillScenarioStatus(&scenario, e)
+		scenarios = append(scenarios, scenario)
+	}
+	status.Scenarios = scenarios
+	status.SpoolDepth = countFiles(dirResults)
+	status.QuarantineDepth = countFiles(dirQuarantine)
+	printStatus(w, status)
+}
+
+func fillScenarioStatus(scenario *scenarioStatus, e enrollment) {
+	scenario.Role = e.role()
+	scenario.HostToken, _ = readHostToken(e.dirData)
+	scenario.HostTokenIssued = len(scenario.HostToken) > 0
+	_, err := readTeamKey(e.dirData)
+	scenario.TeamKeyRegistered = err == nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-scoring-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	status := newStatusRecorder(dir)
+	status.update(func(s *agentStatus) {
+		s.ServerURL = "http://localhost"
+	})
+	status.updateScenario(3, func(s *scenarioStatus) {
+		s.LastChecksFetch = 100
+	})
+	status.updateScenario(3, func(s *scenarioStatus) {
+		s.HostToken = "token1"
+		s.HostTokenIssued = true
+	})
+	for i := 0; i < statusMaxErrors+2; i++ {
+		status.recordError("unable to send results", errors.New("refused"))
+	}
+
+	saved, err := readStatus(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ServerURL != "http://localhost" || len(saved.Scenarios) != 1 {
+		t.Fatal("Unexpected saved status", saved)
+	}
+	if saved.Scenarios[0].ScenarioID != 3 || saved.Scenarios[0].LastChecksFetch != 100 || !saved.Scenarios[0].HostTokenIssued {
+		t.Fatal("Unexpected saved status", saved)
+	}
+	if len(saved.RecentErrors) != statusMaxErrors {
+		t.Fatal("Expected errors to be capped", len(saved.RecentErrors))
+	}
+	if saved.RecentErrors[0].Message != "unable to send results; refused" {
+		t.Fatal("Unexpected error message", saved.RecentErrors[0].Message)
+	}
+	fileInfo, err := os.Stat(filepath.Join(dir, fileNameStatus))
+	if err != nil || fileInfo.Mode().Perm() != 0644 {
+		t.Fatal("Expected status file readable by everyone", err)
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(dir, fileNameStatusScript))
+	if err != nil || !strings.HasPrefix(string(bs), "var cpScoringStatus = {") {
+		t.Fatal("Unexpected status script", string(bs), err)
+	}
+	// host token stays in the root-only data files
+	for _, fileName := range []string{fileNameStatus, fileNameStatusScript} {
+		bs, _ := ioutil.ReadFile(filepath.Join(dir, fileName))
+		if strings.Contains(string(bs), "token1") {
+			t.Fatal("Host token in readable file", fileName)
+		}
+	}
+
+	// restart keeps history
+	status = newStatusRecorder(dir)
+	if status.status.scenario(3).LastChecksFetch != 100 {
+		t.Fatal("Expected status to be loaded", status.status)
+	}
+}
+
+func TestShowStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-scoring-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dirConfig := filepath.Join(dir, "config")
+	dirData := filepath.Join(dir, "data")
+	dirResults := filepath.Join(dirData, "results")
+	dirQuarantine := filepath.Join(dirData, "quarantine")
+	createDir(dirConfig)
+	createDir(dirResults)
+	createDir(dirQuarantine)
+	saveFile(dirConfig, fileNameServer, "http://localhost")
+	saveFile(dirConfig, fileNameScenario, "7")
+	saveFile(dirData, fileNameHostToken, "token1")
+	e, err := saveEnrollment(dirConfig, dirData, 8, "workstation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveFile(e.dirData, fileNameTeamKey, "key")
+	saveFile(dirResults, "1", "result")
+	saveFile(dirResults, "2", "result")
+
+	var out bytes.Buffer
+	showStatus(&out, dirConfig, dirData, dirResults, dirQuarantine)
+	s := out.String()
+	for _, expected := range []string{"No status saved", "http://localhost", "Scenario:", "7", "token1", "false", "8", "workstation", "true", "Last checks fetch:", "never", "Results waiting:", "2", "Recent errors: none"} {
+		if !strings.Contains(s, expected) {
+			t.Fatal("Expected output to contain "+expected, s)
+		}
+	}
+}
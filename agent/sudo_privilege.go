@@ -0,0 +1,370 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const sudoPrivilegeAll string = "all"
+const sudoPrivilegeAny string = "any"
+const sudoPrivilegeNoPasswd string = "nopasswd"
+const sudoAliasMaxDepth int = 8
+
+var sudoersFile = "/etc/sudoers"
+
+var sudoAliasName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+var sudoTag = regexp.MustCompile(`^[A-Z_]+:$`)
+var sudoOption = regexp.MustCompile(`^[A-Z_]+=\S+$`)
+
+type sudoRule struct {
+	Users     []string
+	RunAs     []string
+	GroupOnly bool
+	Command   string
+	NoPasswd  bool
+}
+
+type sudoersPolicy struct {
+	UserAliases  map[string][]string
+	RunAsAliases map[string][]string
+	CmndAliases  map[string][]string
+	// Defaults, with the user list of Defaults:users
+	Defaults []sudoDefaults
+	Rules    []sudoRule
+}
+
+type sudoDefaults struct {
+	Users    []string
+	Settings []string
+}
+
+// user being checked, with the groups used for %group references
+type sudoUser struct {
+	Name   string
+	UID    string
+	Groups []etcGroup
+}
+
+// args: user, privilege (any, all, nopasswd)
+// any: some command as root, all: any command as root,
+// nopasswd: some command as root without a password
+func checkSudoPrivilege(args []string) string {
+	if len(args) != 2 || len(args[0]) == 0 {
+		return "invalid arguments"
+	}
+	privilege := args[1]
+	if privilege != sudoPrivilegeAny && privilege != sudoPrivilegeAll && privilege != sudoPrivilegeNoPasswd {
+		return "invalid privilege"
+	}
+	user, groups, err := readUserGroups(args[0])
+	if err != nil {
+		return "could not read file"
+	}
+	lines, err := readSudoersLines(sudoersFile, 0)
+	if err != nil {
+		return "could not read file"
+	}
+	policy := parseSudoersPolicy(lines)
+	if policy.hasPrivilege(sudoUser{Name: user.Name, UID: user.UID, Groups: groups}, privilege) {
+		return "true"
+	}
+	return "false"
+}
+
+func parseSudoersPolicy(lines []string) sudoersPolicy {
+	policy := sudoersPolicy{
+		UserAliases:  make(map[string][]string),
+		RunAsAliases: make(map[string][]string),
+		CmndAliases:  make(map[string][]string),
+		Defaults:     make([]sudoDefaults, 0),
+		Rules:        make([]sudoRule, 0),
+	}
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "User_Alias":
+			parseSudoAliases(line[len(fields[0]):], policy.UserAliases)
+		case "Runas_Alias":
+			parseSudoAliases(line[len(fields[0]):], policy.RunAsAliases)
+		case "Cmnd_Alias", "Cmd_Alias":
+			parseSudoAliases(line[len(fields[0]):], policy.CmndAliases)
+		case "Host_Alias":
+		default:
+			if strings.HasPrefix(fields[0], "Defaults") {
+				policy.Defaults = append(policy.Defaults, parseSudoDefaults(line)...)
+				continue
+			}
+			policy.Rules = append(policy.Rules, parseSudoUserSpec(line)...)
+		}
+	}
+	return policy
+}
+
+// NAME = item, item : NAME = item
+func parseSudoAliases(s string, aliases map[string][]string) {
+	for _, def := range strings.Split(s, ":") {
+		i := strings.Index(def, "=")
+		if i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(def[:i])
+		if !sudoAliasName.MatchString(name) {
+			continue
+		}
+		aliases[name] = splitSudoList(def[i+1:])
+	}
+}
+
+// Defaults@host, Defaults>runas and Defaults!cmnd do not apply to users
+func parseSudoDefaults(line string) []sudoDefaults {
+	fields := strings.Fields(line)
+	var users []string
+	if strings.HasPrefix(fields[0], "Defaults:") {
+		users = splitSudoList(strings.TrimPrefix(fields[0], "Defaults:"))
+	} else if fields[0] != "Defaults" {
+		return nil
+	}
+	settings := splitSudoList(strings.Join(fields[1:], " "))
+	return []sudoDefaults{{Users: users, Settings: settings}}
+}
+
+// user_list host_list = (runas) TAG: cmnd, cmnd
+// host lists are not evaluated; a rule is taken to apply to this host
+func parseSudoUserSpec(line string) []sudoRule {
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return nil
+	}
+	left := strings.Fields(normalizeSudoList(line[:i]))
+	if len(left) < 2 {
+		return nil
+	}
+	users := splitSudoList(left[0])
+
+	rules := make([]sudoRule, 0)
+	runAs := []string{"root"}
+	groupOnly := false
+	noPasswd := false
+	for _, cmnd := range splitSudoCommands(line[i+1:]) {
+		cmnd = strings.TrimSpace(cmnd)
+		// runas and tags carry over to following commands
+		if strings.HasPrefix(cmnd, "(") {
+			end := strings.Index(cmnd, ")")
+			if end < 0 {
+				continue
+			}
+			runAs, groupOnly = parseSudoRunAs(cmnd[1:end])
+			cmnd = strings.TrimSpace(cmnd[end+1:])
+		}
+		fields := strings.Fields(cmnd)
+		for len(fields) > 0 && (sudoTag.MatchString(fields[0]) || sudoOption.MatchString(fields[0])) {
+			if fields[0] == "NOPASSWD:" {
+				noPasswd = true
+			} else if fields[0] == "PASSWD:" {
+				noPasswd = false
+			}
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, sudoRule{
+			Users:     users,
+			RunAs:     runAs,
+			GroupOnly: groupOnly,
+			Command:   strings.Join(fields, " "),
+			NoPasswd:  noPasswd,
+		})
+	}
+	return rules
+}
+
+// (users:groups); only groups means running as the invoking user
+func parseSudoRunAs(s string) ([]string, bool) {
+	users := s
+	groups := ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		users, groups = s[:i], s[i+1:]
+	}
+	if len(strings.TrimSpace(users)) == 0 {
+		if len(strings.TrimSpace(groups)) > 0 {
+			return nil, true
+		}
+		return []string{"root"}, false
+	}
+	return splitSudoList(users), false
+}
+
+// commas in runas lists are not command separators
+func splitSudoCommands(s string) []string {
+	cmnds := make([]string, 0)
+	depth := 0
+	start := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				cmnds = append(cmnds, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(cmnds, s[start:])
+}
+
+func splitSudoList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// "a, b" to "a,b" so lists are a single field
+func normalizeSudoList(s string) string {
+	fields := strings.Fields(s)
+	joined := ""
+	for i, field := range fields {
+		if i > 0 && !strings.HasSuffix(joined, ",") && !strings.HasPrefix(field, ",") {
+			joined += " "
+		}
+		joined += field
+	}
+	return joined
+}
+
+// later rules override earlier ones for the same command
+func (policy sudoersPolicy) hasPrivilege(user sudoUser, privilege string) bool {
+	noAuth := policy.noAuthenticate(user)
+	all := false
+	allNoPasswd := false
+	some := false
+	someNoPasswd := false
+	for _, rule := range policy.Rules {
+		if rule.GroupOnly || !policy.matchList(rule.Users, policy.UserAliases, user.matchUser, 0) {
+			continue
+		}
+		if !policy.matchList(rule.RunAs, policy.RunAsAliases, matchSudoRunAsRoot, 0) {
+			continue
+		}
+		negated := strings.HasPrefix(rule.Command, "!")
+		command := strings.TrimSpace(strings.TrimPrefix(rule.Command, "!"))
+		isAll := policy.matchList([]string{command}, policy.CmndAliases, matchSudoAllCommand, 0)
+		if negated {
+			// only !ALL takes away privileges, other negated commands are trivially bypassed
+			if isAll {
+				all = false
+				some = false
+				someNoPasswd = false
+			}
+			continue
+		}
+		noPasswd := rule.NoPasswd || noAuth
+		if isAll {
+			all = true
+			allNoPasswd = noPasswd
+		}
+		some = true
+		someNoPasswd = someNoPasswd || noPasswd
+	}
+
+	switch privilege {
+	case sudoPrivilegeAll:
+		return all
+	case sudoPrivilegeAny:
+		return some || all
+	case sudoPrivilegeNoPasswd:
+		return someNoPasswd || (all && allNoPasswd)
+	}
+	return false
+}
+
+// !authenticate, last setting wins, user specific Defaults after global
+func (policy sudoersPolicy) noAuthenticate(user sudoUser) bool {
+	global := false
+	userSet := false
+	userNoAuth := false
+	for _, defaults := range policy.Defaults {
+		for _, setting := range defaults.Settings {
+			if setting != "!authenticate" && setting != "authenticate" {
+				continue
+			}
+			noAuth := setting == "!authenticate"
+			if defaults.Users == nil {
+				global = noAuth
+			} else if policy.matchList(defaults.Users, policy.UserAliases, user.matchUser, 0) {
+				userSet = true
+				userNoAuth = noAuth
+			}
+		}
+	}
+	if userSet {
+		return userNoAuth
+	}
+	return global
+}
+
+// last matching item decides, ! negates
+func (policy sudoersPolicy) matchList(items []string, aliases map[string][]string, match func(string) bool, depth int) bool {
+	matched := false
+	for _, item := range items {
+		negated := false
+		for strings.HasPrefix(item, "!") {
+			negated = !negated
+			item = strings.TrimSpace(item[1:])
+		}
+		var result bool
+		if expanded, present := aliases[item]; present && item != "ALL" {
+			result = depth < sudoAliasMaxDepth && policy.matchList(expanded, aliases, match, depth+1)
+		} else {
+			result = match(item)
+		}
+		if result {
+			matched = !negated
+		}
+	}
+	return matched
+}
+
+func (user sudoUser) matchUser(item string) bool {
+	if item == "ALL" || item == user.Name {
+		return true
+	}
+	if strings.HasPrefix(item, "#") {
+		return len(user.UID) > 0 && item[1:] == user.UID
+	}
+	if strings.HasPrefix(item, "%#") {
+		for _, group := range user.Groups {
+			if group.GID == item[2:] {
+				return true
+			}
+		}
+		return false
+	}
+	if strings.HasPrefix(item, "%") {
+		for _, group := range user.Groups {
+			if group.Name == item[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchSudoRunAsRoot(item string) bool {
+	return item == "ALL" || item == "root" || item == "#0"
+}
+
+func matchSudoAllCommand(item string) bool {
+	return item == "ALL"
+}
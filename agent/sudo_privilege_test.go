@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEtcGroup(t *testing.T) {
+	groups := parseEtcGroup([]byte(""))
+	if len(groups) != 0 {
+		t.Fatal("Parsed groups out of empty string")
+	}
+	groups = parseEtcGroup([]byte("root:x:0:\nsudo:x:27:alice,bob\nbad line\n"))
+	if len(groups) != 2 {
+		t.Fatal("Unexpected groups", groups)
+	}
+	if groups[0].Name != "root" || groups[0].GID != "0" || len(groups[0].Members) != 0 {
+		t.Fatal("Unexpected group", groups[0])
+	}
+	if groups[1].Name != "sudo" || len(groups[1].Members) != 2 || groups[1].Members[1] != "bob" {
+		t.Fatal("Unexpected group", groups[1])
+	}
+
+	users := parseEtcPasswd([]byte("root:x:0:0:root:/root:/bin/bash\nalice:x:1000:1000::/home/alice:/bin/sh\n"))
+	if len(users) != 2 || users[1].Name != "alice" || users[1].UID != "1000" || users[1].GID != "1000" {
+		t.Fatal("Unexpected users", users)
+	}
+}
+
+func TestParseSudoUserSpec(t *testing.T) {
+	rules := parseSudoUserSpec("%admin, bob  ALL=(ALL:ALL) ALL")
+	if len(rules) != 1 {
+		t.Fatal("Unexpected rules", rules)
+	}
+	if len(rules[0].Users) != 2 || rules[0].Users[1] != "bob" || rules[0].Command != "ALL" || rules[0].NoPasswd {
+		t.Fatal("Unexpected rule", rules[0])
+	}
+	if len(rules[0].RunAs) != 1 || rules[0].RunAs[0] != "ALL" {
+		t.Fatal("Unexpected runas", rules[0])
+	}
+
+	rules = parseSudoUserSpec("carol ALL = (www-data, root) NOPASSWD: /usr/bin/systemctl restart apache2, PASSWD: /bin/ls, (:adm) /bin/cat")
+	if len(rules) != 3 {
+		t.Fatal("Unexpected rules", rules)
+	}
+	if len(rules[0].RunAs) != 2 || !rules[0].NoPasswd || rules[0].Command != "/usr/bin/systemctl restart apache2" {
+		t.Fatal("Unexpected rule", rules[0])
+	}
+	if len(rules[1].RunAs) != 2 || rules[1].NoPasswd || rules[1].Command != "/bin/ls" {
+		t.Fatal("Unexpected rule", rules[1])
+	}
+	if !rules[2].GroupOnly {
+		t.Fatal("Unexpected rule", rules[2])
+	}
+
+	rules = parseSudoUserSpec("dave ALL=/bin/ls")
+	if len(rules) != 1 || rules[0].RunAs[0] != "root" {
+		t.Fatal("Expected default runas root", rules)
+	}
+	if len(parseSudoUserSpec("not a rule")) != 0 {
+		t.Fatal("Parsed rule out of invalid line")
+	}
+}
+
+func TestSudoersPolicy(t *testing.T) {
+	lines := []string{
+		"Defaults env_reset",
+		"Defaults:FRIENDS !authenticate",
+		"User_Alias ADMINS = alice, #1005 : FRIENDS = %friends",
+		"Cmnd_Alias SHELLS = /bin/sh, /bin/bash",
+		"Cmnd_Alias EVERYTHING = ALL",
+		"Runas_Alias OP = root, operator",
+		"root ALL=(ALL:ALL) ALL",
+		"ADMINS ALL=(ALL) ALL",
+		"%sudo ALL=(ALL:ALL) NOPASSWD: EVERYTHING",
+		"%friends ALL=(OP) SHELLS",
+		"ALL, !mallory ALL=(www-data) NOPASSWD: ALL",
+		"erin ALL=(ALL) ALL",
+		"erin ALL=(ALL) !ALL",
+		"frank ALL=(ALL) ALL, !SHELLS",
+	}
+	policy := parseSudoersPolicy(lines)
+	if len(policy.UserAliases) != 2 || len(policy.CmndAliases) != 2 || len(policy.RunAsAliases) != 1 {
+		t.Fatal("Unexpected aliases", policy)
+	}
+
+	friends := etcGroup{Name: "friends", GID: "1100"}
+	sudo := etcGroup{Name: "sudo", GID: "27"}
+	tests := []struct {
+		user     sudoUser
+		all      bool
+		any      bool
+		noPasswd bool
+	}{
+		{sudoUser{Name: "root", UID: "0"}, true, true, false},
+		{sudoUser{Name: "alice", UID: "1000"}, true, true, false},
+		{sudoUser{Name: "uid", UID: "1005"}, true, true, false},
+		{sudoUser{Name: "bob", UID: "1001", Groups: []etcGroup{sudo}}, true, true, true},
+		{sudoUser{Name: "carol", UID: "1002", Groups: []etcGroup{friends}}, false, true, true},
+		{sudoUser{Name: "mallory", UID: "1003"}, false, false, false},
+		{sudoUser{Name: "erin", UID: "1004"}, false, false, false},
+		{sudoUser{Name: "frank", UID: "1006"}, true, true, false},
+	}
+	for _, test := range tests {
+		if policy.hasPrivilege(test.user, sudoPrivilegeAll) != test.all {
+			t.Fatal("Unexpected all privilege for", test.user)
+		}
+		if policy.hasPrivilege(test.user, sudoPrivilegeAny) != test.any {
+			t.Fatal("Unexpected any privilege for", test.user)
+		}
+		if policy.hasPrivilege(test.user, sudoPrivilegeNoPasswd) != test.noPasswd {
+			t.Fatal("Unexpected nopasswd privilege for", test.user)
+		}
+	}
+
+	policy = parseSudoersPolicy([]string{"Defaults !authenticate", "Defaults:bob authenticate", "ALL ALL=(ALL) ALL"})
+	if !policy.hasPrivilege(sudoUser{Name: "alice"}, sudoPrivilegeNoPasswd) {
+		t.Fatal("Expected nopasswd from Defaults")
+	}
+	if policy.hasPrivilege(sudoUser{Name: "bob"}, sudoPrivilegeNoPasswd) {
+		t.Fatal("Expected user Defaults to override global")
+	}
+}
+
+func TestCheckSudoPrivilege(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sudo_privilege")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(sudoers string, group string, passwd string) {
+		sudoersFile = sudoers
+		etcGroupFile = group
+		etcPasswdFile = passwd
+	}(sudoersFile, etcGroupFile, etcPasswdFile)
+	sudoersFile = filepath.Join(dir, "sudoers")
+	etcGroupFile = filepath.Join(dir, "group")
+	etcPasswdFile = filepath.Join(dir, "passwd")
+
+	if checkSudoPrivilege([]string{"alice", "all"}) != "could not read file" {
+		t.Fatal("Expected could not read file")
+	}
+
+	os.MkdirAll(filepath.Join(dir, "sudoers.d"), 0755)
+	ioutil.WriteFile(etcPasswdFile, []byte("root:x:0:0:root:/root:/bin/bash\nalice:x:1000:1000::/home/alice:/bin/bash\nbob:x:1001:27::/home/bob:/bin/bash\ncarol:x:1002:1002::/home/carol:/bin/bash\nerin:x:1005:1005::/home/erin:/bin/bash\nfrank:x:1006:1006::/home/frank:/bin/bash\ngina:x:1007:1007::/home/gina:/bin/bash\n"), 0644)
+	ioutil.WriteFile(etcGroupFile, []byte("root:x:0:\nsudo:x:27:alice\nwheel:x:10:carol\nalice:x:1000:\nstaff:x:1003:gina\n"), 0644)
+	ioutil.WriteFile(sudoersFile, []byte("Defaults\tenv_reset\n# comment\nroot\tALL=(ALL:ALL) ALL\n%sudo\tALL=(ALL:ALL) ALL\n@includedir "+filepath.Join(dir, "sudoers.d")+"\n"), 0440)
+	ioutil.WriteFile(filepath.Join(dir, "sudoers.d", "wheel"), []byte("%wheel ALL=(root) NOPASSWD: /usr/bin/apt, \\\n  /usr/bin/apt-get\n"), 0440)
+	// uid and gid forms read from a file, with a trailing comment
+	ioutil.WriteFile(filepath.Join(dir, "sudoers.d", "ids"), []byte("User_Alias OPS = #1005 # ops account\nOPS ALL=(ALL) ALL\nfrank ALL=(#0) NOPASSWD: ALL\n%#1003 ALL=(ALL) ALL #staff\n"), 0440)
+	ioutil.WriteFile(filepath.Join(dir, "sudoers.d", "wheel.bak"), []byte("carol ALL=(ALL) NOPASSWD: ALL\n"), 0440)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"alice"}, "invalid arguments"},
+		{[]string{"alice", "everything"}, "invalid privilege"},
+		{[]string{"alice", "all"}, "true"},
+		{[]string{"alice", "nopasswd"}, "false"},
+		// primary group
+		{[]string{"bob", "all"}, "true"},
+		{[]string{"carol", "all"}, "false"},
+		{[]string{"carol", "any"}, "true"},
+		{[]string{"carol", "nopasswd"}, "true"},
+		{[]string{"dave", "any"}, "false"},
+		{[]string{"erin", "all"}, "true"},
+		{[]string{"frank", "nopasswd"}, "true"},
+		{[]string{"gina", "all"}, "true"},
+	}
+	for _, test := range tests {
+		result := checkSudoPrivilege(test.args)
+		if result != test.expected {
+			t.Fatal("Unexpected result for", test.args, result)
+		}
+	}
+}
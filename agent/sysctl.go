@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const sysctlModePersistent string = "persistent"
+const sysctlModeRuntime string = "runtime"
+
+var sysctlProcDir = "/proc/sys"
+var sysctlConfFile = "/etc/sysctl.conf"
+
+// same precedence as systemd-sysctl, earlier directories override files of the same name
+var sysctlConfDirs = []string{"/etc/sysctl.d", "/run/sysctl.d", "/usr/local/lib/sysctl.d", "/usr/lib/sysctl.d", "/lib/sysctl.d"}
+
+// args: name, mode (runtime, persistent)
+func checkSysctl(args []string) string {
+	if len(args) < 1 || len(args[0]) == 0 {
+		return "invalid arguments"
+	}
+	name := sysctlNormalizeName(args[0])
+	mode := sysctlModeRuntime
+	if len(args) > 1 && len(args[1]) > 0 {
+		mode = args[1]
+	}
+
+	if mode == sysctlModeRuntime {
+		value, err := sysctlRuntimeValue(name)
+		if err != nil {
+			return "could not read file"
+		}
+		return value
+	} else if mode == sysctlModePersistent {
+		value, found := sysctlPersistentValue(name)
+		if !found {
+			return configValueNotFound
+		}
+		return value
+	}
+	return "invalid mode"
+}
+
+// names use . as separator. As in sysctl, if the first separator is /
+// then / and . are swapped, so net/ipv4/conf/eth0.100/rp_filter is
+// net.ipv4.conf.eth0/100.rp_filter
+func sysctlNormalizeName(name string) string {
+	name = strings.TrimSpace(name)
+	i := strings.IndexAny(name, "./")
+	if i >= 0 && name[i] == '/' {
+		return strings.Trim(sysctlSwapSeparators(name), ".")
+	}
+	return name
+}
+
+func sysctlSwapSeparators(name string) string {
+	return strings.NewReplacer(".", "/", "/", ".").Replace(name)
+}
+
+func sysctlRuntimeValue(name string) (string, error) {
+	fp := filepath.Join(sysctlProcDir, sysctlSwapSeparators(name))
+	bs, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(string(bs)), " "), nil
+}
+
+func sysctlPersistentValue(name string) (string, bool) {
+	files := make(map[string]string)
+	for i := len(sysctlConfDirs) - 1; i >= 0; i-- {
+		matches, _ := filepath.Glob(filepath.Join(sysctlConfDirs[i], "*.conf"))
+		for _, match := range matches {
+			files[filepath.Base(match)] = match
+		}
+	}
+	names := make([]string, 0, len(files))
+	for fileName := range files {
+		names = append(names, fileName)
+	}
+	sort.Strings(names)
+	paths := make([]string, 0, len(names)+1)
+	for _, fileName := range names {
+		paths = append(paths, files[fileName])
+	}
+	// applied last
+	paths = append(paths, sysctlConfFile)
+
+	value := ""
+	found := false
+	for _, fp := range paths {
+		bs, err := ioutil.ReadFile(fp)
+		if err != nil {
+			continue
+		}
+		if v, present := parseSysctlConf(bs)[name]; present {
+			value = v
+			found = true
+		}
+	}
+	return value, found
+}
+
+func parseSysctlConf(bs []byte) map[string]string {
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		// - prefix ignores errors when applying
+		key := strings.TrimPrefix(strings.TrimSpace(line[:i]), "-")
+		value := strings.Join(strings.Fields(line[i+1:]), " ")
+		settings[sysctlNormalizeName(key)] = value
+	}
+	return settings
+}
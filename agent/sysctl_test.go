@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSysctlConf(t *testing.T) {
+	settings := parseSysctlConf([]byte(""))
+	if len(settings) != 0 {
+		t.Fatal("Parsed settings out of empty string")
+	}
+
+	bs := []byte("# comment\n; comment\nnet.ipv4.ip_forward = 1\nkernel/randomize_va_space=2\nnet/ipv4/conf/eth0.100/rp_filter = 1\n-net.ipv4.ip_local_port_range = 32768    60999\nbad\n")
+	settings = parseSysctlConf(bs)
+	if len(settings) != 4 {
+		t.Fatal("Unexpected settings count", settings)
+	}
+	if settings["net.ipv4.ip_forward"] != "1" {
+		t.Fatal("Unexpected ip_forward", settings)
+	}
+	if settings["kernel.randomize_va_space"] != "2" {
+		t.Fatal("Unexpected randomize_va_space", settings)
+	}
+	if settings["net.ipv4.conf.eth0/100.rp_filter"] != "1" {
+		t.Fatal("Unexpected rp_filter", settings)
+	}
+	if settings["net.ipv4.ip_local_port_range"] != "32768 60999" {
+		t.Fatal("Unexpected ip_local_port_range", settings)
+	}
+}
+
+func TestCheckSysctl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(procDir string, confFile string, confDirs []string) {
+		sysctlProcDir = procDir
+		sysctlConfFile = confFile
+		sysctlConfDirs = confDirs
+	}(sysctlProcDir, sysctlConfFile, sysctlConfDirs)
+	sysctlProcDir = filepath.Join(dir, "proc")
+	sysctlConfFile = filepath.Join(dir, "sysctl.conf")
+	dirEtc := filepath.Join(dir, "etc")
+	dirLib := filepath.Join(dir, "lib")
+	sysctlConfDirs = []string{dirEtc, dirLib}
+
+	os.MkdirAll(filepath.Join(sysctlProcDir, "net", "ipv4", "conf", "eth0.100"), 0755)
+	os.MkdirAll(dirEtc, 0755)
+	os.MkdirAll(dirLib, 0755)
+	ioutil.WriteFile(filepath.Join(sysctlProcDir, "net", "ipv4", "ip_forward"), []byte("1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(sysctlProcDir, "net", "ipv4", "conf", "eth0.100", "rp_filter"), []byte("2\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirLib, "10-a.conf"), []byte("net.ipv4.ip_forward = 1\nkernel.randomize_va_space = 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirLib, "50-b.conf"), []byte("kernel.randomize_va_space = 0\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirEtc, "50-b.conf"), []byte("kernel.randomize_va_space = 2\n"), 0644)
+	ioutil.WriteFile(sysctlConfFile, []byte("net.ipv4.ip_forward = 0\n"), 0644)
+
+	if checkSysctl([]string{}) != "invalid arguments" {
+		t.Fatal("Expected invalid arguments")
+	}
+	if checkSysctl([]string{"net.ipv4.ip_forward", "bad"}) != "invalid mode" {
+		t.Fatal("Expected invalid mode")
+	}
+	if result := checkSysctl([]string{"net.ipv4.ip_forward"}); result != "1" {
+		t.Fatal("Unexpected runtime value", result)
+	}
+	if result := checkSysctl([]string{"net/ipv4/ip_forward", "runtime"}); result != "1" {
+		t.Fatal("Unexpected runtime value", result)
+	}
+	if result := checkSysctl([]string{"net/ipv4/conf/eth0.100/rp_filter"}); result != "2" {
+		t.Fatal("Unexpected runtime value", result)
+	}
+	if result := checkSysctl([]string{"net.ipv4.conf.eth0/100.rp_filter"}); result != "2" {
+		t.Fatal("Unexpected runtime value", result)
+	}
+	if result := checkSysctl([]string{"net.ipv4.missing"}); result != "could not read file" {
+		t.Fatal("Unexpected runtime value", result)
+	}
+	// sysctl.conf applied last
+	if result := checkSysctl([]string{"net.ipv4.ip_forward", "persistent"}); result != "0" {
+		t.Fatal("Unexpected persistent value", result)
+	}
+	// file in earlier directory overrides file of same name
+	if result := checkSysctl([]string{"kernel.randomize_va_space", "persistent"}); result != "2" {
+		t.Fatal("Unexpected persistent value", result)
+	}
+	if result := checkSysctl([]string{"kernel.missing", "persistent"}); result != configValueNotFound {
+		t.Fatal("Unexpected persistent value", result)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const systemdStateActive string = "active"
+const systemdStateDisabled string = "disabled"
+const systemdStateEnabled string = "enabled"
+const systemdStateInactive string = "inactive"
+const systemdStateMasked string = "masked"
+const systemdStateMissing string = "missing"
+const systemdStateStatic string = "static"
+const systemdAliasMaxDepth int = 8
+
+// unit files, in order of precedence
+var systemdUnitDirs = []string{"/etc/systemd/system", "/run/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"}
+
+// where enabled units are linked into .wants and .requires directories
+var systemdConfigDirs = []string{"/etc/systemd/system", "/run/systemd/system"}
+
+// cgroup v2, cgroup v1
+var systemdCgroupDirs = []string{"/sys/fs/cgroup/system.slice", "/sys/fs/cgroup/systemd/system.slice"}
+
+// args: unit name
+func checkServiceActive(args []string) string {
+	if len(args) != 1 || len(args[0]) == 0 {
+		return "invalid arguments"
+	}
+	unit := systemdUnitName(args[0])
+
+	fileState := systemdUnitFileState(unit, 0)
+	if fileState == systemdStateMissing {
+		props, err := systemctlShow(unit)
+		if err != nil || props["LoadState"] == "not-found" {
+			return systemdStateMissing
+		}
+		if len(props["ActiveState"]) > 0 {
+			return props["ActiveState"]
+		}
+		return systemdStateMissing
+	}
+	if systemdCgroupPopulated(unit) {
+		return systemdStateActive
+	}
+	// empty cgroup can still be active, e.g. oneshot with RemainAfterExit
+	props, err := systemctlShow(unit)
+	if err == nil && len(props["ActiveState"]) > 0 {
+		return props["ActiveState"]
+	}
+	return systemdStateInactive
+}
+
+// args: unit name
+func checkServiceEnabled(args []string) string {
+	if len(args) != 1 || len(args[0]) == 0 {
+		return "invalid arguments"
+	}
+	unit := systemdUnitName(args[0])
+
+	fileState := systemdUnitFileState(unit, 0)
+	if fileState != systemdStateMissing {
+		return fileState
+	}
+	props, err := systemctlShow(unit)
+	if err != nil || props["LoadState"] == "not-found" {
+		return systemdStateMissing
+	}
+	if props["LoadState"] == systemdStateMasked {
+		return systemdStateMasked
+	}
+	if len(props["UnitFileState"]) > 0 {
+		return props["UnitFileState"]
+	}
+	return systemdStateMissing
+}
+
+// service is assumed when no unit type given
+func systemdUnitName(name string) string {
+	name = strings.TrimSpace(name)
+	if len(filepath.Ext(name)) == 0 {
+		return name + ".service"
+	}
+	return name
+}
+
+func systemdFindUnit(unit string) (string, bool) {
+	names := []string{unit}
+	// template instance, e.g. getty@tty1.service from getty@.service
+	if i := strings.Index(unit, "@"); i >= 0 {
+		names = append(names, unit[:i+1]+filepath.Ext(unit))
+	}
+	for _, name := range names {
+		for _, dir := range systemdUnitDirs {
+			fp := filepath.Join(dir, name)
+			if _, err := os.Lstat(fp); err == nil {
+				return fp, true
+			}
+		}
+	}
+	return "", false
+}
+
+func systemdUnitFileState(unit string, depth int) string {
+	fp, found := systemdFindUnit(unit)
+	if !found {
+		return systemdStateMissing
+	}
+	target, err := os.Readlink(fp)
+	if err == nil {
+		if target == os.DevNull {
+			return systemdStateMasked
+		}
+		// alias of another unit
+		targetUnit := filepath.Base(target)
+		if targetUnit != filepath.Base(fp) && depth < systemdAliasMaxDepth {
+			return systemdUnitFileState(targetUnit, depth+1)
+		}
+	}
+	if systemdUnitWanted(unit) {
+		return systemdStateEnabled
+	}
+	bs, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return systemdStateMissing
+	}
+	install := parseSystemdUnitSection(bs, "Install")
+	for _, key := range []string{"WantedBy", "RequiredBy", "Alias", "Also"} {
+		if len(install[key]) > 0 {
+			return systemdStateDisabled
+		}
+	}
+	return systemdStateStatic
+}
+
+func systemdUnitWanted(unit string) bool {
+	for _, dir := range systemdConfigDirs {
+		for _, suffix := range []string{"*.wants", "*.requires"} {
+			matches, _ := filepath.Glob(filepath.Join(dir, suffix, unit))
+			if len(matches) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func systemdCgroupPopulated(unit string) bool {
+	for _, dir := range systemdCgroupDirs {
+		bs, err := ioutil.ReadFile(filepath.Join(dir, unit, "cgroup.procs"))
+		if err == nil && len(strings.TrimSpace(string(bs))) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func systemctlShow(unit string) (map[string]string, error) {
+	out, err := exec.Command("systemctl", "show", unit, "--property=ActiveState,LoadState,UnitFileState").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseSystemctlShow(out), nil
+}
+
+func parseSystemctlShow(bs []byte) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(bs), "\n") {
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		props[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+	return props
+}
+
+// keys in the given section, repeated keys are accumulated
+func parseSystemdUnitSection(bs []byte, section string) map[string][]string {
+	settings := make(map[string][]string)
+	currentSection := ""
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentSection = line[1 : len(line)-1]
+			continue
+		}
+		if currentSection != section {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		// empty assignment resets the list
+		if len(value) == 0 {
+			delete(settings, key)
+			continue
+		}
+		settings[key] = append(settings[key], strings.Fields(value)...)
+	}
+	return settings
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSystemctlShow(t *testing.T) {
+	props := parseSystemctlShow([]byte(""))
+	if len(props) != 0 {
+		t.Fatal("Parsed properties out of empty string")
+	}
+	props = parseSystemctlShow([]byte("ActiveState=active\nLoadState=loaded\nUnitFileState=enabled\n"))
+	if props["ActiveState"] != "active" || props["LoadState"] != "loaded" || props["UnitFileState"] != "enabled" {
+		t.Fatal("Unexpected properties", props)
+	}
+}
+
+func TestParseSystemdUnitSection(t *testing.T) {
+	bs := []byte("[Unit]\nDescription=test\n\n[Install]\nWantedBy=multi-user.target\nAlias=a.service b.service\n# WantedBy=other.target\n")
+	install := parseSystemdUnitSection(bs, "Install")
+	if len(install["WantedBy"]) != 1 || install["WantedBy"][0] != "multi-user.target" {
+		t.Fatal("Unexpected WantedBy", install)
+	}
+	if len(install["Alias"]) != 2 {
+		t.Fatal("Unexpected Alias", install)
+	}
+	if _, present := install["Description"]; present {
+		t.Fatal("Parsed key from other section")
+	}
+
+	install = parseSystemdUnitSection([]byte("[Install]\nWantedBy=a.target\nWantedBy=\n"), "Install")
+	if len(install["WantedBy"]) != 0 {
+		t.Fatal("Empty assignment did not reset", install)
+	}
+}
+
+func TestSystemdUnitFileState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(unitDirs []string, configDirs []string) {
+		systemdUnitDirs = unitDirs
+		systemdConfigDirs = configDirs
+	}(systemdUnitDirs, systemdConfigDirs)
+	dirEtc := filepath.Join(dir, "etc")
+	dirLib := filepath.Join(dir, "lib")
+	systemdUnitDirs = []string{dirEtc, dirLib}
+	systemdConfigDirs = []string{dirEtc}
+
+	os.MkdirAll(filepath.Join(dirEtc, "multi-user.target.wants"), 0755)
+	os.MkdirAll(dirLib, 0755)
+	installable := []byte("[Service]\nExecStart=/bin/true\n[Install]\nWantedBy=multi-user.target\n")
+	ioutil.WriteFile(filepath.Join(dirLib, "ssh.service"), installable, 0644)
+	ioutil.WriteFile(filepath.Join(dirLib, "apache2.service"), installable, 0644)
+	ioutil.WriteFile(filepath.Join(dirLib, "telnet.service"), installable, 0644)
+	ioutil.WriteFile(filepath.Join(dirLib, "static.service"), []byte("[Service]\nExecStart=/bin/true\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dirLib, "getty@.service"), installable, 0644)
+	os.Symlink(filepath.Join(dirLib, "ssh.service"), filepath.Join(dirEtc, "multi-user.target.wants", "ssh.service"))
+	os.Symlink(filepath.Join(dirLib, "ssh.service"), filepath.Join(dirEtc, "sshd.service"))
+	os.Symlink(filepath.Join(dirLib, "getty@.service"), filepath.Join(dirEtc, "multi-user.target.wants", "getty@tty1.service"))
+	os.Symlink(os.DevNull, filepath.Join(dirEtc, "telnet.service"))
+
+	tests := map[string]string{
+		"ssh.service":        systemdStateEnabled,
+		"sshd.service":       systemdStateEnabled,
+		"apache2.service":    systemdStateDisabled,
+		"static.service":     systemdStateStatic,
+		"telnet.service":     systemdStateMasked,
+		"getty@tty1.service": systemdStateEnabled,
+		"getty@tty2.service": systemdStateDisabled,
+		"missing.service":    systemdStateMissing,
+	}
+	for unit, expected := range tests {
+		state := systemdUnitFileState(unit, 0)
+		if state != expected {
+			t.Fatal("Unexpected state for", unit, state)
+		}
+	}
+
+	if systemdUnitName("ssh") != "ssh.service" {
+		t.Fatal("Unexpected unit name")
+	}
+	if systemdUnitName("ssh.socket") != "ssh.socket" {
+		t.Fatal("Unexpected unit name")
+	}
+}
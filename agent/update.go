@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/netwayfind/cp-scoring/processing"
+	"golang.org/x/crypto/openpgp"
+)
+
+const updateInterval = time.Hour
+const updateMaxSize int64 = 256 * 1024 * 1024
+
+// only an installed agent with a build version updates itself, to the version
+// the server advertises and signed with the binary; returns true if the agent
+// was replaced
+func checkUpdate(serverURL string, entities openpgp.EntityList, host currentHost) (bool, error) {
+	if len(version) == 0 {
+		return false, nil
+	}
+	ex, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+	ex, err = filepath.EvalSymlinks(ex)
+	if err != nil {
+		return false, err
+	}
+	if ex != host.agentPath() {
+		return false, nil
+	}
+
+	resp, err := httpClient.Get(serverURL + "/api/version")
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return false, fmt.Errorf("unexpected server response: %d", resp.StatusCode)
+	}
+	serverVersion, err := readBody(resp)
+	resp.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	if len(serverVersion) == 0 || serverVersion == version {
+		return false, nil
+	}
+	log.Println("Updating agent from version " + version + " to " + serverVersion)
+
+	url := serverURL + "/public/" + filepath.Base(host.agentPath())
+	binary, err := downloadFile(url)
+	if err != nil {
+		return false, err
+	}
+	signature, err := downloadFile(url + ".sig")
+	if err != nil {
+		return false, err
+	}
+	// only the version the server advertised is installed
+	err = processing.VerifyDetached(processing.AgentUpdatePayload(serverVersion, binary), signature, entities)
+	if err != nil {
+		return false, errors.New("could not verify agent signature for version " + serverVersion + "; " + err.Error())
+	}
+
+	err = host.replaceAgent(binary)
+	if err != nil {
+		return false, err
+	}
+	log.Println("Agent updated, restarting")
+	return true, host.restartAgent()
+}
+
+func downloadFile(url string) ([]byte, error) {
+	resp, err := downloadClient().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download %s: %d", url, resp.StatusCode)
+	}
+	bs, err := ioutil.ReadAll(io.LimitReader(resp.Body, updateMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(bs)) > updateMaxSize {
+		return nil, errors.New("download too large: " + url)
+	}
+	return bs, nil
+}
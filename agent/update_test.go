@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netwayfind/cp-scoring/processing"
+	"golang.org/x/crypto/openpgp"
+)
+
+type testHost struct {
+	path     string
+	replaced []byte
+	restarts int
+}
+
+func (h *testHost) agentPath() string {
+	return h.path
+}
+
+func (h *testHost) copyTeamFiles() error {
+	return nil
+}
+
+func (h *testHost) install() error {
+	return nil
+}
+
+func (h *testHost) repair() error {
+	return nil
+}
+
+func (h *testHost) replaceAgent(binary []byte) error {
+	h.replaced = binary
+	return nil
+}
+
+func (h *testHost) restartAgent() error {
+	h.restarts++
+	return nil
+}
+
+func (h *testHost) uninstall() error {
+	return nil
+}
+
+func TestCheckUpdate(t *testing.T) {
+	pubKey, privKey, err := processing.NewPubPrivKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privEntities, _ := openpgp.ReadArmoredKeyRing(bytes.NewReader(privKey))
+	entities, _ := openpgp.ReadArmoredKeyRing(bytes.NewReader(pubKey))
+
+	ex, _ := os.Executable()
+	ex, _ = filepath.EvalSymlinks(ex)
+	name := filepath.Base(ex)
+
+	binary := []byte("new agent")
+	serverVersion := "2.0.0"
+	signature, _ := processing.SignDetached(processing.AgentUpdatePayload(serverVersion, binary), privEntities[0])
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(serverVersion)
+	})
+	mux.HandleFunc("/public/"+name, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+	mux.HandleFunc("/public/"+name+".sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(signature)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	defer func(v string) {
+		version = v
+	}(version)
+	version = "1.0.0"
+
+	// not installed
+	host := &testHost{path: filepath.Join(filepath.Dir(ex), "other")}
+	updated, err := checkUpdate(server.URL, entities, host)
+	if updated || err != nil || host.replaced != nil {
+		t.Fatal("Expected no update for agent outside install path", err)
+	}
+
+	host = &testHost{path: ex}
+	updated, err = checkUpdate(server.URL, entities, host)
+	if !updated || err != nil {
+		t.Fatal("Expected update", err)
+	}
+	if !bytes.Equal(host.replaced, binary) || host.restarts != 1 {
+		t.Fatal("Expected agent replaced and restarted", host)
+	}
+
+	// same version
+	version = serverVersion
+	host = &testHost{path: ex}
+	updated, err = checkUpdate(server.URL, entities, host)
+	if updated || err != nil {
+		t.Fatal("Expected no update for same version", err)
+	}
+
+	// signed for another version
+	version = "1.0.0"
+	serverVersion = "3.0.0"
+	host = &testHost{path: ex}
+	updated, err = checkUpdate(server.URL, entities, host)
+	if updated || err == nil || host.replaced != nil || host.restarts != 0 {
+		t.Fatal("Expected signature failure for other version", err)
+	}
+	serverVersion = "2.0.0"
+
+	// tampered binary
+	binary = []byte("evil agent")
+	host = &testHost{path: ex}
+	updated, err = checkUpdate(server.URL, entities, host)
+	if updated || err == nil || host.replaced != nil || host.restarts != 0 {
+		t.Fatal("Expected signature failure", err)
+	}
+}
@@ -11,6 +11,13 @@ import (
 type hostWindows struct {
 }
 
+// home and desktop folders, where team files are usually copied to
+var teamFileDirsWindows = []string{"C:\\Users\\*", "C:\\Users\\*\\Desktop"}
+
+func (h hostWindows) agentPath() string {
+	return filepath.Join(InstallPathWindows, FileAgentWindows)
+}
+
 func (h hostWindows) copyTeamFiles() error {
 	installPath := InstallPathWindows
 	currentDir, err := os.Getwd()
@@ -28,11 +35,16 @@ func (h hostWindows) copyTeamFiles() error {
 	fileName = "Team Setup.bat"
 	copyFile(filepath.Join(installPath, fileName), filepath.Join(currentDir, fileName))
 
+	err = recordTeamFileCopies(installPath, currentDir)
+	if err != nil {
+		log.Println("ERROR: unable to record where team files were copied;", err)
+	}
+
 	log.Println("Finished copying files")
 	return nil
 }
 
-func (h hostWindows) install() error {
+func (h hostWindows) setup() error {
 	installPath := InstallPathWindows
 
 	// create installation folder
@@ -45,18 +57,11 @@ func (h hostWindows) install() error {
 
 	// copy agent
 	log.Println("Copying this executable to installation folder")
-	ex, err := os.Executable()
+	err = copyAgent(h.agentPath())
 	if err != nil {
 		log.Println("ERROR: unable to copy executable")
 		return err
 	}
-	binFile := filepath.Join(installPath, FileAgentWindows)
-	copyFile(ex, binFile)
-	err = os.Chmod(binFile, 0755)
-	if err != nil {
-		log.Println("ERROR: unable to set file permissions")
-		return err
-	}
 
 	// create Task Scheduler file
 	log.Println("Creating Task Scheduler task")
@@ -84,10 +89,100 @@ func (h hostWindows) install() error {
 		return err
 	}
 
+	return nil
+}
+
+func (h hostWindows) install() error {
+	err := h.setup()
+	if err != nil {
+		return err
+	}
 	log.Println("Finished install")
 	return nil
 }
 
+// config and data are left as they are
+func (h hostWindows) repair() error {
+	if _, err := os.Stat(InstallPathWindows); err != nil {
+		log.Println("ERROR: not installed in " + InstallPathWindows)
+		return err
+	}
+	err := h.setup()
+	if err != nil {
+		return err
+	}
+	log.Println("Finished repair")
+	return nil
+}
+
+// the running executable cannot be deleted, so an uninstall run from the
+// installation folder leaves removing it to cmd once this process has exited
+func (h hostWindows) uninstall() error {
+	installPath := InstallPathWindows
+
+	log.Println("Removing task")
+	exec.Command("C:\\Windows\\system32\\schtasks.exe", "/end", "/tn", "cp-scoring").Run()
+	exec.Command("C:\\Windows\\system32\\schtasks.exe", "/delete", "/F", "/tn", "cp-scoring").Run()
+
+	removeTeamFileCopies(installPath, []string{FileReadmeHTML, "Team Setup.bat"}, teamFileDirsWindows)
+
+	log.Println("Removing installation folder: " + installPath)
+	err := os.RemoveAll(installPath)
+	if err != nil {
+		if _, statErr := os.Stat(h.agentPath()); statErr != nil {
+			log.Println("ERROR: unable to remove installation folder")
+			return err
+		}
+		log.Println("Installation folder will be removed after exit")
+		os.Chdir(filepath.Dir(installPath))
+		cmd := exec.Command("C:\\Windows\\system32\\cmd.exe", "/C", "ping -n 6 127.0.0.1 >NUL & rmdir /s /q "+installPath)
+		err = cmd.Start()
+		if err != nil {
+			log.Println("ERROR: unable to remove installation folder")
+			return err
+		}
+	}
+
+	log.Println("Finished uninstall")
+	return nil
+}
+
+// a running executable cannot be replaced, but it can be renamed
+func (h hostWindows) replaceAgent(binary []byte) error {
+	binFile := h.agentPath()
+	newFile := binFile + ".new"
+	oldFile := binFile + ".old"
+	err := ioutil.WriteFile(newFile, binary, 0755)
+	if err != nil {
+		return err
+	}
+	os.Remove(oldFile)
+	err = os.Rename(binFile, oldFile)
+	if err != nil {
+		os.Remove(newFile)
+		return err
+	}
+	err = os.Rename(newFile, binFile)
+	if err != nil {
+		os.Rename(oldFile, binFile)
+		os.Remove(newFile)
+		return err
+	}
+	return nil
+}
+
+// the task is started again once this process has exited, tasks created
+// before on demand starts were allowed run the agent directly
+func (h hostWindows) restartAgent() error {
+	cmd := exec.Command("C:\\Windows\\system32\\cmd.exe", "/C", "ping -n 11 127.0.0.1 >NUL & C:\\Windows\\system32\\schtasks.exe /run /tn cp-scoring || "+h.agentPath())
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	os.Exit(exitCodeSuccess)
+	return nil
+}
+
 func getScheduledTaskXML() []byte {
 	return []byte(`<?xml version="1.0" encoding="UTF-16"?>
 <Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
@@ -118,7 +213,7 @@ func getScheduledTaskXML() []byte {
       <StopOnIdleEnd>true</StopOnIdleEnd>
       <RestartOnIdle>false</RestartOnIdle>
     </IdleSettings>
-    <AllowStartOnDemand>false</AllowStartOnDemand>
+    <AllowStartOnDemand>true</AllowStartOnDemand>
     <Enabled>true</Enabled>
     <Hidden>false</Hidden>
     <RunOnlyIfIdle>false</RunOnlyIfIdle>
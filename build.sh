@@ -28,6 +28,10 @@ cd ${BASE_DIR}/server
 GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -o ${OUTPUT_DIR}/${PROJ_NAME}-server-linux -ldflags "-X main.version=${VERSION}" ${PKG_BASE}/server
 cp ${BASE_DIR}/server/server.conf.example ${OUTPUT_DIR}/config/
 
+# agent dependencies
+echo "Fetching agent dependencies. This may take a while."
+go get gopkg.in/yaml.v3
+
 # build agents
 echo "Building linux agent"
 GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -o ${OUTPUT_DIR}/public/${PROJ_NAME}-agent-linux -ldflags "-X main.version=${VERSION}" $PKG_BASE/agent
@@ -5,10 +5,27 @@ type ActionType string
 
 // asdf
 const (
-	ActionTypeExec      ActionType = "EXEC"
-	ActionTypeFileExist ActionType = "FILE_EXIST"
-	ActionTypeFileRegex ActionType = "FILE_REGEX"
-	ActionTypeFileValue ActionType = "FILE_VALUE"
+	ActionTypeComposite       ActionType = "COMPOSITE"
+	ActionTypeConfigValue     ActionType = "CONFIG_VALUE"
+	ActionTypeExec            ActionType = "EXEC"
+	ActionTypeFileExist       ActionType = "FILE_EXIST"
+	ActionTypeFileRegex       ActionType = "FILE_REGEX"
+	ActionTypeFileSweep       ActionType = "FILE_SWEEP"
+	ActionTypeFileValue       ActionType = "FILE_VALUE"
+	ActionTypeFileWrite       ActionType = "FILE_WRITE"
+	ActionTypeFirewallEnabled ActionType = "FIREWALL_ENABLED"
+	ActionTypeFirewallPolicy  ActionType = "FIREWALL_POLICY"
+	ActionTypeFirewallRule    ActionType = "FIREWALL_RULE"
+	ActionTypeGroupAddMember  ActionType = "GROUP_ADD_MEMBER"
+	ActionTypePAMSetting      ActionType = "PAM_SETTING"
+	ActionTypePackageInstall  ActionType = "PACKAGE_INSTALL"
+	ActionTypeScheduledJob    ActionType = "SCHEDULED_JOB"
+	ActionTypeServiceActive   ActionType = "SERVICE_ACTIVE"
+	ActionTypeServiceEnable   ActionType = "SERVICE_ENABLE"
+	ActionTypeServiceEnabled  ActionType = "SERVICE_ENABLED"
+	ActionTypeSudoPrivilege   ActionType = "SUDO_PRIVILEGE"
+	ActionTypeSysctl          ActionType = "SYSCTL"
+	ActionTypeUserCreate      ActionType = "USER_CREATE"
 )
 
 // AuditQueueStatus asdf
@@ -20,13 +37,68 @@ const (
 	AuditQueueStatusFailed   AuditQueueStatus = "FAIL"
 )
 
+// ExecResultType asdf
+type ExecResultType string
+
+// asdf
+const (
+	ExecResultExitCode ExecResultType = "EXIT_CODE"
+	ExecResultStdout   ExecResultType = "STDOUT"
+)
+
+// AuditBatchMaxSize asdf
+const AuditBatchMaxSize int = 50
+
+// asdf
+const (
+	CompositeAllOf  string = "all_of"
+	CompositeAnyOf  string = "any_of"
+	CompositeNoneOf string = "none_of"
+)
+
+// RunAsPrivileged asdf
+const RunAsPrivileged string = "PRIVILEGED"
+
+// RunAsUnprivileged asdf
+const RunAsUnprivileged string = "UNPRIVILEGED"
+
+// HostCommandDefaultExpiry asdf
+const HostCommandDefaultExpiry int64 = 24 * 60 * 60
+
+// HostCommandStatus asdf
+type HostCommandStatus string
+
+// asdf
+const (
+	HostCommandStatusCanceled HostCommandStatus = "CANCELED"
+	HostCommandStatusDone     HostCommandStatus = "DONE"
+	HostCommandStatusExpired  HostCommandStatus = "EXPIRED"
+	HostCommandStatusFailed   HostCommandStatus = "FAILED"
+	HostCommandStatusQueued   HostCommandStatus = "QUEUED"
+	HostCommandStatusSent     HostCommandStatus = "SENT"
+)
+
+// HostCommandType asdf
+type HostCommandType string
+
+// asdf
+const (
+	HostCommandTypeApplyConfig  HostCommandType = "APPLY_CONFIG"
+	HostCommandTypeClearTeamKey HostCommandType = "CLEAR_TEAM_KEY"
+	HostCommandTypeReEnroll     HostCommandType = "RE_ENROLL"
+)
+
 // OperatorType asdf
 type OperatorType string
 
 // asdf
 const (
-	OperatorTypeEqual    OperatorType = "EQUAL"
-	OperatorTypeNotEqual OperatorType = "NOT_EQUAL"
+	OperatorTypeEqual              OperatorType = "EQUAL"
+	OperatorTypeGreaterThan        OperatorType = "GREATER_THAN"
+	OperatorTypeGreaterThanOrEqual OperatorType = "GREATER_THAN_OR_EQUAL"
+	OperatorTypeLessThan           OperatorType = "LESS_THAN"
+	OperatorTypeLessThanOrEqual    OperatorType = "LESS_THAN_OR_EQUAL"
+	OperatorTypeNotEqual           OperatorType = "NOT_EQUAL"
 )
 
 // Role asdf
@@ -41,6 +113,12 @@ const (
 // asdf
 const (
 	AuthCookieName       = "auth"
+	HeaderCheckInterval  = "X-Check-Interval"
+	HeaderCheckJitter    = "X-Check-Jitter"
+	HeaderEnrollToken    = "X-Enroll-Token"
+	HeaderHostCommands   = "X-Host-Commands"
+	HeaderHostRole       = "X-Host-Role"
+	HeaderHostToken      = "X-Host-Token"
 	JavascriptDateFormat = "Mon, 02 Jan 2006 15:04:05 MST"
 	KeyCharset           = "0123456789ABCDEF"
 	TeamCookieName       = "team"
@@ -8,6 +8,22 @@ type Action struct {
 	Description string
 	Command     string
 	Args        []string
+	// EXEC only, name of a scenario host script to run
+	Script string
+	// EXEC only, account to run as, empty or RunAsUnprivileged for the
+	// agent's unprivileged account, RunAsPrivileged to keep the agent's
+	// privileges
+	RunAs string
+	// EXEC only, seconds, 0 for the agent default
+	Timeout int
+	// EXEC only, empty for stdout
+	Result ExecResultType
+	// runs alone, after the checks before it and before the checks after it
+	Serial bool
+	// COMPOSITE only, children and their answers in the same order; answers
+	// are not sent to agents, the server scores the children
+	Checks  []Action
+	Answers []Answer
 }
 
 // Answer asdf
@@ -21,6 +37,8 @@ type Answer struct {
 type AnswerResult struct {
 	Description string
 	Points      int
+	Details     []CheckResultDetail
+	Diagnostics string
 }
 
 // AuditAnswerResults asdf
@@ -40,9 +58,20 @@ type AuditCheckResults struct {
 	HostToken          string
 	Timestamp          int64
 	CheckResults       []string
+	CheckResultDetails [][]CheckResultDetail
+	CheckDiagnostics   []string
 	ChecksLastModified string
 }
 
+// CheckResultDetail asdf
+type CheckResultDetail struct {
+	Description string
+	Result      string
+	Passed      bool
+	Children    []CheckResultDetail
+	Diagnostics string
+}
+
 // AuditQueueEntry asdf
 type AuditQueueEntry struct {
 	ID        uint64
@@ -64,10 +93,51 @@ type ClaimsTeam struct {
 	TeamID uint64
 }
 
+// HostCommand asdf
+type HostCommand struct {
+	ID        uint64
+	HostToken string
+	Hostname  string
+	Type      HostCommandType
+	Status    HostCommandStatus
+	Created   int64
+	Expires   int64
+	CreatedBy uint64
+	Result    string
+	// audit trail, only when reading one command
+	Events []HostCommandEvent
+	// scenario config to apply, only sent to the agent
+	Config []Action
+}
+
+// HostCommandEvent asdf
+type HostCommandEvent struct {
+	Timestamp int64
+	Status    HostCommandStatus
+	Source    string
+	Message   string
+}
+
+// HostCommandRequest asdf
+type HostCommandRequest struct {
+	HostToken string
+	Type      HostCommandType
+	// seconds until the command expires, zero for the default
+	ExpiresIn int64
+}
+
+// HostCommandResult asdf
+type HostCommandResult struct {
+	Success bool
+	Result  string
+}
+
 // HostTokenRequest asdf
 type HostTokenRequest struct {
 	ScenarioID uint64
 	Hostname   string
+	// scenario host the agent declared in config, empty to match by hostname
+	Role string
 }
 
 // HostTokenRegistration asdf
@@ -105,6 +175,10 @@ type Scenario struct {
 	Name        string
 	Description string
 	Enabled     bool
+	// seconds between agent check runs, 0 for the agent default
+	CheckInterval int
+	// up to this many seconds added to each agent check run
+	CheckJitter int
 }
 
 // ScenarioHost asdf
@@ -112,6 +186,20 @@ type ScenarioHost struct {
 	Answers []Answer
 	Checks  []Action
 	Config  []Action
+	// hostname globs, or regular expressions after "re:", of hosts with this
+	// role; the role name matches its own hostname too
+	Hostnames []string
+	Scripts   []Script
+}
+
+// Script asdf
+type Script struct {
+	Name string
+	Body string
+	// hex SHA-256 of Body, set by the server
+	Hash string
+	// armored detached signature of Body, set by the server for agents
+	Signature string
 }
 
 // ScenarioScore asdf
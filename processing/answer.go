@@ -0,0 +1,48 @@
+package processing
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+// AnswerPasses asdf
+func AnswerPasses(answer model.Answer, checkResult string) bool {
+	switch answer.Operator {
+	case model.OperatorTypeEqual:
+		return answer.Value == checkResult
+	case model.OperatorTypeNotEqual:
+		return answer.Value != checkResult
+	}
+
+	// numeric operators fail on anything that is not a number
+	result, err := strconv.ParseFloat(strings.TrimSpace(checkResult), 64)
+	if err != nil {
+		return false
+	}
+	var value float64
+	switch v := answer.Value.(type) {
+	case float64:
+		value = v
+	case string:
+		value, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return false
+		}
+	default:
+		return false
+	}
+
+	switch answer.Operator {
+	case model.OperatorTypeGreaterThan:
+		return result > value
+	case model.OperatorTypeGreaterThanOrEqual:
+		return result >= value
+	case model.OperatorTypeLessThan:
+		return result < value
+	case model.OperatorTypeLessThanOrEqual:
+		return result <= value
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package processing
+
+import (
+	"testing"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func TestAnswerPasses(t *testing.T) {
+	tests := []struct {
+		operator model.OperatorType
+		value    interface{}
+		result   string
+		expected bool
+	}{
+		{model.OperatorTypeEqual, "12", "12", true},
+		{model.OperatorTypeEqual, "12", "12.0", false},
+		{model.OperatorTypeNotEqual, "12", "13", true},
+		{model.OperatorTypeNotEqual, "12", "12", false},
+		{model.OperatorTypeGreaterThan, "12", "13", true},
+		{model.OperatorTypeGreaterThan, "12", "12", false},
+		{model.OperatorTypeGreaterThanOrEqual, "12", "12", true},
+		{model.OperatorTypeGreaterThanOrEqual, float64(12), "11", false},
+		{model.OperatorTypeLessThan, "5", " 3\n", true},
+		{model.OperatorTypeLessThan, "5", "5", false},
+		{model.OperatorTypeLessThanOrEqual, "5", "5", true},
+		{model.OperatorTypeLessThanOrEqual, "-1", "0", false},
+		{model.OperatorTypeGreaterThan, "12", "not found", false},
+		{model.OperatorTypeGreaterThan, "twelve", "13", false},
+		{model.OperatorTypeGreaterThan, true, "13", false},
+		{"UNKNOWN", "12", "12", false},
+	}
+	for _, test := range tests {
+		answer := model.Answer{Operator: test.operator, Value: test.value}
+		if AnswerPasses(answer, test.result) != test.expected {
+			t.Fatal("Unexpected result for", test.operator, test.value, test.result)
+		}
+	}
+}
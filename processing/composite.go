@@ -0,0 +1,38 @@
+package processing
+
+import (
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+// CompositeResult asdf
+func CompositeResult(check model.Action, details []model.CheckResultDetail) string {
+	if len(check.Args) != 1 || len(check.Checks) != len(check.Answers) || len(check.Checks) != len(details) {
+		return "invalid arguments"
+	}
+	mode := check.Args[0]
+	if mode != model.CompositeAllOf && mode != model.CompositeAnyOf && mode != model.CompositeNoneOf {
+		return "invalid arguments"
+	}
+
+	// every child is marked, so details show which ones passed
+	passed := mode != model.CompositeAnyOf
+	for i, child := range check.Checks {
+		if child.Type == model.ActionTypeComposite {
+			details[i].Result = CompositeResult(child, details[i].Children)
+		}
+		details[i].Passed = AnswerPasses(check.Answers[i], details[i].Result)
+
+		if mode == model.CompositeAllOf && !details[i].Passed {
+			passed = false
+		} else if mode == model.CompositeAnyOf && details[i].Passed {
+			passed = true
+		} else if mode == model.CompositeNoneOf && details[i].Passed {
+			passed = false
+		}
+	}
+
+	if passed {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,67 @@
+package processing
+
+import (
+	"testing"
+
+	"github.com/netwayfind/cp-scoring/model"
+)
+
+func TestCompositeResult(t *testing.T) {
+	isTrue := model.Answer{Operator: model.OperatorTypeEqual, Value: "true"}
+	isNo := model.Answer{Operator: model.OperatorTypeEqual, Value: "no"}
+	exists := model.Action{Type: model.ActionTypeFileExist}
+	rootLogin := model.Action{Type: model.ActionTypeConfigValue}
+	composite := func(mode string, checks ...model.Action) model.Action {
+		answers := make([]model.Answer, len(checks))
+		for i, check := range checks {
+			answers[i] = isTrue
+			if check.Type == model.ActionTypeConfigValue {
+				answers[i] = isNo
+			}
+		}
+		return model.Action{Type: model.ActionTypeComposite, Args: []string{mode}, Checks: checks, Answers: answers}
+	}
+	results := func(results ...string) []model.CheckResultDetail {
+		details := make([]model.CheckResultDetail, len(results))
+		for i, result := range results {
+			details[i].Result = result
+		}
+		return details
+	}
+
+	tests := []struct {
+		check    model.Action
+		details  []model.CheckResultDetail
+		expected string
+	}{
+		{composite(model.CompositeAllOf, exists, rootLogin), results("true", "no"), "true"},
+		{composite(model.CompositeAllOf, exists, rootLogin), results("true", "yes"), "false"},
+		{composite(model.CompositeAllOf), nil, "true"},
+		{composite(model.CompositeAnyOf, exists, exists), results("false", "true"), "true"},
+		{composite(model.CompositeAnyOf, exists, exists), results("false", "false"), "false"},
+		{composite(model.CompositeAnyOf), nil, "false"},
+		{composite(model.CompositeNoneOf, exists, exists), results("false", "false"), "true"},
+		{composite(model.CompositeNoneOf, exists, exists), results("false", "true"), "false"},
+		{composite("xor", exists), results("true"), "invalid arguments"},
+		{composite(model.CompositeAllOf, exists), nil, "invalid arguments"},
+		{model.Action{Type: model.ActionTypeComposite, Args: []string{model.CompositeAllOf}, Checks: []model.Action{exists}}, results("true"), "invalid arguments"},
+	}
+	for i, test := range tests {
+		result := CompositeResult(test.check, test.details)
+		if result != test.expected {
+			t.Fatal("Unexpected result for test", i, result)
+		}
+	}
+
+	// nested results and passed children are filled in
+	details := results("true", "")
+	details[1].Children = results("false", "true")
+	check := composite(model.CompositeAllOf, exists, composite(model.CompositeAnyOf, exists, exists))
+	result := CompositeResult(check, details)
+	if result != "true" || details[1].Result != "true" || !details[1].Passed {
+		t.Fatal("Unexpected nested result", result, details)
+	}
+	if details[1].Children[0].Passed || !details[1].Children[1].Passed {
+		t.Fatal("Unexpected nested details", details[1].Children)
+	}
+}
@@ -72,3 +72,27 @@ func GetPrivKey(entity *openpgp.Entity) ([]byte, error) {
 
 	return bufPriv.Bytes(), nil
 }
+
+// SignDetached asdf
+func SignDetached(bs []byte, entity *openpgp.Entity) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := openpgp.ArmoredDetachSign(buf, entity, bytes.NewReader(bs), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// AgentUpdatePayload asdf
+func AgentUpdatePayload(version string, binary []byte) []byte {
+	// signed with the binary, so a binary cannot be offered as another version
+	payload := []byte("cp-scoring-agent " + version + "\n")
+	return append(payload, binary...)
+}
+
+// VerifyDetached asdf
+func VerifyDetached(bs []byte, signature []byte, entities openpgp.EntityList) error {
+	_, err := openpgp.CheckArmoredDetachedSignature(entities, bytes.NewReader(bs), bytes.NewReader(signature))
+	return err
+}
@@ -0,0 +1,57 @@
+package processing
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestSignDetached(t *testing.T) {
+	pubKey, privKey, err := NewPubPrivKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privEntities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(privKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubEntities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(pubKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := []byte("agent binary")
+	signature, err := SignDetached(bs, privEntities[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = VerifyDetached(bs, signature, pubEntities)
+	if err != nil {
+		t.Fatal("Expected valid signature;", err)
+	}
+	err = VerifyDetached([]byte("agent binary!"), signature, pubEntities)
+	if err == nil {
+		t.Fatal("Expected invalid signature for changed content")
+	}
+
+	// signed by a different key
+	_, otherKey, _ := NewPubPrivKeys()
+	otherEntities, _ := openpgp.ReadArmoredKeyRing(bytes.NewReader(otherKey))
+	signature, _ = SignDetached(bs, otherEntities[0])
+	err = VerifyDetached(bs, signature, pubEntities)
+	if err == nil {
+		t.Fatal("Expected invalid signature for other key")
+	}
+
+	// the version is signed with the binary
+	signature, _ = SignDetached(AgentUpdatePayload("2.0.0", bs), privEntities[0])
+	err = VerifyDetached(AgentUpdatePayload("2.0.0", bs), signature, pubEntities)
+	if err != nil {
+		t.Fatal("Expected valid signature for version;", err)
+	}
+	err = VerifyDetached(AgentUpdatePayload("3.0.0", bs), signature, pubEntities)
+	if err == nil {
+		t.Fatal("Expected invalid signature for other version")
+	}
+}
@@ -0,0 +1,71 @@
+package processing
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// HostnamePatternRegexPrefix asdf
+const HostnamePatternRegexPrefix = "re:"
+
+// hostnames are case insensitive on most hosts, Windows reports them upper case
+func compileHostnamePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)^(?:" + strings.TrimPrefix(pattern, HostnamePatternRegexPrefix) + ")$")
+}
+
+// ValidHostnamePattern asdf
+func ValidHostnamePattern(pattern string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	if strings.HasPrefix(pattern, HostnamePatternRegexPrefix) {
+		_, err := compileHostnamePattern(pattern)
+		return err == nil
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// HostnameMatches asdf
+func HostnameMatches(pattern string, hostname string) bool {
+	if strings.HasPrefix(pattern, HostnamePatternRegexPrefix) {
+		r, err := compileHostnamePattern(pattern)
+		return err == nil && r.MatchString(hostname)
+	}
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(hostname))
+	return err == nil && matched
+}
+
+// ResolveHostRole asdf
+func ResolveHostRole(hostPatterns map[string][]string, role string, hostname string) (string, bool) {
+	// a declared role wins, the host may have been renamed since
+	if len(role) > 0 {
+		_, present := hostPatterns[role]
+		return role, present
+	}
+	// hosts defined before roles are keyed by hostname, hostnames are
+	// case-insensitive
+	if _, present := hostPatterns[hostname]; present {
+		return hostname, true
+	}
+	roles := make([]string, 0, len(hostPatterns))
+	for r := range hostPatterns {
+		roles = append(roles, r)
+	}
+	sort.Strings(roles)
+	for _, r := range roles {
+		if strings.EqualFold(r, hostname) {
+			return r, true
+		}
+	}
+	for _, r := range roles {
+		for _, pattern := range hostPatterns[r] {
+			if HostnameMatches(pattern, hostname) {
+				return r, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package processing
+
+import "testing"
+
+func TestValidHostnamePattern(t *testing.T) {
+	tests := map[string]bool{
+		"ws-01":         true,
+		"ws-*":          true,
+		"ws-[0-9][0-9]": true,
+		"re:ws-\\d+":    true,
+		"":              false,
+		"ws-[":          false,
+		"re:ws-(":       false,
+	}
+	for pattern, expected := range tests {
+		if ValidHostnamePattern(pattern) != expected {
+			t.Fatal("Unexpected result for", pattern)
+		}
+	}
+}
+
+func TestHostnameMatches(t *testing.T) {
+	if !HostnameMatches("ws-*", "WS-17") {
+		t.Fatal("Expected glob to match ignoring case")
+	}
+	if HostnameMatches("ws-?", "ws-17") {
+		t.Fatal("Expected glob not to match")
+	}
+	if !HostnameMatches("re:ws-(0[1-9]|[1-3][0-9]|40)", "ws-40") {
+		t.Fatal("Expected regex to match")
+	}
+	if HostnameMatches("re:ws-(0[1-9]|[1-3][0-9]|40)", "ws-41") {
+		t.Fatal("Expected regex to match whole hostname")
+	}
+}
+
+func TestResolveHostRole(t *testing.T) {
+	hosts := map[string][]string{
+		"server":      {"srv-*"},
+		"workstation": {"ws-*", "re:lab\\d+"},
+		"ws-special":  nil,
+	}
+
+	tests := []struct {
+		role     string
+		hostname string
+		expected string
+		found    bool
+	}{
+		{"server", "renamed", "server", true},
+		{"missing", "srv-01", "missing", false},
+		{"", "ws-special", "ws-special", true},
+		{"", "WS-Special", "ws-special", true},
+		{"", "ws-03", "workstation", true},
+		{"", "LAB12", "workstation", true},
+		{"", "srv-1", "server", true},
+		{"", "other", "", false},
+	}
+	for _, test := range tests {
+		role, found := ResolveHostRole(hosts, test.role, test.hostname)
+		if found != test.found || (found && role != test.expected) {
+			t.Fatal("Unexpected role for", test.role, test.hostname, role, found)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package processing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// names become file names on the host, so no path separators
+var scriptNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ScriptHash asdf
+func ScriptHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidScriptName asdf
+func ValidScriptName(name string) bool {
+	return len(name) <= 128 && scriptNameRegex.MatchString(name)
+}
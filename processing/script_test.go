@@ -0,0 +1,26 @@
+package processing
+
+import "testing"
+
+func TestValidScriptName(t *testing.T) {
+	tests := map[string]bool{
+		"check.sh":       true,
+		"check_users.py": true,
+		"Firewall-1.ps1": true,
+		"":               false,
+		".hidden":        false,
+		"../check.sh":    false,
+		"dir/check.sh":   false,
+		"dir\\check.ps1": false,
+		"check users.sh": false,
+	}
+	for name, expected := range tests {
+		if ValidScriptName(name) != expected {
+			t.Fatal("Unexpected result for", name)
+		}
+	}
+
+	if ScriptHash([]byte("")) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal("Unexpected hash")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,6 +24,7 @@ import (
 type APIHandler struct {
 	BackingStore backingStore
 	jwtSecret    []byte
+	enrollToken  []byte
 	dirResults   string
 	entities     openpgp.EntityList
 }
@@ -52,6 +54,28 @@ func (handler APIHandler) middlewareAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		// who did what, e.g. queued host commands
+		userID, _ := claims["UserID"].(float64)
+		ctx := context.WithValue(r.Context(), model.AuthCookieName, uint64(userID))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// agents set up by automation use the enroll token instead of an admin login
+func (handler APIHandler) middlewareEnroll(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// no enroll token configured, so none accepted
+		if len(handler.enrollToken) == 0 {
+			httpErrorNotAuthenticated(w)
+			return
+		}
+		token := []byte(r.Header.Get(model.HeaderEnrollToken))
+		if subtle.ConstantTimeCompare(token, handler.enrollToken) != 1 {
+			httpErrorNotAuthenticated(w)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -227,10 +251,19 @@ func (handler APIHandler) audit(w http.ResponseWriter, r *http.Request) {
 		httpErrorInternal(w, errors.New("ERROR: unable to read request body"))
 		return
 	}
+	status := handler.auditEnqueue(bs, source, timestamp)
+	if status == http.StatusBadRequest {
+		httpErrorBadRequest(w)
+	} else if status != http.StatusOK {
+		httpErrorInternal(w, errors.New("ERROR: Unable to save to audit queue"))
+	}
+}
+
+// results that cannot be read are rejected, agents should not send them again
+func (handler APIHandler) auditEnqueue(bs []byte, source string, timestamp int64) int {
 	result, err := processing.FromBytes(bs, handler.entities)
 	if err != nil {
-		httpErrorBadRequest(w)
-		return
+		return http.StatusBadRequest
 	}
 	entry := model.AuditQueueEntry{
 		Timestamp: timestamp,
@@ -239,8 +272,33 @@ func (handler APIHandler) audit(w http.ResponseWriter, r *http.Request) {
 	}
 	err = handler.BackingStore.auditQueueInsert(entry)
 	if err != nil {
-		httpErrorInternal(w, errors.New("ERROR: Unable to save to audit queue"))
+		log.Println("ERROR: unable to save to audit queue;", err)
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// responds with a status for each result, in the same order
+func (handler APIHandler) auditBatch(w http.ResponseWriter, r *http.Request) {
+	log.Println("audit batch")
+
+	source := getSourceIP(r)
+	timestamp := time.Now().Unix()
+	var batch [][]byte
+	err := readRequestBody(w, r, &batch)
+	if err != nil {
+		return
+	}
+	if len(batch) == 0 || len(batch) > model.AuditBatchMaxSize {
+		httpErrorBadRequest(w)
+		return
+	}
+
+	statuses := make([]int, len(batch))
+	for i, bs := range batch {
+		statuses[i] = handler.auditEnqueue(bs, source, timestamp)
 	}
+	sendResponse(w, statuses)
 }
 
 func (handler APIHandler) auditEntries(entries []model.AuditQueueEntry) error {
@@ -293,8 +351,13 @@ func (handler APIHandler) auditEntry(entry model.AuditQueueEntry) error {
 	if len(hostname) == 0 {
 		return errors.New("ERROR: hostname not found;")
 	}
+	role, err := handler.BackingStore.hostTokenSelectRole(auditCheckResults.HostToken)
+	if err != nil {
+		log.Println("ERROR: unable to read role from host token;", err)
+		return err
+	}
 
-	lastModified, err := handler.BackingStore.scenarioHostsSelectLastModified(scenario.ID, hostname)
+	lastModified, err := handler.BackingStore.scenarioHostsSelectLastModified(scenario.ID, role)
 	lastModifiedStr := time.Unix(lastModified, 0).Format(model.JavascriptDateFormat)
 	if auditCheckResults.ChecksLastModified != lastModifiedStr {
 		return fmt.Errorf("ERROR: expected last modified %s, received %s", lastModifiedStr, auditCheckResults.ChecksLastModified)
@@ -313,12 +376,12 @@ func (handler APIHandler) auditEntry(entry model.AuditQueueEntry) error {
 		return err
 	}
 
-	answers, err := handler.BackingStore.scenarioHostsSelectAnswers(auditCheckResults.ScenarioID, hostname)
+	answers, err := handler.BackingStore.scenarioHostsSelectAnswers(auditCheckResults.ScenarioID, role)
 	if err != nil {
 		return err
 	}
 
-	checks, err := handler.BackingStore.scenarioHostsSelectChecks(auditCheckResults.ScenarioID, hostname)
+	checks, err := handler.BackingStore.scenarioHostsSelectChecks(auditCheckResults.ScenarioID, role)
 	if err != nil {
 		return err
 	}
@@ -331,21 +394,26 @@ func (handler APIHandler) auditEntry(entry model.AuditQueueEntry) error {
 	score := 0
 	for i, answer := range answers {
 		checkResult := auditCheckResults.CheckResults[i]
+		var details []model.CheckResultDetail
+		if i < len(auditCheckResults.CheckResultDetails) {
+			details = auditCheckResults.CheckResultDetails[i]
+		}
+		// agents are not given composite answers, children are scored here
+		if checks[i].Type == model.ActionTypeComposite {
+			checkResult = processing.CompositeResult(checks[i], details)
+		}
 		points := 0
-		if answer.Operator == model.OperatorTypeEqual {
-			if answer.Value == checkResult {
-				points = answer.Points
-				score += points
-			}
-		} else if answer.Operator == model.OperatorTypeNotEqual {
-			if answer.Value != checkResult {
-				points = answer.Points
-				score += points
-			}
+		if processing.AnswerPasses(answer, checkResult) {
+			points = answer.Points
+			score += points
 		}
 		answerResults[i] = model.AnswerResult{
 			Description: checks[i].Description,
 			Points:      points,
+			Details:     details,
+		}
+		if i < len(auditCheckResults.CheckDiagnostics) {
+			answerResults[i].Diagnostics = auditCheckResults.CheckDiagnostics[i]
 		}
 	}
 
@@ -372,6 +440,238 @@ func (handler APIHandler) auditEntry(entry model.AuditQueueEntry) error {
 	return nil
 }
 
+func validHostCommandType(commandType model.HostCommandType) bool {
+	return commandType == model.HostCommandTypeApplyConfig ||
+		commandType == model.HostCommandTypeClearTeamKey ||
+		commandType == model.HostCommandTypeReEnroll
+}
+
+func (handler APIHandler) createHostCommand(w http.ResponseWriter, r *http.Request) {
+	log.Println("create host command")
+
+	var hostCommandRequest model.HostCommandRequest
+	err := readRequestBody(w, r, &hostCommandRequest)
+	if err != nil {
+		return
+	}
+	if !validHostCommandType(hostCommandRequest.Type) || hostCommandRequest.ExpiresIn < 0 {
+		httpErrorBadRequest(w)
+		return
+	}
+
+	hostname, err := handler.BackingStore.hostTokenSelectHostname(hostCommandRequest.HostToken)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+	if len(hostname) == 0 {
+		httpErrorNotFound(w)
+		return
+	}
+
+	expiresIn := hostCommandRequest.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = model.HostCommandDefaultExpiry
+	}
+	userID, _ := r.Context().Value(model.AuthCookieName).(uint64)
+	timestamp := time.Now().Unix()
+	command := model.HostCommand{
+		HostToken: hostCommandRequest.HostToken,
+		Hostname:  hostname,
+		Type:      hostCommandRequest.Type,
+		Created:   timestamp,
+		Expires:   timestamp + expiresIn,
+		CreatedBy: userID,
+	}
+	command, err = handler.BackingStore.hostCommandInsert(command, getSourceIP(r))
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+
+	sendResponse(w, command)
+}
+
+// only queued commands can be canceled, the command is kept for the audit
+// trail
+func (handler APIHandler) deleteHostCommand(w http.ResponseWriter, r *http.Request) {
+	log.Println("delete host command")
+
+	id, err := getRequestID(r)
+	if err != nil {
+		httpErrorInvalidID(w)
+		return
+	}
+
+	err = handler.BackingStore.hostCommandUpdateStatus(id, model.HostCommandStatusQueued, model.HostCommandStatusCanceled, "", time.Now().Unix(), getSourceIP(r))
+	if err != nil {
+		if err.Error() == model.ErrorDBUpdateNoChange {
+			httpErrorNotFound(w)
+			return
+		}
+		httpErrorDatabase(w, err)
+		return
+	}
+}
+
+func (handler APIHandler) readHostCommand(w http.ResponseWriter, r *http.Request) {
+	log.Println("read host command")
+
+	id, err := getRequestID(r)
+	if err != nil {
+		httpErrorInvalidID(w)
+		return
+	}
+
+	command, err := handler.BackingStore.hostCommandSelect(id)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+	if command.ID == 0 {
+		httpErrorNotFound(w)
+		return
+	}
+
+	sendResponse(w, command)
+}
+
+func (handler APIHandler) readHostCommands(w http.ResponseWriter, r *http.Request) {
+	log.Println("read host commands")
+
+	commands, err := handler.BackingStore.hostCommandSelectAll(r.URL.Query().Get("host_token"))
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+
+	sendResponse(w, commands)
+}
+
+// agents pick up queued commands after the checks response says there are
+// some; sent commands are sent again until the agent reports a result, so a
+// lost response does not lose the command
+func (handler APIHandler) readAgentCommands(w http.ResponseWriter, r *http.Request) {
+	log.Println("read agent commands")
+
+	id, err := getRequestID(r)
+	if err != nil {
+		httpErrorInvalidID(w)
+		return
+	}
+
+	hostToken := r.Header.Get(model.HeaderHostToken)
+	if len(hostToken) == 0 {
+		httpErrorNotAuthenticated(w)
+		return
+	}
+
+	// the host token decides scenario and role, not the query
+	scenarioID, err := handler.BackingStore.hostTokenSelectScenarioID(hostToken)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+	if scenarioID != id {
+		httpErrorNotFound(w)
+		return
+	}
+	role, err := handler.BackingStore.hostTokenSelectRole(hostToken)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+
+	pending, err := handler.BackingStore.hostCommandSelectPending(hostToken)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+
+	timestamp := time.Now().Unix()
+	source := getSourceIP(r)
+	commands := make([]model.HostCommand, 0)
+	for _, command := range pending {
+		status := model.HostCommandStatusSent
+		if command.Expires <= timestamp {
+			status = model.HostCommandStatusExpired
+		} else if command.Type == model.HostCommandTypeApplyConfig {
+			command.Config, err = handler.BackingStore.scenarioHostsSelectConfig(id, role)
+			if err != nil {
+				httpErrorDatabase(w, err)
+				return
+			}
+		}
+		if command.Status != status {
+			err = handler.BackingStore.hostCommandUpdateStatus(command.ID, command.Status, status, "", timestamp, source)
+			if err != nil {
+				// canceled or reported meanwhile
+				if err.Error() == model.ErrorDBUpdateNoChange {
+					continue
+				}
+				httpErrorDatabase(w, err)
+				return
+			}
+		}
+		if status == model.HostCommandStatusSent {
+			command.Status = status
+			commands = append(commands, command)
+		}
+	}
+
+	sendResponse(w, commands)
+}
+
+func (handler APIHandler) updateAgentCommandResult(w http.ResponseWriter, r *http.Request) {
+	log.Println("update agent command result")
+
+	id, err := getRequestID(r)
+	if err != nil {
+		httpErrorInvalidID(w)
+		return
+	}
+
+	var hostCommandResult model.HostCommandResult
+	err = readRequestBody(w, r, &hostCommandResult)
+	if err != nil {
+		return
+	}
+
+	command, err := handler.BackingStore.hostCommandSelect(id)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+	hostToken := []byte(r.Header.Get(model.HeaderHostToken))
+	if command.ID == 0 || subtle.ConstantTimeCompare(hostToken, []byte(command.HostToken)) != 1 {
+		httpErrorNotFound(w)
+		return
+	}
+
+	status := model.HostCommandStatusFailed
+	if hostCommandResult.Success {
+		status = model.HostCommandStatusDone
+	}
+	err = handler.BackingStore.hostCommandUpdateStatus(id, model.HostCommandStatusSent, status, hostCommandResult.Result, time.Now().Unix(), getSourceIP(r))
+	if err != nil {
+		if err.Error() == model.ErrorDBUpdateNoChange {
+			httpErrorBadRequest(w)
+			return
+		}
+		httpErrorDatabase(w, err)
+		return
+	}
+
+	// the host scores for no team until team setup runs again
+	if status == model.HostCommandStatusDone && command.Type == model.HostCommandTypeClearTeamKey {
+		err = handler.BackingStore.teamHostTokenDelete(command.HostToken)
+		if err != nil {
+			httpErrorDatabase(w, err)
+			return
+		}
+	}
+}
+
 func (handler APIHandler) requestHostToken(w http.ResponseWriter, r *http.Request) {
 	log.Println("request host token")
 
@@ -387,23 +687,26 @@ func (handler APIHandler) requestHostToken(w http.ResponseWriter, r *http.Reques
 	timestamp := time.Now().Unix()
 	sourceIP := getSourceIP(r)
 
-	// make sure scenario + hostname exists
-	s, err := handler.BackingStore.scenarioHostsSelectChecks(scenarioID, hostname)
+	// make sure scenario + host exists
+	hostPatterns, err := handler.BackingStore.scenarioHostsSelectHostnames(scenarioID)
 	if err != nil {
 		httpErrorDatabase(w, err)
 		return
 	}
-	if s == nil {
+	role, found := processing.ResolveHostRole(hostPatterns, hostTokenRequest.Role, hostname)
+	if !found {
 		httpErrorNotFound(w)
 		return
 	}
 
-	err = handler.BackingStore.hostTokenInsert(hostToken, hostname, timestamp, sourceIP)
+	err = handler.BackingStore.hostTokenInsert(hostToken, scenarioID, hostname, role, timestamp, sourceIP)
 	if err != nil {
 		httpErrorDatabase(w, err)
 		return
 	}
 
+	// agents ask by role from then on, so renaming the host keeps scoring
+	w.Header().Set(model.HeaderHostRole, role)
 	sendResponse(w, hostToken)
 }
 
@@ -646,6 +949,10 @@ func (handler APIHandler) createScenario(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return
 	}
+	if scenario.CheckInterval < 0 || scenario.CheckJitter < 0 {
+		httpErrorBadRequest(w)
+		return
+	}
 
 	s, err := handler.BackingStore.scenarioInsert(scenario)
 	if err != nil {
@@ -729,6 +1036,10 @@ func (handler APIHandler) updateScenario(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return
 	}
+	if scenario.CheckInterval < 0 || scenario.CheckJitter < 0 {
+		httpErrorBadRequest(w)
+		return
+	}
 
 	s, err := handler.BackingStore.scenarioUpdate(id, scenario)
 	if err != nil {
@@ -743,6 +1054,28 @@ func (handler APIHandler) updateScenario(w http.ResponseWriter, r *http.Request)
 	sendResponse(w, s)
 }
 
+// agents send their hostname and the role they declared in config, if any
+func (handler APIHandler) resolveScenarioHost(w http.ResponseWriter, r *http.Request, scenarioID uint64) (string, bool) {
+	hostnameParam, present := r.URL.Query()["hostname"]
+	if !present || len(hostnameParam) != 1 {
+		httpErrorBadRequest(w)
+		return "", false
+	}
+
+	hostPatterns, err := handler.BackingStore.scenarioHostsSelectHostnames(scenarioID)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return "", false
+	}
+	role, found := processing.ResolveHostRole(hostPatterns, r.URL.Query().Get("role"), hostnameParam[0])
+	if !found {
+		httpErrorNotFound(w)
+		return "", false
+	}
+	w.Header().Set(model.HeaderHostRole, role)
+	return role, true
+}
+
 func (handler APIHandler) readScenarioChecks(w http.ResponseWriter, r *http.Request) {
 	log.Println("read scenario checks")
 
@@ -752,12 +1085,10 @@ func (handler APIHandler) readScenarioChecks(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	hostnameParam, present := r.URL.Query()["hostname"]
-	if !present || len(hostnameParam) != 1 {
-		httpErrorBadRequest(w)
+	role, found := handler.resolveScenarioHost(w, r, id)
+	if !found {
 		return
 	}
-	hostname := hostnameParam[0]
 
 	modifiedSince := r.Header.Get("If-Modified-Since")
 	if len(modifiedSince) == 0 {
@@ -769,7 +1100,43 @@ func (handler APIHandler) readScenarioChecks(w http.ResponseWriter, r *http.Requ
 		httpErrorBadRequest(w)
 		return
 	}
-	lastModified, err := handler.BackingStore.scenarioHostsSelectLastModified(id, hostname)
+	// schedule goes out with not modified responses too, so agents pick up
+	// changes without new checks
+	scenario, err := handler.BackingStore.scenarioSelect(id)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+	w.Header().Set(model.HeaderCheckInterval, strconv.Itoa(scenario.CheckInterval))
+	w.Header().Set(model.HeaderCheckJitter, strconv.Itoa(scenario.CheckJitter))
+
+	// agents send their host token to hear about queued commands, only
+	// counted for the scenario the token belongs to, as only that scenario
+	// hands them out
+	hostToken := r.Header.Get(model.HeaderHostToken)
+	if len(hostToken) > 0 {
+		err = handler.BackingStore.hostTokenUpdateScenarioID(hostToken, id, role)
+		if err != nil && err.Error() != model.ErrorDBUpdateNoChange {
+			httpErrorDatabase(w, err)
+			return
+		}
+		tokenScenarioID, err := handler.BackingStore.hostTokenSelectScenarioID(hostToken)
+		if err != nil {
+			httpErrorDatabase(w, err)
+			return
+		}
+		pending := 0
+		if tokenScenarioID == id {
+			pending, err = handler.BackingStore.hostCommandCountPending(hostToken, time.Now().Unix())
+			if err != nil {
+				httpErrorDatabase(w, err)
+				return
+			}
+		}
+		w.Header().Set(model.HeaderHostCommands, strconv.Itoa(pending))
+	}
+
+	lastModified, err := handler.BackingStore.scenarioHostsSelectLastModified(id, role)
 	if err != nil {
 		httpErrorDatabase(w, err)
 		return
@@ -779,7 +1146,7 @@ func (handler APIHandler) readScenarioChecks(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	s, err := handler.BackingStore.scenarioHostsSelectChecks(id, hostname)
+	s, err := handler.BackingStore.scenarioHostsSelectChecks(id, role)
 	if err != nil {
 		httpErrorDatabase(w, err)
 		return
@@ -790,6 +1157,56 @@ func (handler APIHandler) readScenarioChecks(w http.ResponseWriter, r *http.Requ
 	}
 
 	w.Header().Set("Last-Modified", time.Unix(lastModified, 0).Format(model.JavascriptDateFormat))
+	sendResponse(w, agentChecks(s))
+}
+
+// answers never leave the server, including those of composite children
+func agentChecks(checks []model.Action) []model.Action {
+	if checks == nil {
+		return nil
+	}
+	stripped := make([]model.Action, len(checks))
+	for i, check := range checks {
+		check.Answers = nil
+		check.Checks = agentChecks(check.Checks)
+		stripped[i] = check
+	}
+	return stripped
+}
+
+func (handler APIHandler) readScenarioScripts(w http.ResponseWriter, r *http.Request) {
+	log.Println("read scenario scripts")
+
+	id, err := getRequestID(r)
+	if err != nil {
+		httpErrorInvalidID(w)
+		return
+	}
+
+	role, found := handler.resolveScenarioHost(w, r, id)
+	if !found {
+		return
+	}
+
+	s, err := handler.BackingStore.scenarioHostsSelectScripts(id, role)
+	if err != nil {
+		httpErrorDatabase(w, err)
+		return
+	}
+	if s == nil {
+		httpErrorNotFound(w)
+		return
+	}
+	// signed like the agent binaries, agents only run what the server signed
+	for i := range s {
+		signature, err := processing.SignDetached([]byte(s[i].Body), handler.entities[0])
+		if err != nil {
+			httpErrorInternal(w, err)
+			return
+		}
+		s[i].Signature = string(signature)
+	}
+
 	sendResponse(w, s)
 }
 
@@ -802,14 +1219,12 @@ func (handler APIHandler) readScenarioConfig(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	hostnameParam, present := r.URL.Query()["hostname"]
-	if !present || len(hostnameParam) != 1 {
-		httpErrorBadRequest(w)
+	role, found := handler.resolveScenarioHost(w, r, id)
+	if !found {
 		return
 	}
-	hostname := hostnameParam[0]
 
-	s, err := handler.BackingStore.scenarioHostsSelectConfig(id, hostname)
+	s, err := handler.BackingStore.scenarioHostsSelectConfig(id, role)
 	if err != nil {
 		httpErrorDatabase(w, err)
 		return
@@ -855,6 +1270,25 @@ func (handler APIHandler) updateScenarioHosts(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// agents verify scripts against the hash before running them
+	for _, scenarioHost := range hostMap {
+		for _, pattern := range scenarioHost.Hostnames {
+			if !processing.ValidHostnamePattern(pattern) {
+				httpErrorBadRequest(w)
+				return
+			}
+		}
+		names := make(map[string]bool)
+		for i, script := range scenarioHost.Scripts {
+			if !processing.ValidScriptName(script.Name) || names[script.Name] {
+				httpErrorBadRequest(w)
+				return
+			}
+			names[script.Name] = true
+			scenarioHost.Scripts[i].Hash = processing.ScriptHash([]byte(script.Body))
+		}
+	}
+
 	err = handler.BackingStore.scenarioHostsUpdate(id, hostMap)
 	if err != nil {
 		if err.Error() == model.ErrorDBUpdateNoChange {
@@ -914,6 +1348,9 @@ func (handler APIHandler) readScenarioReportCommon(w http.ResponseWriter, r *htt
 		filtered := make([]model.AnswerResult, 0)
 		for _, answerResult := range s.AnswerResults {
 			if answerResult.Points != 0 {
+				// child results would hint on answers too, diagnostics are for admins
+				answerResult.Details = nil
+				answerResult.Diagnostics = ""
 				filtered = append(filtered, answerResult)
 			}
 		}
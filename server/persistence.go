@@ -19,9 +19,19 @@ type backingStore interface {
 	auditQueueSelectStatusReceived() ([]model.AuditQueueEntry, error)
 	auditQueueUpdateStatusFailed(id uint64) error
 	auditCheckResultsInsert(results model.AuditCheckResults, teamID uint64, timestamp int64, source string) (uint64, error)
-	hostTokenInsert(hostToken string, hostname string, timestamp int64, source string) error
+	hostCommandCountPending(hostToken string, timestamp int64) (int, error)
+	hostCommandExpire(timestamp int64, source string) error
+	hostCommandInsert(command model.HostCommand, source string) (model.HostCommand, error)
+	hostCommandSelect(id uint64) (model.HostCommand, error)
+	hostCommandSelectAll(hostToken string) ([]model.HostCommand, error)
+	hostCommandSelectPending(hostToken string) ([]model.HostCommand, error)
+	hostCommandUpdateStatus(id uint64, from model.HostCommandStatus, to model.HostCommandStatus, result string, timestamp int64, source string) error
+	hostTokenInsert(hostToken string, scenarioID uint64, hostname string, role string, timestamp int64, source string) error
 	hostTokenSelectHostname(hostToken string) (string, error)
+	hostTokenSelectRole(hostToken string) (string, error)
+	hostTokenSelectScenarioID(hostToken string) (uint64, error)
 	hostTokenSelectTeamID(hostToken string) (uint64, error)
+	hostTokenUpdateScenarioID(hostToken string, scenarioID uint64, role string) error
 	scenarioDelete(id uint64) error
 	scenarioInsert(scenario model.Scenario) (model.Scenario, error)
 	scenarioSelect(id uint64) (model.Scenario, error)
@@ -31,6 +41,8 @@ type backingStore interface {
 	scenarioHostsSelectAnswers(scenarioID uint64, hostname string) ([]model.Answer, error)
 	scenarioHostsSelectChecks(scenarioID uint64, hostname string) ([]model.Action, error)
 	scenarioHostsSelectConfig(scenarioID uint64, hostname string) ([]model.Action, error)
+	scenarioHostsSelectScripts(scenarioID uint64, hostname string) ([]model.Script, error)
+	scenarioHostsSelectHostnames(scenarioID uint64) (map[string][]string, error)
 	scenarioHostsSelectLastModified(scenarioID uint64, hostname string) (int64, error)
 	scenarioHostsDelete(scenarioID uint64) error
 	scenarioHostsUpdate(scenarioID uint64, scenarioHosts map[string]model.ScenarioHost) error
@@ -43,6 +55,7 @@ type backingStore interface {
 	teamSelectByKey(key string) (model.Team, error)
 	teamSelectAll() ([]model.TeamSummary, error)
 	teamUpdate(id uint64, team model.Team) (model.Team, error)
+	teamHostTokenDelete(hostToken string) error
 	teamHostTokenInsert(teamID uint64, hostToken string, timestamp int64) error
 	userDelete(id uint64) error
 	userInsert(user model.User) (model.User, error)
@@ -18,15 +18,28 @@ type dbObj struct {
 func (db dbObj) dbInit() {
 	db.dbCreateTable("users", "CREATE TABLE IF NOT EXISTS users(id BIGSERIAL PRIMARY KEY, username VARCHAR NOT NULL, password VARCHAR NOT NULL, enabled BOOLEAN NOT NULL, email VARCHAR NOT NULL)")
 	db.dbCreateTable("user_roles", "CREATE TABLE IF NOT EXISTS user_roles(user_id BIGSERIAL NOT NULL, role VARCHAR NOT NULL, FOREIGN KEY(user_id) REFERENCES users(id))")
-	db.dbCreateTable("host_tokens", "CREATE TABLE IF NOT EXISTS host_tokens(host_token VARCHAR NOT NULL PRIMARY KEY, timestamp INTEGER NOT NULL, hostname VARCHAR NOT NULL, source VARCHAR NOT NULL)")
+	db.dbCreateTable("host_tokens", "CREATE TABLE IF NOT EXISTS host_tokens(host_token VARCHAR NOT NULL PRIMARY KEY, timestamp INTEGER NOT NULL, hostname VARCHAR NOT NULL, source VARCHAR NOT NULL, role VARCHAR NOT NULL DEFAULT '', scenario_id BIGINT NOT NULL DEFAULT 0)")
+	db.dbCreateTable("host_tokens", "ALTER TABLE host_tokens ADD COLUMN IF NOT EXISTS role VARCHAR NOT NULL DEFAULT ''")
+	db.dbCreateTable("host_tokens", "ALTER TABLE host_tokens ADD COLUMN IF NOT EXISTS scenario_id BIGINT NOT NULL DEFAULT 0")
 	db.dbCreateTable("teams", "CREATE TABLE IF NOT EXISTS teams(id BIGSERIAL PRIMARY KEY, name VARCHAR UNIQUE NOT NULL, poc VARCHAR NOT NULL, email VARCHAR NOT NULL, enabled BOOLEAN NOT NULL, key VARCHAR NOT NULL)")
 	db.dbCreateTable("team_host_tokens", "CREATE TABLE IF NOT EXISTS team_host_tokens(team_id BIGSERIAL NOT NULL, host_token VARCHAR NOT NULL, timestamp INTEGER NOT NULL, FOREIGN KEY(team_id) REFERENCES teams(id), FOREIGN KEY(host_token) REFERENCES host_tokens(host_token))")
-	db.dbCreateTable("scenarios", "CREATE TABLE IF NOT EXISTS scenarios(id BIGSERIAL PRIMARY KEY, name VARCHAR UNIQUE NOT NULL, description VARCHAR NOT NULL, enabled BOOLEAN NOT NULL)")
-	db.dbCreateTable("scenario_hosts", "CREATE TABLE IF NOT EXISTS scenario_hosts(scenario_id BIGSERIAL NOT NULL, hostname VARCHAR NOT NULL, checks JSONB NOT NULL, answers JSONB NOT NULL, config JSONB NOT NULL, last_modified INTEGER NOT NULL, FOREIGN KEY(scenario_id) REFERENCES scenarios(id))")
+	db.dbCreateTable("scenarios", "CREATE TABLE IF NOT EXISTS scenarios(id BIGSERIAL PRIMARY KEY, name VARCHAR UNIQUE NOT NULL, description VARCHAR NOT NULL, enabled BOOLEAN NOT NULL, check_interval INTEGER NOT NULL DEFAULT 0, check_jitter INTEGER NOT NULL DEFAULT 0)")
+	db.dbCreateTable("scenarios", "ALTER TABLE scenarios ADD COLUMN IF NOT EXISTS check_interval INTEGER NOT NULL DEFAULT 0")
+	db.dbCreateTable("scenarios", "ALTER TABLE scenarios ADD COLUMN IF NOT EXISTS check_jitter INTEGER NOT NULL DEFAULT 0")
+	db.dbCreateTable("scenario_hosts", "CREATE TABLE IF NOT EXISTS scenario_hosts(scenario_id BIGSERIAL NOT NULL, hostname VARCHAR NOT NULL, checks JSONB NOT NULL, answers JSONB NOT NULL, config JSONB NOT NULL, scripts JSONB NOT NULL DEFAULT '[]', hostnames JSONB NOT NULL DEFAULT '[]', last_modified INTEGER NOT NULL, FOREIGN KEY(scenario_id) REFERENCES scenarios(id))")
+	db.dbCreateTable("scenario_hosts", "ALTER TABLE scenario_hosts ADD COLUMN IF NOT EXISTS scripts JSONB NOT NULL DEFAULT '[]'")
+	db.dbCreateTable("scenario_hosts", "ALTER TABLE scenario_hosts ADD COLUMN IF NOT EXISTS hostnames JSONB NOT NULL DEFAULT '[]'")
 	db.dbCreateTable("scoreboard", "CREATE TABLE IF NOT EXISTS scoreboard(scenario_id BIGSERIAL NOT NULL, team_id BIGSERIAL NOT NULL, hostname VARCHAR NOT NULL, score INTEGER NOT NULL, timestamp INTEGER NOT NULL, FOREIGN KEY(scenario_id) REFERENCES scenarios(id), FOREIGN KEY(team_id) REFERENCES teams(id))")
 	db.dbCreateTable("audit_check_results", "CREATE TABLE IF NOT EXISTS audit_check_results(id BIGSERIAL NOT NULL PRIMARY KEY, scenario_id BIGSERIAL NOT NULL, team_id BIGSERIAL NOT NULL, host_token VARCHAR NOT NULL, timestamp_reported INTEGER NOT NULL, timestamp_received INTEGER NOT NULL, check_results JSONB NOT NULL, source VARCHAR NOT NULL, FOREIGN KEY(scenario_id) REFERENCES scenarios(id), FOREIGN KEY(team_id) REFERENCES teams(id), FOREIGN KEY(host_token) REFERENCES host_tokens(host_token))")
+	// host tokens from before scenarios were recorded, by their results
+	db.dbCreateTable("host_tokens", "UPDATE host_tokens h SET scenario_id=a.scenario_id FROM audit_check_results a WHERE h.scenario_id=0 AND a.host_token=h.host_token")
 	db.dbCreateTable("audit_answer_results", "CREATE TABLE IF NOT EXISTS audit_answer_results(id BIGSERIAL NOT NULL PRIMARY KEY, scenario_id BIGSERIAL NOT NULL, team_id BIGSERIAL NOT NULL, host_token VARCHAR NOT NULL, timestamp INTEGER NOT NULL, audit_check_results_id BIGSERIAL NOT NULL, score INTEGER NOT NULL, answer_results JSONB NOT NULL, FOREIGN KEY(scenario_id) REFERENCES scenarios(id), FOREIGN KEY(team_id) REFERENCES teams(id), FOREIGN KEY(host_token) REFERENCES host_tokens(host_token), FOREIGN KEY(audit_check_results_id) REFERENCES audit_check_results(id))")
 	db.dbCreateTable("audit_queue", "CREATE TABLE IF NOT EXISTS audit_queue(id BIGSERIAL PRIMARY KEY, timestamp INTEGER NOT NULL, source VARCHAR NOT NULL, body JSONB NOT NULL, status VARCHAR NOT NULL)")
+	db.dbCreateTable("host_commands", "CREATE TABLE IF NOT EXISTS host_commands(id BIGSERIAL PRIMARY KEY, host_token VARCHAR NOT NULL, type VARCHAR NOT NULL, status VARCHAR NOT NULL, created INTEGER NOT NULL, expires INTEGER NOT NULL, created_by BIGINT NOT NULL, result VARCHAR NOT NULL DEFAULT '', FOREIGN KEY(host_token) REFERENCES host_tokens(host_token))")
+	db.dbCreateTable("host_command_events", "CREATE TABLE IF NOT EXISTS host_command_events(id BIGSERIAL PRIMARY KEY, host_command_id BIGINT NOT NULL, timestamp INTEGER NOT NULL, status VARCHAR NOT NULL, source VARCHAR NOT NULL, message VARCHAR NOT NULL, FOREIGN KEY(host_command_id) REFERENCES host_commands(id))")
+	db.dbCreateTable("migrations", "CREATE TABLE IF NOT EXISTS migrations(name VARCHAR NOT NULL PRIMARY KEY, timestamp INTEGER NOT NULL)")
+
+	db.dbMigrate("checks_run_as_privileged", migrateChecksRunAsPrivileged)
 
 	log.Println("Finished setting up database")
 }
@@ -46,6 +59,85 @@ func (db dbObj) dbCreateTable(name string, stmtStr string) {
 	}
 }
 
+// runs once per database, recorded in migrations in the same transaction
+func (db dbObj) dbMigrate(name string, migrate func(tx *sql.Tx) error) {
+	tx, err := db.dbConn.Begin()
+	if err != nil {
+		log.Fatal("ERROR: cannot migrate "+name+";", err)
+	}
+	result, err := tx.Exec("INSERT INTO migrations(name, timestamp) VALUES($1, $2) ON CONFLICT DO NOTHING", name, time.Now().Unix())
+	if err != nil {
+		tx.Rollback()
+		log.Fatal("ERROR: cannot migrate "+name+";", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil || count == 0 {
+		tx.Rollback()
+		return
+	}
+	err = migrate(tx)
+	if err != nil {
+		tx.Rollback()
+		log.Fatal("ERROR: cannot migrate "+name+";", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Fatal("ERROR: cannot migrate "+name+";", err)
+	}
+	log.Println("Migrated database: " + name)
+}
+
+// empty RunAs used to keep root, it now means the unprivileged account, so
+// checks stored before keep root by being marked privileged
+func migrateChecksRunAsPrivileged(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT scenario_id, hostname, checks FROM scenario_hosts")
+	if err != nil {
+		return err
+	}
+	type hostChecks struct {
+		scenarioID uint64
+		hostname   string
+		checks     []model.Action
+	}
+	hosts := make([]hostChecks, 0)
+	for rows.Next() {
+		var host hostChecks
+		var checksBs []byte
+		err = rows.Scan(&host.scenarioID, &host.hostname, &checksBs)
+		if err == nil {
+			err = json.Unmarshal(checksBs, &host.checks)
+		}
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		hosts = append(hosts, host)
+	}
+	rows.Close()
+
+	for _, host := range hosts {
+		checksBs, err := json.Marshal(checksRunAsPrivileged(host.checks))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE scenario_hosts SET checks=$1 WHERE scenario_id=$2 AND hostname=$3", checksBs, host.scenarioID, host.hostname)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checksRunAsPrivileged(checks []model.Action) []model.Action {
+	for i := range checks {
+		if checks[i].Type == model.ActionTypeExec && len(checks[i].RunAs) == 0 {
+			checks[i].RunAs = model.RunAsPrivileged
+		}
+		checks[i].Checks = checksRunAsPrivileged(checks[i].Checks)
+	}
+	return checks
+}
+
 func (db dbObj) dbDelete(stmtStr string, args ...interface{}) error {
 	stmt, err := db.dbConn.Prepare(stmtStr)
 	if err != nil {
@@ -254,8 +346,115 @@ func (db dbObj) auditQueueUpdateStatusFailed(id uint64) error {
 	return db.dbDelete("UPDATE audit_queue SET status=$1 WHERE id=$2", model.AuditQueueStatusFailed, id)
 }
 
-func (db dbObj) hostTokenInsert(hostToken string, hostname string, timestamp int64, source string) error {
-	_, err := db.dbInsert("INSERT INTO host_tokens(host_token, hostname, timestamp, source) VALUES($1, $2, $3, $4)", hostToken, hostname, timestamp, source)
+// sent commands stay pending until the agent reports a result
+func (db dbObj) hostCommandCountPending(hostToken string, timestamp int64) (int, error) {
+	var count int
+	err := db.dbConn.QueryRow("SELECT COUNT(*) FROM host_commands WHERE host_token=$1 AND status IN ($2, $3) AND expires>$4", hostToken, model.HostCommandStatusQueued, model.HostCommandStatusSent, timestamp).Scan(&count)
+	return count, err
+}
+
+// commands no agent picked up or reported on in time
+func (db dbObj) hostCommandExpire(timestamp int64, source string) error {
+	commands, err := db.hostCommandSelectWhere("c.status IN ($1, $2) AND c.expires<=$3", model.HostCommandStatusQueued, model.HostCommandStatusSent, timestamp)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		err = db.hostCommandUpdateStatus(command.ID, command.Status, model.HostCommandStatusExpired, "", timestamp, source)
+		if err != nil && err.Error() != model.ErrorDBUpdateNoChange {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db dbObj) hostCommandInsert(command model.HostCommand, source string) (model.HostCommand, error) {
+	id, err := db.dbInsert("INSERT INTO host_commands(host_token, type, status, created, expires, created_by) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+		command.HostToken, command.Type, model.HostCommandStatusQueued, command.Created, command.Expires, command.CreatedBy)
+	if err != nil {
+		return command, err
+	}
+	command.ID = id
+	command.Status = model.HostCommandStatusQueued
+	err = db.hostCommandEventInsert(id, command.Created, model.HostCommandStatusQueued, source, "")
+	return command, err
+}
+
+func (db dbObj) hostCommandEventInsert(id uint64, timestamp int64, status model.HostCommandStatus, source string, message string) error {
+	_, err := db.dbInsert("INSERT INTO host_command_events(host_command_id, timestamp, status, source, message) VALUES($1, $2, $3, $4, $5)", id, timestamp, status, source, message)
+	return err
+}
+
+func (db dbObj) hostCommandSelect(id uint64) (model.HostCommand, error) {
+	commands, err := db.hostCommandSelectWhere("c.id=$1", id)
+	if err != nil || len(commands) == 0 {
+		return model.HostCommand{}, err
+	}
+	command := commands[0]
+
+	rows, err := db.dbConn.Query("SELECT timestamp, status, source, message FROM host_command_events WHERE host_command_id=$1 ORDER BY id ASC", id)
+	if err != nil {
+		return command, err
+	}
+	defer rows.Close()
+
+	command.Events = make([]model.HostCommandEvent, 0)
+	for rows.Next() {
+		event := model.HostCommandEvent{}
+		err = rows.Scan(&event.Timestamp, &event.Status, &event.Source, &event.Message)
+		if err != nil {
+			return command, err
+		}
+		command.Events = append(command.Events, event)
+	}
+
+	return command, nil
+}
+
+// empty host token for all
+func (db dbObj) hostCommandSelectAll(hostToken string) ([]model.HostCommand, error) {
+	if len(hostToken) == 0 {
+		return db.hostCommandSelectWhere("TRUE")
+	}
+	return db.hostCommandSelectWhere("c.host_token=$1", hostToken)
+}
+
+// queued and sent, includes expired commands, so they can be marked expired
+func (db dbObj) hostCommandSelectPending(hostToken string) ([]model.HostCommand, error) {
+	return db.hostCommandSelectWhere("c.host_token=$1 AND c.status IN ($2, $3)", hostToken, model.HostCommandStatusQueued, model.HostCommandStatusSent)
+}
+
+func (db dbObj) hostCommandSelectWhere(where string, args ...interface{}) ([]model.HostCommand, error) {
+	rows, err := db.dbConn.Query("SELECT c.id, c.host_token, h.hostname, c.type, c.status, c.created, c.expires, c.created_by, c.result FROM host_commands c JOIN host_tokens h ON c.host_token=h.host_token WHERE "+where+" ORDER BY c.id ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commands := make([]model.HostCommand, 0)
+	for rows.Next() {
+		command := model.HostCommand{}
+		err = rows.Scan(&command.ID, &command.HostToken, &command.Hostname, &command.Type, &command.Status, &command.Created, &command.Expires, &command.CreatedBy, &command.Result)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+
+	return commands, nil
+}
+
+// only from the status given, so a command is not both sent and canceled
+func (db dbObj) hostCommandUpdateStatus(id uint64, from model.HostCommandStatus, to model.HostCommandStatus, result string, timestamp int64, source string) error {
+	err := db.dbUpdate("UPDATE host_commands SET status=$1, result=$2 WHERE id=$3 AND status=$4", to, result, id, from)
+	if err != nil {
+		return err
+	}
+	return db.hostCommandEventInsert(id, timestamp, to, source, result)
+}
+
+func (db dbObj) hostTokenInsert(hostToken string, scenarioID uint64, hostname string, role string, timestamp int64, source string) error {
+	_, err := db.dbInsert("INSERT INTO host_tokens(host_token, scenario_id, hostname, role, timestamp, source) VALUES($1, $2, $3, $4, $5, $6)", hostToken, scenarioID, hostname, role, timestamp, source)
 	return err
 }
 
@@ -280,6 +479,56 @@ func (db dbObj) hostTokenSelectHostname(hostToken string) (string, error) {
 	return hostname, nil
 }
 
+// host tokens from before roles were keyed by hostname
+func (db dbObj) hostTokenSelectRole(hostToken string) (string, error) {
+	var role string
+
+	rows, err := db.dbConn.Query("SELECT COALESCE(NULLIF(role, ''), hostname) FROM host_tokens WHERE host_token=$1", hostToken)
+	if err != nil {
+		return role, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&role)
+		if err != nil {
+			return role, err
+		}
+		// only get first result
+		break
+	}
+
+	return role, nil
+}
+
+// only host tokens from before scenarios were recorded, for the scenario
+// they ask checks for with their own role
+func (db dbObj) hostTokenUpdateScenarioID(hostToken string, scenarioID uint64, role string) error {
+	return db.dbUpdate("UPDATE host_tokens SET scenario_id=$1 WHERE host_token=$2 AND scenario_id=0 AND COALESCE(NULLIF(role, ''), hostname)=$3", scenarioID, hostToken, role)
+}
+
+// 0 for host tokens from before scenarios were recorded that never reported
+func (db dbObj) hostTokenSelectScenarioID(hostToken string) (uint64, error) {
+	var scenarioID uint64
+
+	rows, err := db.dbConn.Query("SELECT scenario_id FROM host_tokens WHERE host_token=$1", hostToken)
+	if err != nil {
+		return scenarioID, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&scenarioID)
+		if err != nil {
+			return scenarioID, err
+		}
+		// only get first result
+		break
+	}
+
+	return scenarioID, nil
+}
+
 func (db dbObj) hostTokenSelectTeamID(hostToken string) (uint64, error) {
 	var teamID uint64
 
@@ -311,7 +560,7 @@ func (db dbObj) scenarioDelete(id uint64) error {
 }
 
 func (db dbObj) scenarioInsert(scenario model.Scenario) (model.Scenario, error) {
-	id, err := db.dbInsert("INSERT INTO scenarios(name, description, enabled) VALUES($1, $2, $3) RETURNING id", scenario.Name, scenario.Description, scenario.Enabled)
+	id, err := db.dbInsert("INSERT INTO scenarios(name, description, enabled, check_interval, check_jitter) VALUES($1, $2, $3, $4, $5) RETURNING id", scenario.Name, scenario.Description, scenario.Enabled, scenario.CheckInterval, scenario.CheckJitter)
 	if err != nil {
 		return model.Scenario{}, err
 	}
@@ -322,7 +571,7 @@ func (db dbObj) scenarioInsert(scenario model.Scenario) (model.Scenario, error)
 func (db dbObj) scenarioSelect(id uint64) (model.Scenario, error) {
 	var scenario model.Scenario
 
-	rows, err := db.dbConn.Query("SELECT id, name, description, enabled FROM scenarios WHERE id=$1", id)
+	rows, err := db.dbConn.Query("SELECT id, name, description, enabled, check_interval, check_jitter FROM scenarios WHERE id=$1", id)
 	if err != nil {
 		return scenario, err
 	}
@@ -330,7 +579,7 @@ func (db dbObj) scenarioSelect(id uint64) (model.Scenario, error) {
 
 	for rows.Next() {
 		scenario = model.Scenario{}
-		err = rows.Scan(&scenario.ID, &scenario.Name, &scenario.Description, &scenario.Enabled)
+		err = rows.Scan(&scenario.ID, &scenario.Name, &scenario.Description, &scenario.Enabled, &scenario.CheckInterval, &scenario.CheckJitter)
 		if err != nil {
 			return scenario, err
 		}
@@ -368,7 +617,7 @@ func (db dbObj) scenarioUpdate(id uint64, scenario model.Scenario) (model.Scenar
 		enabled = 0
 	}
 
-	err := db.dbUpdate("UPDATE scenarios SET name=$1, description=$2, enabled=$3 WHERE id=$4", scenario.Name, scenario.Description, enabled, id)
+	err := db.dbUpdate("UPDATE scenarios SET name=$1, description=$2, enabled=$3, check_interval=$4, check_jitter=$5 WHERE id=$6", scenario.Name, scenario.Description, enabled, scenario.CheckInterval, scenario.CheckJitter, id)
 	if err != nil {
 		return model.Scenario{}, err
 	}
@@ -377,7 +626,7 @@ func (db dbObj) scenarioUpdate(id uint64, scenario model.Scenario) (model.Scenar
 }
 
 func (db dbObj) scenarioHostsSelectAll(scenarioID uint64) (map[string]model.ScenarioHost, error) {
-	rows, err := db.dbConn.Query("SELECT hostname, checks, answers, config FROM scenario_hosts WHERE scenario_id=$1", scenarioID)
+	rows, err := db.dbConn.Query("SELECT hostname, checks, answers, config, scripts, hostnames FROM scenario_hosts WHERE scenario_id=$1", scenarioID)
 	if err != nil {
 		return nil, err
 	}
@@ -392,7 +641,11 @@ func (db dbObj) scenarioHostsSelectAll(scenarioID uint64) (map[string]model.Scen
 		var answersBs []byte
 		var config []model.Action
 		var configBs []byte
-		err = rows.Scan(&hostname, &checksBs, &answersBs, &configBs)
+		var scripts []model.Script
+		var scriptsBs []byte
+		var hostnames []string
+		var hostnamesBs []byte
+		err = rows.Scan(&hostname, &checksBs, &answersBs, &configBs, &scriptsBs, &hostnamesBs)
 		if err != nil {
 			return nil, err
 		}
@@ -408,11 +661,21 @@ func (db dbObj) scenarioHostsSelectAll(scenarioID uint64) (map[string]model.Scen
 		if err != nil {
 			return nil, err
 		}
+		err = json.Unmarshal(scriptsBs, &scripts)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(hostnamesBs, &hostnames)
+		if err != nil {
+			return nil, err
+		}
 
 		hostMap[hostname] = model.ScenarioHost{
-			Checks:  checks,
-			Answers: answers,
-			Config:  config,
+			Checks:    checks,
+			Answers:   answers,
+			Config:    config,
+			Hostnames: hostnames,
+			Scripts:   scripts,
 		}
 	}
 
@@ -491,6 +754,57 @@ func (db dbObj) scenarioHostsSelectConfig(scenarioID uint64, hostname string) ([
 	return config, nil
 }
 
+func (db dbObj) scenarioHostsSelectScripts(scenarioID uint64, hostname string) ([]model.Script, error) {
+	rows, err := db.dbConn.Query("SELECT scripts FROM scenario_hosts WHERE scenario_id=$1 AND hostname=$2", scenarioID, hostname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scripts []model.Script
+	var scriptsBs []byte
+	for rows.Next() {
+		err = rows.Scan(&scriptsBs)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(scriptsBs, &scripts)
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	return scripts, nil
+}
+
+// role names, the hostname column, with their hostname patterns
+func (db dbObj) scenarioHostsSelectHostnames(scenarioID uint64) (map[string][]string, error) {
+	rows, err := db.dbConn.Query("SELECT hostname, hostnames FROM scenario_hosts WHERE scenario_id=$1", scenarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hostPatterns := make(map[string][]string)
+	for rows.Next() {
+		var role string
+		var hostnames []string
+		var hostnamesBs []byte
+		err = rows.Scan(&role, &hostnamesBs)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(hostnamesBs, &hostnames)
+		if err != nil {
+			return nil, err
+		}
+		hostPatterns[role] = hostnames
+	}
+
+	return hostPatterns, nil
+}
+
 func (db dbObj) scenarioHostsSelectLastModified(scenarioID uint64, hostname string) (int64, error) {
 	rows, err := db.dbConn.Query("SELECT last_modified FROM scenario_hosts WHERE scenario_id=$1 AND hostname=$2", scenarioID, hostname)
 	if err != nil {
@@ -534,7 +848,23 @@ func (db dbObj) scenarioHostsUpdate(scenarioID uint64, scenarioHosts map[string]
 		if err != nil {
 			return err
 		}
-		_, err = db.dbInsert("INSERT INTO scenario_hosts(scenario_id, hostname, checks, answers, config, last_modified) VALUES ($1, $2, $3, $4, $5, $6)", scenarioID, hostname, checksBs, answersBs, configBs, time.Now().Unix())
+		scripts := scenarioHost.Scripts
+		if scripts == nil {
+			scripts = []model.Script{}
+		}
+		scriptsBs, err := json.Marshal(scripts)
+		if err != nil {
+			return err
+		}
+		hostnames := scenarioHost.Hostnames
+		if hostnames == nil {
+			hostnames = []string{}
+		}
+		hostnamesBs, err := json.Marshal(hostnames)
+		if err != nil {
+			return err
+		}
+		_, err = db.dbInsert("INSERT INTO scenario_hosts(scenario_id, hostname, checks, answers, config, scripts, hostnames, last_modified) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", scenarioID, hostname, checksBs, answersBs, configBs, scriptsBs, hostnamesBs, time.Now().Unix())
 		if err != nil {
 			return err
 		}
@@ -714,6 +1044,10 @@ func (db dbObj) teamUpdate(id uint64, team model.Team) (model.Team, error) {
 	return db.teamSelect(id)
 }
 
+func (db dbObj) teamHostTokenDelete(hostToken string) error {
+	return db.dbDelete("DELETE FROM team_host_tokens WHERE host_token=$1", hostToken)
+}
+
 func (db dbObj) teamHostTokenInsert(teamID uint64, hostToken string, timestamp int64) error {
 	_, err := db.dbInsert("INSERT INTO team_host_tokens(team_id, host_token, timestamp) VALUES($1, $2, $3)", teamID, hostToken, timestamp)
 	return err
@@ -59,6 +59,7 @@ func main() {
 	var port string
 	var dbURL string
 	var bytesJwtSecret []byte
+	var bytesEnrollToken []byte
 	for _, line := range strings.Split(string(bytesConfig), "\n") {
 		line = strings.TrimSpace(line)
 		if len(line) == 0 {
@@ -75,6 +76,8 @@ func main() {
 			dbURL = tokens[1]
 		} else if tokens[0] == "jwt_secret" {
 			bytesJwtSecret = []byte(tokens[1])
+		} else if tokens[0] == "enroll_token" {
+			bytesEnrollToken = []byte(tokens[1])
 		} else {
 			log.Fatalf("ERROR: unknown config file setting %s\n", tokens[0])
 		}
@@ -87,6 +90,7 @@ func main() {
 	apiHandler := APIHandler{
 		BackingStore: backingStore,
 		jwtSecret:    bytesJwtSecret,
+		enrollToken:  bytesEnrollToken,
 	}
 
 	// generate default user if no users
@@ -145,6 +149,12 @@ func main() {
 	}
 	apiHandler.entities = entities
 
+	// agents verify updates against these
+	err = signAgentBinaries(dirPublic, version, entities[0])
+	if err != nil {
+		log.Println("ERROR: cannot sign agent binaries;", err)
+	}
+
 	// async audit
 	go func() {
 		for {
@@ -163,6 +173,17 @@ func main() {
 		}
 	}()
 
+	// commands for hosts that stopped asking
+	go func() {
+		for {
+			err := apiHandler.BackingStore.hostCommandExpire(time.Now().Unix(), "server")
+			if err != nil {
+				log.Println("ERROR: unable to expire host commands;", err)
+			}
+			time.Sleep(time.Minute)
+		}
+	}()
+
 	// API routing
 	r := mux.NewRouter().StrictSlash(true)
 	r.Use(apiHandler.middlewareLog)
@@ -177,9 +198,23 @@ func main() {
 	apiRouter.HandleFunc("/", apiHandler.readAPIRoot).Methods("GET")
 	apiRouter.HandleFunc("/version", apiHandler.readAPIVersion).Methods("GET")
 
+	// agent-commands, host token required
+	agentCommandRouter := apiRouter.PathPrefix("/agent-commands").Subrouter()
+	agentCommandRouter.HandleFunc("/{id:[0-9]+}", apiHandler.readAgentCommands).Methods("GET")
+	agentCommandRouter.HandleFunc("/results/{id:[0-9]+}", apiHandler.updateAgentCommandResult).Methods("POST")
+
 	// audit, no auth
 	auditRouter := apiRouter.PathPrefix("/audit").Subrouter()
 	auditRouter.HandleFunc("/", apiHandler.audit).Methods("POST")
+	auditRouter.HandleFunc("/batch", apiHandler.auditBatch).Methods("POST")
+
+	// host-commands, auth required
+	hostCommandRouter := apiRouter.PathPrefix("/host-commands").Subrouter()
+	hostCommandRouter.Use(apiHandler.middlewareAuth)
+	hostCommandRouter.HandleFunc("/", apiHandler.readHostCommands).Methods("GET")
+	hostCommandRouter.HandleFunc("/", apiHandler.createHostCommand).Methods("POST")
+	hostCommandRouter.HandleFunc("/{id:[0-9]+}", apiHandler.deleteHostCommand).Methods("DELETE")
+	hostCommandRouter.HandleFunc("/{id:[0-9]+}", apiHandler.readHostCommand).Methods("GET")
 
 	// host-token, no auth
 	hostTokenRouter := apiRouter.PathPrefix("/host-token").Subrouter()
@@ -231,6 +266,11 @@ func main() {
 	reportRouter.HandleFunc("/{id:[0-9]+}/hostnames", apiHandler.readScenarioReportHostnames).Methods("GET")
 	reportRouter.HandleFunc("/{id:[0-9]+}/timeline", apiHandler.readScenarioReportTimeline).Methods("GET")
 
+	// scenario-config, enroll token required
+	scenarioConfigRouter := apiRouter.PathPrefix("/scenario-config").Subrouter()
+	scenarioConfigRouter.Use(apiHandler.middlewareEnroll)
+	scenarioConfigRouter.HandleFunc("/{id:[0-9]+}", apiHandler.readScenarioConfig).Methods("GET")
+
 	// scenario-desc, no auth
 	scenarioDescRouter := apiRouter.PathPrefix("/scenario-desc").Subrouter()
 	scenarioDescRouter.HandleFunc("/{id:[0-9]+}", apiHandler.readScenario).Methods("GET")
@@ -239,6 +279,10 @@ func main() {
 	scenarioChecksRouter := apiRouter.PathPrefix("/scenario-checks").Subrouter()
 	scenarioChecksRouter.HandleFunc("/{id:[0-9]+}", apiHandler.readScenarioChecks).Methods("GET")
 
+	// scenario-scripts, no auth
+	scenarioScriptsRouter := apiRouter.PathPrefix("/scenario-scripts").Subrouter()
+	scenarioScriptsRouter.HandleFunc("/{id:[0-9]+}", apiHandler.readScenarioScripts).Methods("GET")
+
 	// scoreboard, no auth
 	scoreboardRouter := apiRouter.PathPrefix("/scoreboard").Subrouter()
 	scoreboardRouter.HandleFunc("/scenarios", apiHandler.readScoreboardScenarios).Methods("GET")
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"io/ioutil"
+	"log"
 	"math/rand"
+	"path/filepath"
 	"strings"
 
 	"github.com/netwayfind/cp-scoring/model"
+	"github.com/netwayfind/cp-scoring/processing"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/openpgp"
 )
 
 func checkPasswordHash(cleartext string, hash string) bool {
@@ -33,3 +38,36 @@ func randHexStr(length int) string {
 	}
 	return output.String()
 }
+
+// writes a detached signature of the server version and each agent binary
+// next to the binary, unless the signature there already covers both
+func signAgentBinaries(dirPublic string, version string, entity *openpgp.Entity) error {
+	files, err := filepath.Glob(filepath.Join(dirPublic, "cp-scoring-agent-*"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, ".sig") {
+			continue
+		}
+		bs, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		payload := processing.AgentUpdatePayload(version, bs)
+		signature, err := ioutil.ReadFile(file + ".sig")
+		if err == nil && processing.VerifyDetached(payload, signature, openpgp.EntityList{entity}) == nil {
+			continue
+		}
+		signature, err = processing.SignDetached(payload, entity)
+		if err != nil {
+			return err
+		}
+		log.Println("Signing " + filepath.Base(file) + " for version " + version)
+		err = ioutil.WriteFile(file+".sig", signature, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
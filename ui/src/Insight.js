@@ -142,6 +142,24 @@ class Insight extends Component {
     );
   }
 
+  renderDetails(details) {
+    if (!details || details.length === 0) {
+      return null;
+    }
+    let entries = [];
+    details.forEach((detail, i) => {
+      entries.push(
+        <li key={i}>
+          {detail.Passed ? "pass" : "fail"} - {detail.Description}:{" "}
+          {detail.Result}
+          {detail.Diagnostics ? <pre>{detail.Diagnostics}</pre> : null}
+          {this.renderDetails(detail.Children)}
+        </li>
+      );
+    });
+    return <ul>{entries}</ul>;
+  }
+
   render() {
     let hostSelect = "No hosts found";
     if (this.state.hostnames.length > 0) {
@@ -215,6 +233,8 @@ class Insight extends Component {
           let entry = (
             <li key={i}>
               <strong>{result.Points}</strong> - {result.Description}
+              {result.Diagnostics ? <pre>{result.Diagnostics}</pre> : null}
+              {this.renderDetails(result.Details)}
             </li>
           );
           results.push(<li key={i}>{entry}</li>);
@@ -163,12 +163,14 @@ class Scenario extends Component {
     }
   }
 
-  handleSaveHost(checks, answers, config) {
+  handleSaveHost(checks, answers, config, scripts, hostnames) {
     let id = this.state.scenario.ID;
     let scenarioHost = {
       Checks: checks,
       Answers: answers,
       Config: config,
+      Hostnames: hostnames,
+      Scripts: scripts,
     };
     let scenarioHosts = {
       ...this.state.scenarioHosts,
@@ -195,6 +197,8 @@ class Scenario extends Component {
     let value = event.target.value;
     if (event.target.type === "checkbox") {
       value = event.target.checked;
+    } else if (event.target.type === "number") {
+      value = Number(value);
     }
     this.setState({
       scenario: {
@@ -272,6 +276,7 @@ class Scenario extends Component {
       Checks: [],
       Answers: [],
       Config: [],
+      Scripts: [],
     };
     let scenarioHosts = {
       ...this.state.scenarioHosts,
@@ -302,6 +307,8 @@ class Scenario extends Component {
     let answers = this.state.currentScenarioHost.Answers || [];
     let checks = this.state.currentScenarioHost.Checks || [];
     let config = this.state.currentScenarioHost.Config || [];
+    let scripts = this.state.currentScenarioHost.Scripts || [];
+    let hostnames = this.state.currentScenarioHost.Hostnames || [];
     let hostname = this.state.currentScenarioHostname;
 
     let scenarioHosts = null;
@@ -310,7 +317,7 @@ class Scenario extends Component {
       if (hostname) {
         scenarioHost = (
           <Fragment>
-            <label>Hostname</label>
+            <label>Role or Hostname</label>
             <input onChange={this.handleHostnameRename} value={hostname} />
             <br />
             <button type="button" onClick={this.handleHostnameCopy}>
@@ -324,7 +331,9 @@ class Scenario extends Component {
               checks={checks}
               config={config}
               hostname={hostname}
+              hostnames={hostnames}
               parentCallback={this.handleSaveHost}
+              scripts={scripts}
             />
           </Fragment>
         );
@@ -387,6 +396,26 @@ class Scenario extends Component {
             checked={this.state.scenario.Enabled || false}
           />
           <br />
+          <label htmlFor="CheckInterval">Check Interval (seconds)</label>
+          <input
+            className="input-5"
+            min="0"
+            name="CheckInterval"
+            onChange={this.handleUpdate}
+            type="number"
+            value={this.state.scenario.CheckInterval || 0}
+          />
+          <br />
+          <label htmlFor="CheckJitter">Check Jitter (seconds)</label>
+          <input
+            className="input-5"
+            min="0"
+            name="CheckJitter"
+            onChange={this.handleUpdate}
+            type="number"
+            value={this.state.scenario.CheckJitter || 0}
+          />
+          <br />
           <button type="submit">Save</button>
           <button
             type="button"
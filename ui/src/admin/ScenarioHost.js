@@ -10,15 +10,31 @@ const ACTION_PRESET = Object.freeze({
 });
 
 const ACTION_PRESET_CHECK = Object.freeze({
+  COMPOSITE_SSH_ROOT_LOGIN_DISABLED_LINUX:
+    "composite ssh installed and root login disabled (linux)",
+  CONFIG_PASSWORD_MAX_DAYS_LINUX: "config password max days (linux)",
+  CONFIG_SSH_ROOT_LOGIN_DISABLED: "config ssh root login disabled",
   FILE_PERMISSIONS_LINUX: "file permissions (linux)",
+  FILE_SWEEP_SUID_LINUX: "file sweep SUID (linux)",
+  FILE_SWEEP_WORLD_WRITABLE_LINUX: "file sweep world-writable (linux)",
   FIREWALL_INBOUND_ALLOW_IPTABLES: "firewall inbound allow (iptables)",
   FIREWALL_INBOUND_ALLOW_UFW: "firewall inbound allow (ufw)",
   FIREWALL_INBOUND_DEFAULT_DROP_LINUX: "firewall inbound default drop (linux)",
   FIREWALL_FORWARD_DEFAULT_DROP_LINUX: "firewall forward default drop (linux)",
+  FIREWALL_UFW_ENABLED: "firewall enabled (ufw)",
   NETWORK_SERVICE_NOT_AVAILABLE_LINUX: "network service not available (linux)",
+  PAM_FAILLOCK_DENY_LINUX: "PAM faillock deny (linux)",
+  PAM_PASSWORD_HISTORY_LINUX: "PAM password history (linux)",
+  PAM_PASSWORD_MIN_LENGTH_LINUX: "PAM password min length (linux)",
+  SCHEDULED_JOB_REMOVED_LINUX: "scheduled job removed (linux)",
+  SERVICE_ACTIVE_LINUX: "service active (linux)",
+  SERVICE_DISABLED_LINUX: "service disabled (linux)",
   SOFTWARE_INSTALLED_LINUX: "software installed (linux)",
   SOFTWARE_PACKAGES_UPDATED_LINUX: "software packages updated (linux)",
   SOFTWARE_REMOVED_LINUX: "software removed (linux)",
+  SUDO_NO_ROOT_ACCESS_LINUX: "sudo no root access (linux)",
+  SUDO_NOPASSWD_REMOVED_LINUX: "sudo NOPASSWD removed (linux)",
+  SYSCTL_IP_FORWARD_DISABLED_LINUX: "sysctl ip forward disabled (linux)",
   TMP_APT_PACKAGE_LIST: "TEMP: apt package list",
   TMP_APT_PACKAGE_LIST_REMOVE: "TEMP: apt package list remove",
   USER_ADDED_LINUX: "user added (linux)",
@@ -33,23 +49,50 @@ const ACTION_PRESET_CHECK = Object.freeze({
 });
 
 const ACTION_PRESET_CONFIG = Object.freeze({
+  FILE_WRITE: "file write",
   FIREWALL_OFF_WINDOWS: "firewall off (windows)",
+  GROUP_ADD_MEMBER: "group add member",
   INSTALL_CHOCO: "install chocolatey",
   INSTALL_PACKAGES_LINUX: "install packages (linux)",
   INSTALL_SOFTWARE_CHOCO: "install software (choco)",
   NET_SHARE_ADD: "net share add",
   NEW_DIR_LINUX: "new directory (linux)",
   NEW_DIR_WINDOWS: "new directory (windows)",
+  PACKAGE_INSTALL_LINUX: "package install (linux)",
+  SERVICE_ENABLE: "service enable",
   USER_ADD_LINUX: "user add (linux)",
   USER_ADD_LINUX_SYSTEM: "user add (linux - system)",
   USER_ADD_WINDOWS: "user add (windows)",
+  USER_CREATE: "user create",
 });
 
 const CHECK_TYPE = Object.freeze({
+  COMPOSITE: "COMPOSITE",
+  CONFIG_VALUE: "CONFIG_VALUE",
   EXEC: "EXEC",
   FILE_EXIST: "FILE_EXIST",
   FILE_REGEX: "FILE_REGEX",
+  FILE_SWEEP: "FILE_SWEEP",
   FILE_VALUE: "FILE_VALUE",
+  FIREWALL_ENABLED: "FIREWALL_ENABLED",
+  FIREWALL_POLICY: "FIREWALL_POLICY",
+  FIREWALL_RULE: "FIREWALL_RULE",
+  PAM_SETTING: "PAM_SETTING",
+  SCHEDULED_JOB: "SCHEDULED_JOB",
+  SERVICE_ACTIVE: "SERVICE_ACTIVE",
+  SERVICE_ENABLED: "SERVICE_ENABLED",
+  SUDO_PRIVILEGE: "SUDO_PRIVILEGE",
+  SYSCTL: "SYSCTL",
+});
+
+// config actions other than EXEC only change what is not already in place
+const CONFIG_TYPE = Object.freeze({
+  EXEC: "EXEC",
+  FILE_WRITE: "FILE_WRITE",
+  GROUP_ADD_MEMBER: "GROUP_ADD_MEMBER",
+  PACKAGE_INSTALL: "PACKAGE_INSTALL",
+  SERVICE_ENABLE: "SERVICE_ENABLE",
+  USER_CREATE: "USER_CREATE",
 });
 
 const COMMAND = Object.freeze({
@@ -60,8 +103,21 @@ const COMMAND = Object.freeze({
   SH: "/bin/sh",
 });
 
+// empty RunAs is the unprivileged account, only privileged checks keep root
+const RUN_AS_PRIVILEGED = "PRIVILEGED";
+const RUN_AS_UNPRIVILEGED = "UNPRIVILEGED";
+
+const EXEC_RESULT = Object.freeze({
+  STDOUT: "STDOUT",
+  EXIT_CODE: "EXIT_CODE",
+});
+
 const OPERATOR = Object.freeze({
   EQUAL: "EQUAL",
+  GREATER_THAN: "GREATER_THAN",
+  GREATER_THAN_OR_EQUAL: "GREATER_THAN_OR_EQUAL",
+  LESS_THAN: "LESS_THAN",
+  LESS_THAN_OR_EQUAL: "LESS_THAN_OR_EQUAL",
   NOT_EQUAL: "NOT_EQUAL",
 });
 
@@ -73,6 +129,8 @@ class ScenarioHost extends Component {
       checks: props.checks,
       config: props.config,
       hostname: props.hostname,
+      hostnames: props.hostnames.join("\n"),
+      scripts: props.scripts,
       presetAddCheck: ACTION_PRESET.EXEC,
       presetAddConfig: ACTION_PRESET.EXEC,
     };
@@ -85,6 +143,7 @@ class ScenarioHost extends Component {
     this.handleCheckArgAdd = this.handleCheckArgAdd.bind(this);
     this.handleCheckArgDelete = this.handleCheckArgDelete.bind(this);
     this.handleCheckArgUpdate = this.handleCheckArgUpdate.bind(this);
+    this.handleCheckChildrenUpdate = this.handleCheckChildrenUpdate.bind(this);
     this.handleConfigAdd = this.handleConfigAdd.bind(this);
     this.handleConfigDelete = this.handleConfigDelete.bind(this);
     this.handleConfigUpdate = this.handleConfigUpdate.bind(this);
@@ -92,7 +151,11 @@ class ScenarioHost extends Component {
     this.handleConfigArgAdd = this.handleConfigArgAdd.bind(this);
     this.handleConfigArgDelete = this.handleConfigArgDelete.bind(this);
     this.handleConfigArgUpdate = this.handleConfigArgUpdate.bind(this);
+    this.handleHostnamesUpdate = this.handleHostnamesUpdate.bind(this);
     this.handleSave = this.handleSave.bind(this);
+    this.handleScriptAdd = this.handleScriptAdd.bind(this);
+    this.handleScriptDelete = this.handleScriptDelete.bind(this);
+    this.handleScriptUpdate = this.handleScriptUpdate.bind(this);
     this.handleUpdatePresetAddCheck = this.handleUpdatePresetAddCheck.bind(
       this
     );
@@ -108,6 +171,8 @@ class ScenarioHost extends Component {
         checks: this.props.checks,
         config: this.props.config,
         hostname: this.props.hostname,
+        hostnames: this.props.hostnames.join("\n"),
+        scripts: this.props.scripts,
       });
     }
   }
@@ -139,6 +204,10 @@ class ScenarioHost extends Component {
       Type: preset.Type,
       Command: preset.Command,
       Args: preset.Args,
+      Checks: preset.Checks,
+      Answers: preset.Answers,
+      Serial: preset.Serial,
+      RunAs: preset.RunAs,
     });
     this.setState({
       answers: answers,
@@ -174,6 +243,11 @@ class ScenarioHost extends Component {
     let name = event.target.name;
     let value = event.target.value;
     let checks = [...this.state.checks];
+    if (event.target.type === "number") {
+      value = Number(value);
+    } else if (event.target.type === "checkbox") {
+      value = event.target.checked;
+    }
     checks[i][name] = value;
     this.setState({
       checks: checks,
@@ -205,6 +279,22 @@ class ScenarioHost extends Component {
     });
   }
 
+  handleCheckChildrenUpdate(i, event) {
+    let children;
+    try {
+      children = JSON.parse(event.target.value);
+    } catch (error) {
+      alert("Invalid children: " + error.message);
+      return;
+    }
+    let checks = [...this.state.checks];
+    checks[i]["Checks"] = children.Checks;
+    checks[i]["Answers"] = children.Answers;
+    this.setState({
+      checks: checks,
+    });
+  }
+
   handleConfigAdd() {
     let config = [...this.state.config];
     let preset = this.preset(this.state.presetAddConfig);
@@ -272,17 +362,58 @@ class ScenarioHost extends Component {
     });
   }
 
+  handleHostnamesUpdate(event) {
+    this.setState({
+      hostnames: event.target.value,
+    });
+  }
+
   handleSave(event) {
     if (event !== null) {
       event.preventDefault();
     }
+    let hostnames = this.state.hostnames
+      .split("\n")
+      .map((hostname) => hostname.trim())
+      .filter((hostname) => hostname.length > 0);
     this.props.parentCallback(
       this.state.checks,
       this.state.answers,
-      this.state.config
+      this.state.config,
+      this.state.scripts,
+      hostnames
     );
   }
 
+  handleScriptAdd() {
+    let scripts = [...this.state.scripts];
+    scripts.push({
+      Name: "script" + (scripts.length + 1) + ".sh",
+      Body: "",
+    });
+    this.setState({
+      scripts: scripts,
+    });
+  }
+
+  handleScriptDelete(i) {
+    let scripts = [...this.state.scripts];
+    scripts.splice(i, 1);
+    this.setState({
+      scripts: scripts,
+    });
+  }
+
+  handleScriptUpdate(i, event) {
+    let name = event.target.name;
+    let value = event.target.value;
+    let scripts = [...this.state.scripts];
+    scripts[i][name] = value;
+    this.setState({
+      scripts: scripts,
+    });
+  }
+
   handleUpdatePresetAddCheck(event) {
     let value = event.target.value;
     this.setState({
@@ -305,6 +436,10 @@ class ScenarioHost extends Component {
     let operator = "";
     let value = "";
     let points = 0;
+    let checks = null;
+    let answers = null;
+    let serial = false;
+    let runAs = "";
     if (p === ACTION_PRESET.EXEC) {
       // default
     } else if (p === ACTION_PRESET.SH) {
@@ -313,47 +448,145 @@ class ScenarioHost extends Component {
     } else if (p === ACTION_PRESET.POWERSHELL) {
       command = COMMAND.POWERSHELL;
       args = ["-command", ""];
+    } else if (
+      p === ACTION_PRESET_CHECK.COMPOSITE_SSH_ROOT_LOGIN_DISABLED_LINUX
+    ) {
+      // all_of, any_of, none_of; each child passes when its answer passes
+      type = CHECK_TYPE.COMPOSITE;
+      args = ["all_of"];
+      checks = [
+        {
+          Description: "ssh installed",
+          Type: CHECK_TYPE.FILE_EXIST,
+          Command: "",
+          Args: ["/etc/ssh/sshd_config"],
+        },
+        {
+          Description: "root login disabled",
+          Type: CHECK_TYPE.CONFIG_VALUE,
+          Command: "",
+          Args: ["/etc/ssh/sshd_config", "sshd", "PermitRootLogin"],
+        },
+      ];
+      answers = [
+        { Operator: OPERATOR.EQUAL, Value: "true", Points: 0 },
+        { Operator: OPERATOR.EQUAL, Value: "no", Points: 0 },
+      ];
+      operator = OPERATOR.EQUAL;
+      value = "true";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.CONFIG_PASSWORD_MAX_DAYS_LINUX) {
+      // file, dialect (sshd, sudoers, keyvalue, ini, json, yaml), key, section
+      type = CHECK_TYPE.CONFIG_VALUE;
+      args = ["/etc/login.defs", "keyvalue", "PASS_MAX_DAYS"];
+      operator = OPERATOR.EQUAL;
+      value = "90";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.CONFIG_SSH_ROOT_LOGIN_DISABLED) {
+      type = CHECK_TYPE.CONFIG_VALUE;
+      args = ["/etc/ssh/sshd_config", "sshd", "PermitRootLogin"];
+      operator = OPERATOR.EQUAL;
+      value = "no";
+      points = 1;
     } else if (p === ACTION_PRESET_CHECK.FILE_PERMISSIONS_LINUX) {
       command = COMMAND.SH;
       args = ["-c", "stat -c %a file"];
       operator = OPERATOR.EQUAL;
       value = "###";
-    } else if (p === ACTION_PRESET_CHECK.FIREWALL_INBOUND_DEFAULT_DROP_LINUX) {
-      command = COMMAND.SH;
-      args = ["-c", "iptables -nvL INPUT | grep -q 'policy DROP' ; echo $?"];
+    } else if (p === ACTION_PRESET_CHECK.FILE_SWEEP_SUID_LINUX) {
+      // root, predicate, output (count, digest), max depth, exclusions
+      type = CHECK_TYPE.FILE_SWEEP;
+      args = ["/", "suid", "count", "", "/proc", "/sys"];
+      operator = OPERATOR.EQUAL;
+      value = "#";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.FILE_SWEEP_WORLD_WRITABLE_LINUX) {
+      type = CHECK_TYPE.FILE_SWEEP;
+      args = ["/etc", "world_writable", "count", ""];
       operator = OPERATOR.EQUAL;
       value = "0";
       points = 1;
+    } else if (p === ACTION_PRESET_CHECK.FIREWALL_INBOUND_DEFAULT_DROP_LINUX) {
+      // backend (iptables, ip6tables, nftables, ufw), chain
+      type = CHECK_TYPE.FIREWALL_POLICY;
+      args = ["iptables", "INPUT"];
+      operator = OPERATOR.EQUAL;
+      value = "DROP";
+      points = 1;
     } else if (p === ACTION_PRESET_CHECK.FIREWALL_FORWARD_DEFAULT_DROP_LINUX) {
-      command = COMMAND.SH;
-      args = ["-c", "iptables -nvL FORWARD | grep -q 'policy DROP' ; echo $?"];
+      type = CHECK_TYPE.FIREWALL_POLICY;
+      args = ["iptables", "FORWARD"];
       operator = OPERATOR.EQUAL;
-      value = "0";
+      value = "DROP";
       points = 1;
     } else if (p === ACTION_PRESET_CHECK.FIREWALL_INBOUND_ALLOW_IPTABLES) {
-      command = COMMAND.SH;
-      args = [
-        "-c",
-        "iptables -nvL INPUT | grep 'protocol dpt:port' | grep -q ACCEPT ; echo $?",
-      ];
+      // backend, chain, target (ACCEPT, DROP, REJECT), protocol, port
+      type = CHECK_TYPE.FIREWALL_RULE;
+      args = ["iptables", "INPUT", "ACCEPT", "tcp", "port"];
       operator = OPERATOR.EQUAL;
-      value = "0";
+      value = "true";
       points = 1;
     } else if (p === ACTION_PRESET_CHECK.FIREWALL_INBOUND_ALLOW_UFW) {
-      command = COMMAND.SH;
-      args = [
-        "-c",
-        "iptables -nvL ufw-user-input | grep 'protocol dpt:port' | grep -q ACCEPT ; echo $?",
-      ];
+      type = CHECK_TYPE.FIREWALL_RULE;
+      args = ["ufw", "INPUT", "ACCEPT", "tcp", "port"];
       operator = OPERATOR.EQUAL;
-      value = "0";
+      value = "true";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.FIREWALL_UFW_ENABLED) {
+      // backend
+      type = CHECK_TYPE.FIREWALL_ENABLED;
+      args = ["ufw"];
+      operator = OPERATOR.EQUAL;
+      value = "true";
       points = 1;
     } else if (p === ACTION_PRESET_CHECK.NETWORK_SERVICE_NOT_AVAILABLE_LINUX) {
       command = COMMAND.SH;
       args = ["-c", "ss -ntlp | grep ':port ' | grep -q service ; echo $?"];
+      // process names of other users need root
+      runAs = RUN_AS_PRIVILEGED;
       operator = OPERATOR.NOT_EQUAL;
       value = "0";
       points = -1;
+    } else if (p === ACTION_PRESET_CHECK.PAM_FAILLOCK_DENY_LINUX) {
+      // service, type (auth, account, password, session), module, option
+      type = CHECK_TYPE.PAM_SETTING;
+      args = ["common-auth", "auth", "pam_faillock", "deny"];
+      operator = OPERATOR.LESS_THAN_OR_EQUAL;
+      value = "5";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.PAM_PASSWORD_HISTORY_LINUX) {
+      type = CHECK_TYPE.PAM_SETTING;
+      args = ["common-password", "password", "pam_pwhistory", "remember"];
+      operator = OPERATOR.GREATER_THAN_OR_EQUAL;
+      value = "5";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.PAM_PASSWORD_MIN_LENGTH_LINUX) {
+      type = CHECK_TYPE.PAM_SETTING;
+      args = ["common-password", "password", "pam_pwquality", "minlen"];
+      operator = OPERATOR.GREATER_THAN_OR_EQUAL;
+      value = "12";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.SCHEDULED_JOB_REMOVED_LINUX) {
+      // command regex, user, schedule regex, output (exists, count)
+      type = CHECK_TYPE.SCHEDULED_JOB;
+      args = ["command", "", "", "exists"];
+      operator = OPERATOR.EQUAL;
+      value = "false";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.SERVICE_ACTIVE_LINUX) {
+      // unit name
+      type = CHECK_TYPE.SERVICE_ACTIVE;
+      args = ["service"];
+      operator = OPERATOR.EQUAL;
+      value = "active";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.SERVICE_DISABLED_LINUX) {
+      // enabled, disabled, static, masked, missing
+      type = CHECK_TYPE.SERVICE_ENABLED;
+      args = ["service"];
+      operator = OPERATOR.NOT_EQUAL;
+      value = "enabled";
+      points = 1;
     } else if (p === ACTION_PRESET_CHECK.SOFTWARE_INSTALLED_LINUX) {
       command = COMMAND.SH;
       args = ["-c", "grep -q '^software/' apt; echo $?"];
@@ -371,12 +604,38 @@ class ScenarioHost extends Component {
       args = ["-c", "grep -q '^software/' apt; echo $?"];
       operator = OPERATOR.NOT_EQUAL;
       value = "0";
+    } else if (p === ACTION_PRESET_CHECK.SUDO_NO_ROOT_ACCESS_LINUX) {
+      // user, privilege (any, all, nopasswd)
+      type = CHECK_TYPE.SUDO_PRIVILEGE;
+      args = ["user", "any"];
+      operator = OPERATOR.EQUAL;
+      value = "false";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.SUDO_NOPASSWD_REMOVED_LINUX) {
+      type = CHECK_TYPE.SUDO_PRIVILEGE;
+      args = ["user", "nopasswd"];
+      operator = OPERATOR.EQUAL;
+      value = "false";
+      points = 1;
+    } else if (p === ACTION_PRESET_CHECK.SYSCTL_IP_FORWARD_DISABLED_LINUX) {
+      // name, mode (runtime, persistent)
+      type = CHECK_TYPE.SYSCTL;
+      args = ["net.ipv4.ip_forward", "runtime"];
+      operator = OPERATOR.EQUAL;
+      value = "0";
+      points = 1;
     } else if (p === ACTION_PRESET_CHECK.TMP_APT_PACKAGE_LIST) {
+      // other checks read the file, so it must be written before they run
       command = COMMAND.SH;
       args = ["-c", "apt list --installed > apt"];
+      serial = true;
+      // only root can write to the temp directory
+      runAs = RUN_AS_PRIVILEGED;
     } else if (p === ACTION_PRESET_CHECK.TMP_APT_PACKAGE_LIST_REMOVE) {
       command = COMMAND.SH;
       args = ["-c", "rm apt"];
+      serial = true;
+      runAs = RUN_AS_PRIVILEGED;
     } else if (p === ACTION_PRESET_CHECK.USER_ADDED_LINUX) {
       command = COMMAND.SH;
       args = ["-c", "grep -q '^user:' /etc/passwd; echo $?"];
@@ -413,6 +672,8 @@ class ScenarioHost extends Component {
         "-c",
         "grep '^user' /etc/shadow | grep -q 'passwordHash' ; echo $?",
       ];
+      // only root can read /etc/shadow
+      runAs = RUN_AS_PRIVILEGED;
       operator = OPERATOR.EQUAL;
       value = "1";
       points = 1;
@@ -442,9 +703,16 @@ class ScenarioHost extends Component {
       ];
       operator = OPERATOR.EQUAL;
       value = '{"Count":0}';
+    } else if (p === ACTION_PRESET_CONFIG.FILE_WRITE) {
+      // path, content, mode, owner
+      type = CONFIG_TYPE.FILE_WRITE;
+      args = ["path", "content", "0644", "root:root"];
     } else if (p === ACTION_PRESET_CONFIG.FIREWALL_OFF_WINDOWS) {
       command = COMMAND.CMD;
       args = ["/C", "netsh advfirewall set allprofiles state off"];
+    } else if (p === ACTION_PRESET_CONFIG.GROUP_ADD_MEMBER) {
+      type = CONFIG_TYPE.GROUP_ADD_MEMBER;
+      args = ["group", "username"];
     } else if (p === ACTION_PRESET_CONFIG.INSTALL_CHOCO) {
       command = COMMAND.POWERSHELL;
       args = [
@@ -466,6 +734,12 @@ class ScenarioHost extends Component {
     } else if (p === ACTION_PRESET_CONFIG.NEW_DIR_WINDOWS) {
       command = COMMAND.CMD;
       args = ["/C", "mkdir directory"];
+    } else if (p === ACTION_PRESET_CONFIG.PACKAGE_INSTALL_LINUX) {
+      type = CONFIG_TYPE.PACKAGE_INSTALL;
+      args = ["package"];
+    } else if (p === ACTION_PRESET_CONFIG.SERVICE_ENABLE) {
+      type = CONFIG_TYPE.SERVICE_ENABLE;
+      args = ["service"];
     } else if (p === ACTION_PRESET_CONFIG.USER_ADD_LINUX) {
       command = COMMAND.SH;
       args = [
@@ -481,6 +755,9 @@ class ScenarioHost extends Component {
     } else if (p === ACTION_PRESET_CONFIG.USER_ADD_WINDOWS) {
       command = COMMAND.NET;
       args = ["user", "username", "password", "/add"];
+    } else if (p === ACTION_PRESET_CONFIG.USER_CREATE) {
+      type = CONFIG_TYPE.USER_CREATE;
+      args = ["username"];
     } else {
       description = "unsupported preset";
     }
@@ -489,6 +766,10 @@ class ScenarioHost extends Component {
       Type: type,
       Command: command,
       Args: args,
+      Checks: checks,
+      Answers: answers,
+      Serial: serial,
+      RunAs: runAs,
       Operator: operator,
       Value: value,
       Points: points,
@@ -501,6 +782,21 @@ class ScenarioHost extends Component {
       let value = CHECK_TYPE[type];
       actionOptions.push(<option key={type}>{value}</option>);
     }
+    let configTypeOptions = [];
+    for (let type in CONFIG_TYPE) {
+      let value = CONFIG_TYPE[type];
+      configTypeOptions.push(<option key={type}>{value}</option>);
+    }
+    let execResultOptions = [];
+    for (let result in EXEC_RESULT) {
+      let value = EXEC_RESULT[result];
+      execResultOptions.push(<option key={result}>{value}</option>);
+    }
+    let scriptOptions = [];
+    scriptOptions.push(<option key="" />);
+    this.state.scripts.forEach((script, i) => {
+      scriptOptions.push(<option key={i}>{script.Name}</option>);
+    });
     let operatorOptions = [];
     operatorOptions.push(<option key="" />);
     for (let operator in OPERATOR) {
@@ -608,10 +904,75 @@ class ScenarioHost extends Component {
                   value={check.Command}
                 />
                 <br />
+                <label htmlFor="Script">Script</label>
+                <select
+                  name="Script"
+                  onChange={(event) => this.handleCheckUpdate(i, event)}
+                  value={check.Script || ""}
+                >
+                  {scriptOptions}
+                </select>
+                <br />
+                <label htmlFor="RunAs">Run As</label>
+                <input
+                  className="input-20"
+                  name="RunAs"
+                  onChange={(event) => this.handleCheckUpdate(i, event)}
+                  placeholder={
+                    RUN_AS_PRIVILEGED + ", " + RUN_AS_UNPRIVILEGED + " or user"
+                  }
+                  value={check.RunAs || ""}
+                />
+                <br />
+                <label htmlFor="Timeout">Timeout (seconds)</label>
+                <input
+                  className="input-5"
+                  name="Timeout"
+                  onChange={(event) => this.handleCheckUpdate(i, event)}
+                  value={check.Timeout || 0}
+                  type="number"
+                  steps="1"
+                />
+                <br />
+                <label htmlFor="Result">Result</label>
+                <select
+                  name="Result"
+                  onChange={(event) => this.handleCheckUpdate(i, event)}
+                  value={check.Result || EXEC_RESULT.STDOUT}
+                >
+                  {execResultOptions}
+                </select>
+                <br />
               </Fragment>
             ) : null}
+            <label htmlFor="Serial">Serial</label>
+            <input
+              name="Serial"
+              onChange={(event) => this.handleCheckUpdate(i, event)}
+              checked={check.Serial || false}
+              type="checkbox"
+            />
+            <br />
             <label htmlFor="Args">Args</label>
             <ul>{args}</ul>
+            {check.Type === CHECK_TYPE.COMPOSITE ? (
+              <Fragment>
+                <label htmlFor="Children">Children</label>
+                <br />
+                <textarea
+                  className="input-50"
+                  name="Children"
+                  rows="10"
+                  onBlur={(event) => this.handleCheckChildrenUpdate(i, event)}
+                  defaultValue={JSON.stringify(
+                    { Checks: check.Checks || [], Answers: check.Answers || [] },
+                    null,
+                    2
+                  )}
+                />
+                <br />
+              </Fragment>
+            ) : null}
             <label htmlFor="Answer">Answer</label>
             <select
               name="Operator"
@@ -715,18 +1076,26 @@ class ScenarioHost extends Component {
             />
             <br />
             <label htmlFor="Type">Type</label>
-            <select disabled name="Type" value={CHECK_TYPE.EXEC}>
-              {actionOptions}
-            </select>
-            <br />
-            <label htmlFor="Command">Command</label>
-            <input
-              className="input-50"
-              name="Command"
+            <select
+              name="Type"
               onChange={(event) => this.handleConfigUpdate(i, event)}
-              value={conf.Command}
-            />
+              value={conf.Type}
+            >
+              {configTypeOptions}
+            </select>
             <br />
+            {conf.Type === CONFIG_TYPE.EXEC ? (
+              <Fragment>
+                <label htmlFor="Command">Command</label>
+                <input
+                  className="input-50"
+                  name="Command"
+                  onChange={(event) => this.handleConfigUpdate(i, event)}
+                  value={conf.Command}
+                />
+                <br />
+              </Fragment>
+            ) : null}
             <label htmlFor="Args">Args</label>
             <ul>{args}</ul>
           </details>
@@ -747,8 +1116,61 @@ class ScenarioHost extends Component {
       </li>
     );
 
+    let scriptList = [];
+    this.state.scripts.forEach((script, i) => {
+      scriptList.push(
+        <li key={i}>
+          <details>
+            <summary>{script.Name}</summary>
+            <button type="button" onClick={() => this.handleScriptDelete(i)}>
+              Delete Script
+            </button>
+            <p />
+            <label htmlFor="Name">Name</label>
+            <input
+              className="input-20"
+              name="Name"
+              onChange={(event) => this.handleScriptUpdate(i, event)}
+              value={script.Name}
+            />
+            <br />
+            <label htmlFor="Body">Body</label>
+            <br />
+            <textarea
+              cols="80"
+              name="Body"
+              onChange={(event) => this.handleScriptUpdate(i, event)}
+              rows="10"
+              value={script.Body}
+            />
+          </details>
+        </li>
+      );
+    });
+    scriptList.push(
+      <li key="script_add">
+        <button type="button" onClick={this.handleScriptAdd}>
+          Add Script
+        </button>
+      </li>
+    );
+
     return (
       <form onSubmit={this.handleSave}>
+        <p>Hostnames</p>
+        <p>
+          One per line, matched when an agent did not declare a role. Globs
+          like ws-* or regular expressions starting with re:
+        </p>
+        <textarea
+          cols="40"
+          name="Hostnames"
+          onChange={this.handleHostnamesUpdate}
+          rows="4"
+          value={this.state.hostnames}
+        />
+        <p>Scripts</p>
+        <ul>{scriptList}</ul>
         <p>Checks</p>
         <ol>{checkList}</ol>
         <p>Config</p>